@@ -1,36 +1,45 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"github.com/google/uuid"
 	"github.com/hibiken/asynq"
 	"github.com/tuanta7/qworker/config"
 	"github.com/tuanta7/qworker/internal/domain"
 	"github.com/tuanta7/qworker/pkg/db"
 	"github.com/tuanta7/qworker/pkg/logger"
 	"go.uber.org/zap"
+	"os"
+	"os/signal"
 	"strconv"
+	"syscall"
 	"time"
 )
 
 func main() {
-	cfg := config.InitConfig()
+	cfg := config.InitConfig(nil)
 	zl := logger.MustNewLogger(cfg.Logger.Level)
 
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
 	redisClient := db.MustNewRedisSentinelClient(cfg)
 	defer redisClient.Close()
 
 	asynqClient := asynq.NewClientFromRedisClient(redisClient)
 	defer asynqClient.Close()
 
-	message := &domain.QueueMessage{
-		ConnectorID: 2,
-		TaskType:    config.QueueTask[config.QueueFullSync],
-	}
+	message := domain.NewQueueMessage(2, config.QueueTask[config.QueueFullSync], domain.TriggerManual)
+	message.RequestedBy = os.Getenv("USER")
 	taskID := strconv.FormatUint(message.ConnectorID, 10)
-	payload, _ := json.Marshal(message)
 
 	for {
-		info, err := asynqClient.Enqueue(
+		message.CorrelationID = uuid.NewString()
+		message.ScheduledAt = time.Now()
+		payload, _ := json.Marshal(message)
+
+		info, err := asynqClient.EnqueueContext(ctx,
 			asynq.NewTask(message.TaskType, payload),
 			asynq.TaskID(taskID),
 			asynq.Queue(config.QueueFullSync),
@@ -40,7 +49,12 @@ func main() {
 			zl.Error("Enqueue failed", zap.Error(err))
 		}
 		zl.Info("Enqueued", zap.Any("info", info))
-		time.Sleep(10 * time.Second)
-	}
 
+		select {
+		case <-ctx.Done():
+			zl.Info("Stopped")
+			return
+		case <-time.After(10 * time.Second):
+		}
+	}
 }
@@ -3,21 +3,37 @@ package main
 import (
 	"context"
 	"github.com/hibiken/asynq"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/tuanta7/qworker/config"
 	"github.com/tuanta7/qworker/internal/handler"
 	pgrepo "github.com/tuanta7/qworker/internal/repository/postgres"
 	"github.com/tuanta7/qworker/internal/usecase/connector"
 	"github.com/tuanta7/qworker/internal/usecase/scheduler"
 	"github.com/tuanta7/qworker/pkg/db"
+	"github.com/tuanta7/qworker/pkg/health"
 	"github.com/tuanta7/qworker/pkg/logger"
+	"github.com/tuanta7/qworker/pkg/tracing"
+	"go.uber.org/zap"
 	"log"
+	"os/signal"
+	"syscall"
 )
 
 func main() {
-	cfg := config.InitConfig()
+	cfg := config.InitConfig((*config.Config).ValidateScheduler)
 	zapLogger := logger.MustNewLogger(cfg.Logger.Level)
 
-	pgClient := db.MustNewPostgresClient(cfg)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	shutdownTracing := tracing.MustInit(context.Background(), "qworker-scheduler", cfg.Tracing)
+	defer shutdownTracing(context.Background())
+
+	pgClient := db.MustNewPostgresClient(cfg,
+		db.WithMaxConns(cfg.Postgres.MaxConns),
+		db.WithMinConns(cfg.Postgres.MinConns),
+		db.WithTracing(),
+	)
 	defer pgClient.Close()
 
 	redisClient := db.MustNewRedisSentinelClient(cfg)
@@ -34,15 +50,40 @@ func main() {
 	schedulerUsecase := scheduleruc.NewUseCase(asynqClient, asynqInspector, zapLogger)
 	schedulerHandler := handler.NewSchedulerHandler(cfg, schedulerUsecase, connectorUsecase)
 
-	err := schedulerHandler.Init(context.Background())
+	err := schedulerHandler.Init(ctx)
 	if err != nil {
 		log.Fatalf("schedulerHandler.InitScheduledJobs(): %v", err)
 	}
-	defer schedulerHandler.Clear()
 
 	s := NewScheduler(pgClient, zapLogger)
+
+	healthServer := health.NewServer(cfg.ServerAddress())
+	healthServer.AddCheck("postgres", func(ctx context.Context) error { return pgClient.Pool().Ping(ctx) })
+	healthServer.AddCheck("redis", func(ctx context.Context) error { return redisClient.Ping(ctx).Err() })
+	healthServer.AddCheck("listen", s.Ready)
+	healthServer.AddCheck("leader", schedulerHandler.Ready)
+	healthServer.Handle("GET /metrics", promhttp.Handler())
+	if err := healthServer.Start(); err != nil {
+		log.Fatalf("healthServer.Start(): %v", err)
+	}
+
 	s.RegisterHandler("insert", schedulerHandler.HandleInsertConnector)
 	s.RegisterHandler("update", schedulerHandler.HandleUpdateConnector)
 	s.RegisterHandler("delete", schedulerHandler.HandleDeleteConnector)
-	s.Listen(context.Background(), "connectors_changes", 10)
+	s.RegisterHandler(ActionReload, schedulerHandler.HandleReload)
+	s.Listen(ctx, "connectors_changes", 10)
+
+	zapLogger.Info("shutting down scheduler", zap.Duration("timeout", cfg.Scheduler.ShutdownTimeout))
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Scheduler.ShutdownTimeout)
+	defer cancel()
+
+	err = schedulerHandler.Clear(shutdownCtx)
+	if err != nil {
+		zapLogger.Warn("schedulerHandler.Clear()", zap.Error(err))
+	}
+
+	err = healthServer.Shutdown(shutdownCtx)
+	if err != nil {
+		zapLogger.Warn("healthServer.Shutdown()", zap.Error(err))
+	}
 }
@@ -3,20 +3,31 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/tuanta7/qworker/internal/domain"
+	"github.com/tuanta7/qworker/internal/metrics"
 	"github.com/tuanta7/qworker/pkg/db"
 	"github.com/tuanta7/qworker/pkg/logger"
 	"go.uber.org/zap"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
 type Scheduler struct {
-	pgClient db.PostgresClient
-	zl       *logger.ZapLogger
-	handlers map[string]SchedulerHandlerFunc
+	pgClient  db.PostgresClient
+	zl        *logger.ZapLogger
+	handlers  map[string]SchedulerHandlerFunc
+	listening atomic.Bool
 }
 
+// ActionReload is handled after the LISTEN connection was replaced.
+const ActionReload = "reload"
+
+// reloadPayload asks the reload handler to reconcile the jobs with the stored connectors.
+var reloadPayload = `{"action": "` + ActionReload + `"}`
+
 type SchedulerHandlerFunc func(c context.Context, msg *domain.NotifyMessage) error
 
 func NewScheduler(pgClient db.PostgresClient, zl *logger.ZapLogger) *Scheduler {
@@ -27,27 +38,54 @@ func NewScheduler(pgClient db.PostgresClient, zl *logger.ZapLogger) *Scheduler {
 	}
 }
 
+// Listen blocks until ctx is done, then UNLISTENs and waits for queued notifications to be handled.
 func (s *Scheduler) Listen(ctx context.Context, channelName string, buffer int) {
 	conn, err := s.pgClient.Pool().Acquire(ctx)
 	if err != nil {
 		panic(err)
 	}
-	defer conn.Release()
 
 	_, err = conn.Exec(ctx, "LISTEN "+channelName)
 	if err != nil {
 		panic(err)
 	}
-	defer conn.Exec(ctx, "UNLISTEN "+channelName)
+	defer func() {
+		if conn != nil {
+			s.unlisten(conn, channelName)
+			conn.Release()
+		}
+	}()
+	s.listening.Store(true)
+	defer s.listening.Store(false)
 
 	notifyChan := make(chan string, buffer)
-	go s.ProcessNotifications(notifyChan)
+	processed := make(chan struct{})
+	go func() {
+		s.ProcessNotifications(notifyChan)
+		close(processed)
+	}()
+	defer func() {
+		close(notifyChan)
+		<-processed
+	}()
 
 	for {
 		notification, err := conn.Conn().WaitForNotification(ctx)
+		if ctx.Err() != nil {
+			s.zl.Info("stop listening for notifications", zap.String("channel", channelName))
+			return
+		}
 		if err != nil {
+			s.listening.Store(false)
 			s.zl.Error("listen - conn.Conn().WaitForNotification", zap.Error(err))
-			time.Sleep(10 * time.Second)
+			s.discard(conn)
+			conn = s.relisten(ctx, channelName)
+			if conn == nil {
+				return
+			}
+			s.listening.Store(true)
+			// the notifications sent while the connection was down are lost
+			notifyChan <- reloadPayload
 			continue
 		}
 
@@ -57,6 +95,58 @@ func (s *Scheduler) Listen(ctx context.Context, channelName string, buffer int)
 	}
 }
 
+// discard closes a connection that failed while waiting, so that the pool destroys it on release.
+func (s *Scheduler) discard(conn *pgxpool.Conn) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_ = conn.Conn().Close(ctx)
+	conn.Release()
+}
+
+// relisten acquires a new connection and LISTENs on it, retrying every 10 seconds. It returns nil once ctx
+// is done.
+func (s *Scheduler) relisten(ctx context.Context, channelName string) *pgxpool.Conn {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(10 * time.Second):
+		}
+
+		conn, err := s.pgClient.Pool().Acquire(ctx)
+		if err != nil {
+			s.zl.Error("listen - s.pgClient.Pool().Acquire", zap.Error(err))
+			continue
+		}
+
+		_, err = conn.Exec(ctx, "LISTEN "+channelName)
+		if err != nil {
+			s.zl.Error("listen - conn.Exec - LISTEN", zap.Error(err))
+			s.discard(conn)
+			continue
+		}
+
+		s.zl.Info("listening again for notifications", zap.String("channel", channelName))
+		return conn
+	}
+}
+
+func (s *Scheduler) unlisten(conn *pgxpool.Conn, channelName string) {
+	// cancelling WaitForNotification closes the connection, and its LISTEN with it
+	if conn.Conn().IsClosed() {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := conn.Exec(ctx, "UNLISTEN "+channelName)
+	if err != nil {
+		s.zl.Warn("listen - conn.Exec - UNLISTEN", zap.Error(err))
+	}
+}
+
 func (s *Scheduler) ProcessNotifications(notifyChan <-chan string) {
 	for n := range notifyChan {
 		s.zl.Info("notification received", zap.Any("notification", n))
@@ -64,23 +154,38 @@ func (s *Scheduler) ProcessNotifications(notifyChan <-chan string) {
 		message := &domain.NotifyMessage{}
 		err := json.Unmarshal([]byte(n), message)
 		if err != nil {
+			metrics.NotificationErrors.WithLabelValues("invalid").Inc()
 			s.zl.Error("failed to unmarshal notification message", zap.Error(err))
 			continue
 		}
 
-		requestHandler, exists := s.handlers[strings.ToLower(message.Action)]
+		action := strings.ToLower(message.Action)
+		metrics.Notifications.WithLabelValues(action).Inc()
+
+		requestHandler, exists := s.handlers[action]
 		if exists {
 			err = requestHandler(context.TODO(), message)
 			if err != nil {
+				metrics.NotificationErrors.WithLabelValues(action).Inc()
 				s.zl.Warn("error while handling trigger action", zap.Error(err))
 				continue
 			}
 		} else {
+			metrics.NotificationErrors.WithLabelValues(action).Inc()
 			s.zl.Error("unknown action", zap.String("action", message.Action))
 		}
 	}
 }
 
+// Ready reports whether the LISTEN connection is alive. It turns unready as soon as waiting for
+// notifications fails and recovers once a new connection LISTENs again.
+func (s *Scheduler) Ready(_ context.Context) error {
+	if !s.listening.Load() {
+		return errors.New("not listening for connector changes")
+	}
+	return nil
+}
+
 func (s *Scheduler) RegisterHandler(action string, handler func(c context.Context, msg *domain.NotifyMessage) error) {
 	if s.handlers == nil {
 		s.handlers = make(map[string]SchedulerHandlerFunc)
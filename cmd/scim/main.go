@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/tuanta7/qworker/config"
+	"github.com/tuanta7/qworker/internal/handler"
+	pgrepo "github.com/tuanta7/qworker/internal/repository/postgres"
+	"github.com/tuanta7/qworker/internal/usecase/scim"
+	"github.com/tuanta7/qworker/pkg/cipherx"
+	"github.com/tuanta7/qworker/pkg/db"
+	"github.com/tuanta7/qworker/pkg/health"
+	"github.com/tuanta7/qworker/pkg/logger"
+	"github.com/tuanta7/qworker/pkg/tracing"
+	"go.uber.org/zap"
+	"log"
+	"os/signal"
+	"syscall"
+)
+
+func main() {
+	cfg := config.InitConfig((*config.Config).ValidateSCIM)
+
+	aead, err := cipherx.New(cipherx.AEAD, []byte(cfg.AESSecret))
+	if err != nil {
+		panic(err)
+	}
+
+	zl := logger.MustNewLogger(cfg.Logger.Level)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	shutdownTracing := tracing.MustInit(context.Background(), "qworker-scim", cfg.Tracing)
+	defer shutdownTracing(context.Background())
+
+	pgClient := db.MustNewPostgresClient(cfg,
+		db.WithMaxConns(cfg.Postgres.MaxConns),
+		db.WithMinConns(cfg.Postgres.MinConns),
+		db.WithTracing(),
+	)
+	defer pgClient.Close()
+
+	userRepository := pgrepo.NewUserRepository(pgClient)
+	connectorRepository := pgrepo.NewConnectorRepository(pgClient)
+	scimUsecase := scimuc.NewUseCase(connectorRepository, userRepository, aead, zl)
+	scimHandler := handler.NewSCIMHandler(scimUsecase, cfg.SCIM.MaxPageSize, zl)
+
+	// SCIM requests share the listener of the health and metrics endpoints.
+	server := health.NewServer(cfg.ServerAddress())
+	server.AddCheck("postgres", func(ctx context.Context) error { return pgClient.Pool().Ping(ctx) })
+	server.Handle("GET /metrics", promhttp.Handler())
+	server.Handle("/scim/v2/", scimHandler.Routes())
+	if err := server.Start(); err != nil {
+		log.Fatalf("server.Start(): %v", err)
+	}
+	zl.Info("scim server started", zap.String("address", cfg.ServerAddress()))
+
+	<-ctx.Done()
+	zl.Info("shutting down scim server", zap.Duration("timeout", cfg.SCIM.ShutdownTimeout))
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.SCIM.ShutdownTimeout)
+	defer cancel()
+
+	err = server.Shutdown(shutdownCtx)
+	if err != nil {
+		zl.Warn("server.Shutdown()", zap.Error(err))
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// asynqHealth keeps the last result of the asynq server health check, asynq does not expose its state.
+type asynqHealth struct {
+	lock    sync.RWMutex
+	checked bool
+	err     error
+}
+
+func (h *asynqHealth) Record(err error) {
+	h.lock.Lock()
+	h.checked = true
+	h.err = err
+	h.lock.Unlock()
+}
+
+func (h *asynqHealth) Check(_ context.Context) error {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	if !h.checked {
+		return errors.New("asynq server has not reported its health yet")
+	}
+	return h.err
+}
@@ -1,21 +1,50 @@
 package main
 
 import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/tuanta7/qworker/internal/handler"
+	"github.com/tuanta7/qworker/internal/metrics"
 	pgrepo "github.com/tuanta7/qworker/internal/repository/postgres"
+	redisrepo "github.com/tuanta7/qworker/internal/repository/redis"
+	"github.com/tuanta7/qworker/internal/source"
+	"github.com/tuanta7/qworker/internal/source/file"
+	"github.com/tuanta7/qworker/internal/source/http"
+	"github.com/tuanta7/qworker/internal/source/ldap"
+	"github.com/tuanta7/qworker/internal/source/scim"
+	"github.com/tuanta7/qworker/internal/source/sql"
 	"github.com/tuanta7/qworker/internal/usecase/connector"
+	"github.com/tuanta7/qworker/internal/usecase/preview"
+	"github.com/tuanta7/qworker/internal/usecase/webhook"
 	"github.com/tuanta7/qworker/internal/usecase/worker"
 	"github.com/tuanta7/qworker/pkg/cipherx"
 	"github.com/tuanta7/qworker/pkg/ldapclient"
 	"log"
+	"maps"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+	"time"
 
 	"github.com/hibiken/asynq"
 	"github.com/tuanta7/qworker/config"
 	"github.com/tuanta7/qworker/pkg/db"
+	"github.com/tuanta7/qworker/pkg/health"
 	"github.com/tuanta7/qworker/pkg/logger"
+	"github.com/tuanta7/qworker/pkg/tracing"
+	"go.uber.org/zap"
 )
 
 func main() {
-	cfg := config.InitConfig()
+	if len(os.Args) > 1 && os.Args[1] == "preview" {
+		runPreview(os.Args[2:])
+		return
+	}
+
+	cfg := config.InitConfig((*config.Config).ValidateWorker)
 
 	aead, err := cipherx.New(cipherx.AEAD, []byte(cfg.AESSecret))
 	if err != nil {
@@ -23,9 +52,20 @@ func main() {
 	}
 
 	zl := logger.MustNewLogger(cfg.Logger.Level)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	shutdownTracing := tracing.MustInit(context.Background(), "qworker-worker", cfg.Tracing)
+	defer shutdownTracing(context.Background())
+
 	ldapClient := ldapclient.NewLDAPClient(cfg.StartTLS.SkipVerify)
 
-	pgClient := db.MustNewPostgresClient(cfg, db.WithMaxConns(10), db.WithMinConns(3))
+	pgClient := db.MustNewPostgresClient(cfg,
+		db.WithMaxConns(cfg.Postgres.MaxConns),
+		db.WithMinConns(cfg.Postgres.MinConns),
+		db.WithTracing(),
+	)
 	defer pgClient.Close()
 
 	redisClient := db.MustNewRedisSentinelClient(cfg)
@@ -34,19 +74,111 @@ func main() {
 	asynqInspector := asynq.NewInspectorFromRedisClient(redisClient)
 	defer asynqInspector.Close()
 
+	asynqClient := asynq.NewClientFromRedisClient(redisClient)
+	defer asynqClient.Close()
+
+	asynqState := &asynqHealth{}
+	taskCtx, cancelTasks := context.WithCancel(context.Background())
+	defer cancelTasks()
+
 	srv := asynq.NewServerFromRedisClient(redisClient, asynq.Config{
-		Concurrency:    6,
-		Queues:         config.QueuePriority,
-		StrictPriority: true,
+		BaseContext:         func() context.Context { return taskCtx },
+		Concurrency:         cfg.Worker.Concurrency,
+		Queues:              cfg.Worker.QueueWeights,
+		StrictPriority:      cfg.Worker.StrictPriority,
+		ShutdownTimeout:     cfg.Worker.ShutdownTimeout,
+		HealthCheckInterval: cfg.Worker.HealthCheckInterval,
+		RetryDelayFunc: func(n int, err error, task *asynq.Task) time.Duration {
+			if task.Type() == config.TaskTypeWebhookDelivery {
+				return webhookuc.RetryDelay(n, cfg.Webhook.RetryBaseDelay, cfg.Webhook.RetryMaxDelay)
+			}
+			return asynq.DefaultRetryDelayFunc(n, err, task)
+		},
+		HealthCheckFunc: func(err error) {
+			asynqState.Record(err)
+			if err != nil {
+				zl.Error("asynq server health check failed", zap.Error(err))
+			}
+		},
 	})
 
 	userRepository := pgrepo.NewUserRepository(pgClient)
+	groupRepository := pgrepo.NewGroupRepository(pgClient)
 	connectorRepository := pgrepo.NewConnectorRepository(pgClient)
+	rejectedRecordRepository := pgrepo.NewRejectedRecordRepository(pgClient)
+	processedFileRepository := pgrepo.NewProcessedFileRepository(pgClient)
+	webhookRepository := pgrepo.NewWebhookRepository(pgClient)
+	rateLimitRepository := redisrepo.NewRateLimitRepository(redisClient)
 	connectorUsecase := connectoruc.NewUseCase(connectorRepository, zl)
-	workerUsecase := workeruc.NewUseCase(asynqInspector, ldapClient, aead, connectorRepository, userRepository, zl)
+	sources := newSources(ldapClient, processedFileRepository, aead, zl)
+	previewUsecase := previewuc.NewUseCase(sources, connectorRepository, zl)
+	webhookUsecase := webhookuc.NewUseCase(webhookRepository, asynqClient, &http.Client{}, aead, cfg.Webhook, zl)
+
+	workerUsecase := workeruc.NewUseCase(
+		asynqInspector,
+		sources,
+		connectorRepository,
+		userRepository,
+		groupRepository,
+		rejectedRecordRepository,
+		rateLimitRepository,
+		zl,
+	)
+
+	healthServer := health.NewServer(cfg.ServerAddress())
+	healthServer.AddCheck("postgres", func(ctx context.Context) error { return pgClient.Pool().Ping(ctx) })
+	healthServer.AddCheck("redis", func(ctx context.Context) error { return redisClient.Ping(ctx).Err() })
+	healthServer.AddCheck("asynq", asynqState.Check)
+	healthServer.Handle("GET /metrics", promhttp.Handler())
+	if cfg.Worker.AdminToken != "" {
+		previewHandler := handler.NewPreviewHandler(previewUsecase, cfg.Worker.AdminToken, zl)
+		mapperHandler := handler.NewMapperHandler(connectorUsecase, cfg.Worker.AdminToken, zl)
+		healthServer.Handle("/admin/", previewHandler.Routes())
+		healthServer.Handle("/admin/connectors/", mapperHandler.Routes())
+	}
+	prometheus.MustRegister(metrics.NewQueueCollector(asynqInspector, slices.Collect(maps.Keys(config.QueuePriority))))
+	if err := healthServer.Start(); err != nil {
+		log.Fatalf("healthServer.Start(): %v", err)
+	}
 
-	mux := NewRouter(cfg, zl, workerUsecase, connectorUsecase)
-	if err := srv.Run(mux); err != nil {
+	mux := NewRouter(cfg, zl, workerUsecase, connectorUsecase, webhookUsecase)
+	if err := srv.Start(mux); err != nil {
 		log.Fatalf("asynq server stopped: %v", err)
 	}
+
+	// events are dispatched apart from the sync tasks, a sync only records them in its transaction
+	go webhookUsecase.Run(ctx)
+
+	<-ctx.Done()
+	zl.Info("shutting down worker", zap.Duration("timeout", cfg.Worker.ShutdownTimeout))
+	srv.Stop()
+
+	// Shutdown returns once asynq has pushed the tasks still running at the shutdown timeout back to
+	// their queue. Their contexts are only cancelled afterward, otherwise asynq would see them failing
+	// and archive them instead of requeueing.
+	srv.Shutdown()
+	cancelTasks()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = healthServer.Shutdown(shutdownCtx)
+	if err != nil {
+		zl.Warn("healthServer.Shutdown()", zap.Error(err))
+	}
+}
+
+func newSources(
+	ldapClient ldapclient.LDAPClient,
+	processedFileRepository *pgrepo.ProcessedFileRepository,
+	aead cipherx.Cipher,
+	zl *logger.ZapLogger,
+) *source.Registry {
+	sources := source.NewRegistry()
+	ldapsource.Register(sources, ldapClient, aead, zl)
+	scimsource.Register(sources, &http.Client{}, aead, zl)
+	filesource.Register(sources, processedFileRepository, zl)
+	sqlsource.Register(sources, aead, zl)
+	httpsource.Register(sources, &http.Client{}, aead, zl)
+	return sources
 }
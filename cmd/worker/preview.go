@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"github.com/tuanta7/qworker/config"
+	"github.com/tuanta7/qworker/internal/domain"
+	pgrepo "github.com/tuanta7/qworker/internal/repository/postgres"
+	"github.com/tuanta7/qworker/internal/usecase/preview"
+	"github.com/tuanta7/qworker/pkg/cipherx"
+	"github.com/tuanta7/qworker/pkg/db"
+	"github.com/tuanta7/qworker/pkg/ldapclient"
+	"github.com/tuanta7/qworker/pkg/logger"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// runPreview prints the preview of a connector mapper as JSON:
+//
+//	worker preview -connector 3 -mapper draft.json -limit 10
+//	worker preview -type ldap -data ldap.json -mapper draft.json
+func runPreview(args []string) {
+	flags := flag.NewFlagSet("preview", flag.ExitOnError)
+	connectorID := flags.Uint64("connector", 0, "id of a stored connector")
+	connectorType := flags.String("type", "", "connector type, when previewing a connector that is not stored")
+	dataFile := flags.String("data", "", "file holding the connector config, secrets encrypted like stored connectors")
+	mapperFile := flags.String("mapper", "", "file holding a draft mapper that replaces the connector one")
+	limit := flags.Int("limit", previewuc.DefaultLimit, "number of entries to fetch")
+	_ = flags.Parse(args)
+
+	req := &domain.PreviewRequest{
+		ConnectorID:   *connectorID,
+		ConnectorType: domain.ConnectorType(*connectorType),
+		Limit:         *limit,
+	}
+	if *dataFile != "" {
+		data, err := os.ReadFile(*dataFile)
+		if err != nil {
+			log.Fatalf("os.ReadFile(): %v", err)
+		}
+		req.Data = data
+	}
+	if *mapperFile != "" {
+		data, err := os.ReadFile(*mapperFile)
+		if err != nil {
+			log.Fatalf("os.ReadFile(): %v", err)
+		}
+		req.Mapper = &domain.Mapper{}
+		if err := json.Unmarshal(data, req.Mapper); err != nil {
+			log.Fatalf("invalid mapper file: %v", err)
+		}
+	}
+
+	cfg := config.InitConfig(nil)
+	aead, err := cipherx.New(cipherx.AEAD, []byte(cfg.AESSecret))
+	if err != nil {
+		panic(err)
+	}
+	zl := logger.MustNewLogger(cfg.Logger.Level)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	pgClient := db.MustNewPostgresClient(cfg, db.WithMaxConns(1), db.WithMinConns(0))
+	defer pgClient.Close()
+
+	connectorRepository := pgrepo.NewConnectorRepository(pgClient)
+	processedFileRepository := pgrepo.NewProcessedFileRepository(pgClient)
+	sources := newSources(ldapclient.NewLDAPClient(cfg.StartTLS.SkipVerify), processedFileRepository, aead, zl)
+
+	result, err := previewuc.NewUseCase(sources, connectorRepository, zl).Preview(ctx, req)
+	if err != nil {
+		log.Fatalf("preview failed: %v", err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(result); err != nil {
+		log.Fatalf("encoder.Encode(): %v", err)
+	}
+}
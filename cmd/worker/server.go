@@ -5,6 +5,7 @@ import (
 	"github.com/tuanta7/qworker/config"
 	"github.com/tuanta7/qworker/internal/handler"
 	"github.com/tuanta7/qworker/internal/usecase/connector"
+	"github.com/tuanta7/qworker/internal/usecase/webhook"
 	"github.com/tuanta7/qworker/internal/usecase/worker"
 	"github.com/tuanta7/qworker/pkg/logger"
 )
@@ -14,12 +15,15 @@ func NewRouter(
 	zl *logger.ZapLogger,
 	workerUC *workeruc.UseCase,
 	connectorUC *connectoruc.UseCase,
+	webhookUC *webhookuc.UseCase,
 ) *asynq.ServeMux {
 	workerHandler := handler.NewWorkerHandler(workerUC, connectorUC, zl)
+	webhookHandler := handler.NewWebhookHandler(webhookUC, zl)
 
 	mux := asynq.NewServeMux()
 	mux.HandleFunc(config.QueueTask[config.QueueIncrementalSync], workerHandler.HandleIncrementalSync)
 	mux.HandleFunc(config.QueueTask[config.QueueFullSync], workerHandler.HandleFullSync)
+	mux.HandleFunc(config.TaskTypeWebhookDelivery, webhookHandler.HandleDelivery)
 
 	return mux
 }
@@ -3,17 +3,22 @@ package config
 const (
 	TaskTypeIncrementalSync = "user:incremental_sync"
 	TaskTypeFullSync        = "user:full_sync"
+	TaskTypeWebhookDelivery = "webhook:delivery"
 
 	QueueIncrementalSync = "inc"
 	QueueFullSync        = "full"
+	QueueWebhook         = "webhook"
 )
 
 var (
+	// QueuePriority lists every queue with its default weight, see WorkerConfig.QueueWeights.
 	QueuePriority = map[string]int{
 		QueueFullSync:        3, // critical
 		QueueIncrementalSync: 1, // default
+		QueueWebhook:         1,
 	}
 
+	// QueueTask lists the sync queues, whose task IDs are connector IDs.
 	QueueTask = map[string]string{
 		QueueIncrementalSync: TaskTypeIncrementalSync,
 		QueueFullSync:        TaskTypeFullSync,
@@ -1,8 +1,10 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/kelseyhightower/envconfig"
@@ -19,6 +21,11 @@ type Config struct {
 	StartTLS   *StartTLSConfig
 	Postgres   *PostgresConfig
 	Redis      *RedisConfig
+	Worker     *WorkerConfig
+	Scheduler  *SchedulerConfig
+	SCIM       *SCIMConfig
+	Webhook    *WebhookConfig
+	Tracing    *TracingConfig
 }
 
 type LoggerConfig struct {
@@ -31,6 +38,8 @@ type RedisConfig struct {
 	MasterName string   `envconfig:"REDIS_MASTER_NAME" default:"mymaster"`
 	Password   string   `envconfig:"REDIS_PASSWORD" default:""`
 	Database   int      `envconfig:"REDIS_DATABASE" default:"0"`
+	PoolSize   int      `envconfig:"REDIS_POOL_SIZE" default:"10"`
+	MinIdle    int      `envconfig:"REDIS_MIN_IDLE_CONNS" default:"3"`
 }
 
 type StartTLSConfig struct {
@@ -43,13 +52,58 @@ type PostgresConfig struct {
 	Username string `envconfig:"POSTGRES_USERNAME" default:"postgres"`
 	Password string `envconfig:"POSTGRES_PASSWORD" default:"password"`
 	Database string `envconfig:"POSTGRES_DATABASE" default:"qworker"`
+	MaxConns int32  `envconfig:"POSTGRES_MAX_CONNS" default:"10"`
+	MinConns int32  `envconfig:"POSTGRES_MIN_CONNS" default:"3"`
+}
+
+type WorkerConfig struct {
+	Concurrency         int            `envconfig:"WORKER_CONCURRENCY" default:"6"`
+	StrictPriority      bool           `envconfig:"WORKER_STRICT_PRIORITY" default:"true"`
+	QueueWeights        map[string]int `envconfig:"WORKER_QUEUE_WEIGHTS" default:"full:3,inc:1,webhook:1"`
+	ShutdownTimeout     time.Duration  `envconfig:"WORKER_SHUTDOWN_TIMEOUT" default:"30s"`
+	HealthCheckInterval time.Duration  `envconfig:"WORKER_HEALTH_CHECK_INTERVAL" default:"15s"`
+	// AdminToken enables the admin endpoints of the worker server, such as mapper previews.
+	AdminToken string `envconfig:"WORKER_ADMIN_TOKEN" default:""`
+}
+
+type SchedulerConfig struct {
+	ShutdownTimeout time.Duration `envconfig:"SCHEDULER_SHUTDOWN_TIMEOUT" default:"30s"`
+}
+
+type SCIMConfig struct {
+	ShutdownTimeout time.Duration `envconfig:"SCIM_SHUTDOWN_TIMEOUT" default:"15s"`
+	MaxPageSize     uint64        `envconfig:"SCIM_MAX_PAGE_SIZE" default:"200"`
+}
+
+// WebhookConfig paces the worker dispatching user events to webhook subscriptions. A failed delivery is
+// retried after RetryBaseDelay, doubled on each attempt up to RetryMaxDelay.
+type WebhookConfig struct {
+	DispatchInterval time.Duration `envconfig:"WEBHOOK_DISPATCH_INTERVAL" default:"5s"`
+	BatchSize        uint64        `envconfig:"WEBHOOK_BATCH_SIZE" default:"100"`
+	Timeout          time.Duration `envconfig:"WEBHOOK_TIMEOUT" default:"10s"`
+	MaxRetry         int           `envconfig:"WEBHOOK_MAX_RETRY" default:"10"`
+	RetryBaseDelay   time.Duration `envconfig:"WEBHOOK_RETRY_BASE_DELAY" default:"10s"`
+	RetryMaxDelay    time.Duration `envconfig:"WEBHOOK_RETRY_MAX_DELAY" default:"1h"`
+}
+
+type TracingConfig struct {
+	Exporter    string  `envconfig:"TRACING_EXPORTER" default:"none"` // none, stdout or otlp
+	Endpoint    string  `envconfig:"TRACING_OTLP_ENDPOINT" default:"localhost:4318"`
+	Insecure    bool    `envconfig:"TRACING_OTLP_INSECURE" default:"true"`
+	SampleRatio float64 `envconfig:"TRACING_SAMPLE_RATIO" default:"1"`
 }
 
 func (p PostgresConfig) GetConnectionString() string {
 	return fmt.Sprintf("postgresql://%s:%s@%s:%d/%s", p.Username, p.Password, p.Host, p.Port, p.Database)
 }
 
-func InitConfig() *Config {
+func (c *Config) ServerAddress() string {
+	return fmt.Sprintf("%s:%d", c.ServerHost, c.ServerPort)
+}
+
+// InitConfig loads the config and checks the settings shared by every binary, then those of the binary
+// itself with validate, one of the Config.Validate* methods or nil.
+func InitConfig(validate func(*Config) error) *Config {
 	config := &Config{}
 
 	err := godotenv.Load()
@@ -57,10 +111,125 @@ func InitConfig() *Config {
 		log.Printf("[Warning] config - init - godotenv.Load: %v", err)
 	}
 
-	err = envconfig.Process(envPrefix, config)
+	err = config.process()
+	if err != nil {
+		log.Fatalf("config - init - config.process: %v", err)
+	}
+
+	err = config.Validate()
+	if err == nil && validate != nil {
+		err = validate(config)
+	}
 	if err != nil {
-		log.Fatalf("config - init - envconfig.Process: %v", err)
+		log.Fatalf("config - init - config.Validate: %v", err)
 	}
 
 	return config
 }
+
+// process reads the environment. Queues missing from WORKER_QUEUE_WEIGHTS take their QueuePriority weight,
+// so that a weight list written before a queue was added keeps working.
+func (c *Config) process() error {
+	err := envconfig.Process(envPrefix, c)
+	if err != nil {
+		return err
+	}
+
+	for queue, weight := range QueuePriority {
+		if _, ok := c.Worker.QueueWeights[queue]; !ok {
+			c.Worker.QueueWeights[queue] = weight
+		}
+	}
+	return nil
+}
+
+// Validate rejects shared settings that would make a binary misbehave at runtime rather than at startup.
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.Postgres.MaxConns <= 0 {
+		errs = append(errs, errors.New("postgres max conns must be positive"))
+	}
+	if c.Postgres.MinConns < 0 || c.Postgres.MinConns > c.Postgres.MaxConns {
+		errs = append(errs, fmt.Errorf("postgres min conns must be between 0 and %d", c.Postgres.MaxConns))
+	}
+
+	if c.Redis.PoolSize <= 0 {
+		errs = append(errs, errors.New("redis pool size must be positive"))
+	}
+	if c.Redis.MinIdle < 0 || c.Redis.MinIdle > c.Redis.PoolSize {
+		errs = append(errs, fmt.Errorf("redis min idle conns must be between 0 and %d", c.Redis.PoolSize))
+	}
+
+	switch c.Tracing.Exporter {
+	case "none", "stdout", "otlp":
+	default:
+		errs = append(errs, fmt.Errorf("unknown tracing exporter %q", c.Tracing.Exporter))
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		errs = append(errs, errors.New("tracing sample ratio must be between 0 and 1"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// ValidateWorker checks the settings of the worker, which also dispatches the webhooks.
+func (c *Config) ValidateWorker() error {
+	var errs []error
+
+	w := c.Worker
+	if w.Concurrency <= 0 {
+		errs = append(errs, errors.New("worker concurrency must be positive"))
+	}
+	// every running task holds a database connection while it upserts users
+	if int32(w.Concurrency) > c.Postgres.MaxConns {
+		errs = append(errs, fmt.Errorf("worker concurrency %d exceeds postgres max conns %d", w.Concurrency, c.Postgres.MaxConns))
+	}
+	if w.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("worker shutdown timeout must be positive"))
+	}
+	if w.HealthCheckInterval <= 0 {
+		errs = append(errs, errors.New("worker health check interval must be positive"))
+	}
+
+	if c.Webhook.DispatchInterval <= 0 || c.Webhook.Timeout <= 0 || c.Webhook.BatchSize == 0 {
+		errs = append(errs, errors.New("webhook dispatch interval, timeout and batch size must be positive"))
+	}
+	if c.Webhook.MaxRetry < 0 {
+		errs = append(errs, errors.New("webhook max retry must not be negative"))
+	}
+	if c.Webhook.RetryBaseDelay <= 0 || c.Webhook.RetryMaxDelay < c.Webhook.RetryBaseDelay {
+		errs = append(errs, errors.New("webhook retry delays must be positive, the max not below the base"))
+	}
+
+	for queue := range QueuePriority {
+		if w.QueueWeights[queue] <= 0 {
+			errs = append(errs, fmt.Errorf("worker queue weight of %q must be positive", queue))
+		}
+	}
+	for queue := range w.QueueWeights {
+		if _, ok := QueuePriority[queue]; !ok {
+			errs = append(errs, fmt.Errorf("unknown worker queue %q", queue))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (c *Config) ValidateScheduler() error {
+	if c.Scheduler.ShutdownTimeout <= 0 {
+		return errors.New("scheduler shutdown timeout must be positive")
+	}
+	return nil
+}
+
+func (c *Config) ValidateSCIM() error {
+	var errs []error
+	if c.SCIM.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("scim shutdown timeout must be positive"))
+	}
+	if c.SCIM.MaxPageSize == 0 {
+		errs = append(errs, errors.New("scim max page size must be positive"))
+	}
+	return errors.Join(errs...)
+}
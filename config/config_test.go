@@ -0,0 +1,28 @@
+package config
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestQueueWeights(t *testing.T) {
+	t.Run("missing_queue", func(t *testing.T) {
+		// the weights of a deployment from before the webhook queue
+		t.Setenv("WORKER_QUEUE_WEIGHTS", "full:3,inc:1")
+
+		c := &Config{}
+		assert.NoError(t, c.process())
+		assert.Equal(t, map[string]int{QueueFullSync: 3, QueueIncrementalSync: 1, QueueWebhook: 1}, c.Worker.QueueWeights)
+		assert.NoError(t, c.ValidateWorker())
+	})
+
+	t.Run("invalid_weight", func(t *testing.T) {
+		t.Setenv("WORKER_QUEUE_WEIGHTS", "full:3,inc:0,archive:1")
+
+		c := &Config{}
+		assert.NoError(t, c.process())
+		err := c.ValidateWorker()
+		assert.ErrorContains(t, err, `worker queue weight of "inc" must be positive`)
+		assert.ErrorContains(t, err, `unknown worker queue "archive"`)
+	})
+}
@@ -11,6 +11,9 @@ type ConnectorType string
 const (
 	ConnectorTypeLDAP ConnectorType = "ldap"
 	ConnectorTypeSCIM ConnectorType = "scim"
+	ConnectorTypeFile ConnectorType = "file"
+	ConnectorTypeSQL  ConnectorType = "sql"
+	ConnectorTypeHTTP ConnectorType = "http"
 )
 
 type Connector struct {
@@ -19,6 +22,7 @@ type Connector struct {
 	DisplayName   string         `json:"displayName"`
 	LastSync      time.Time      `json:"lastSync"`
 	Enabled       bool           `json:"enabled"`
+	Priority      int            `json:"priority"` // takes precedence over lower priorities on shared users
 	Data          sqlxx.TextData `json:"data"`
 	Mapper        Mapper         `json:"mapper,omitempty"`
 	CreatedAt     time.Time      `json:"createdAt"`
@@ -39,23 +43,174 @@ func (c *Connector) GetSyncSettings() (*SyncSettings, error) {
 }
 
 type LDAPConnector struct {
-	URL                   string        `json:"url"`
-	ConnectTimeout        time.Duration `json:"connectTimeout"`
-	ReadTimeout           time.Duration `json:"readTimeout"`
-	SystemAccountDN       string        `json:"systemAccountDn"`
-	SystemAccountPassword string        `json:"systemAccountPassword"`
-	UsernameAttribute     string        `json:"usernameAttribute"`
-	BaseDN                string        `json:"baseDn"`
-	SyncSettings          SyncSettings  `json:"syncSettings"`
+	URL                   string           `json:"url"`
+	ConnectTimeout        time.Duration    `json:"connectTimeout"`
+	ReadTimeout           time.Duration    `json:"readTimeout"`
+	SystemAccountDN       string           `json:"systemAccountDn"`
+	SystemAccountPassword string           `json:"systemAccountPassword"`
+	UsernameAttribute     string           `json:"usernameAttribute"`
+	BaseDN                string           `json:"baseDn"` // searched one level deep when SearchBases is empty
+	SearchBases           []LDAPSearchBase `json:"searchBases"`
+	UserFilter            string           `json:"userFilter"` // required, such as (&(objectCategory=person)(objectClass=user))
+	ExcludeDNs            []string         `json:"excludeDns"` // entries equal to or under these DNs are skipped
+	Groups                LDAPGroups       `json:"groups"`
+	SyncSettings          SyncSettings     `json:"syncSettings"`
 }
 
+type LDAPScope string
+
+const (
+	LDAPScopeBase    LDAPScope = "base"
+	LDAPScopeOne     LDAPScope = "one"
+	LDAPScopeSubtree LDAPScope = "subtree"
+)
+
+// LDAPSearchBase is a DN the users are searched under, with the subtree scope by default.
+type LDAPSearchBase struct {
+	DN    string    `json:"dn"`
+	Scope LDAPScope `json:"scope"`
+}
+
+type LDAPNestedGroups string
+
+const (
+	LDAPNestedGroupsNone    LDAPNestedGroups = ""
+	LDAPNestedGroupsInChain LDAPNestedGroups = "in_chain" // Active Directory LDAP_MATCHING_RULE_IN_CHAIN
+	LDAPNestedGroupsExpand  LDAPNestedGroups = "expand"   // expanded from the stored direct memberships
+)
+
+// LDAPGroups configures the group pass of an LDAP connector, which runs after the users.
+type LDAPGroups struct {
+	Enabled              bool             `json:"enabled"`
+	BaseDN               string           `json:"baseDn"`               // defaults to the connector BaseDN or every search base, searched with subtree scope
+	Filter               string           `json:"filter"`               // defaults to the group, groupOfNames and groupOfUniqueNames classes
+	NameAttribute        string           `json:"nameAttribute"`        // defaults to cn
+	DescriptionAttribute string           `json:"descriptionAttribute"` // defaults to description
+	MemberAttribute      string           `json:"memberAttribute"`      // defaults to member
+	MemberOfAttribute    string           `json:"memberOfAttribute"`    // when set, members are the users holding the group DN in it
+	Nested               LDAPNestedGroups `json:"nested"`
+}
+
+type SCIMAuthType string
+
+const (
+	SCIMAuthBearer SCIMAuthType = "bearer"
+	SCIMAuthBasic  SCIMAuthType = "basic"
+)
+
 type SCIMConnector struct {
-	BaseURL      string       `json:"baseUrl"`
+	BaseURL      string        `json:"baseUrl"`
+	AuthType     SCIMAuthType  `json:"authType"`
+	Token        string        `json:"token"` // encrypted, bearer auth
+	Username     string        `json:"username"`
+	Password     string        `json:"password"`     // encrypted, basic auth
+	Timeout      time.Duration `json:"timeout"`      // milliseconds
+	InboundToken string        `json:"inboundToken"` // encrypted, authenticates pushes to cmd/scim
+	SyncSettings SyncSettings  `json:"syncSettings"`
+}
+
+type FileFormat string
+
+const (
+	FileFormatCSV   FileFormat = "csv"
+	FileFormatJSON  FileFormat = "json"  // a single array of objects
+	FileFormatJSONL FileFormat = "jsonl" // one object per line
+)
+
+// FileConnector imports the exports dropped in a local directory. Path is either a file or a directory
+// whose files matching Pattern are imported, oldest first.
+type FileConnector struct {
+	Path         string       `json:"path"`
+	Pattern      string       `json:"pattern"`   // glob, defaults to the extension of Format
+	Format       FileFormat   `json:"format"`    // inferred from the file extension when empty
+	Delimiter    string       `json:"delimiter"` // csv only, defaults to a comma
+	FailedDir    string       `json:"failedDir"` // defaults to "failed" next to the imported files
 	SyncSettings SyncSettings `json:"syncSettings"`
 }
 
+type SQLDriver string
+
+const (
+	SQLDriverPostgres SQLDriver = "postgres"
+	SQLDriverMySQL    SQLDriver = "mysql"
+)
+
+// SQLConnector pulls users with a query, which may reference the incremental sync watermark as :since.
+// Pages are fetched by keyset on KeyColumn, a unique column of the query result.
+type SQLConnector struct {
+	Driver       SQLDriver     `json:"driver"`
+	DSN          string        `json:"dsn"`      // without the password
+	Password     string        `json:"password"` // encrypted
+	Query        string        `json:"query"`
+	KeyColumn    string        `json:"keyColumn"`
+	Timeout      time.Duration `json:"timeout"` // milliseconds, per page
+	SyncSettings SyncSettings  `json:"syncSettings"`
+}
+
+type HTTPAuthType string
+
+const (
+	HTTPAuthNone   HTTPAuthType = ""
+	HTTPAuthBearer HTTPAuthType = "bearer"
+	HTTPAuthBasic  HTTPAuthType = "basic"
+	HTTPAuthHeader HTTPAuthType = "header" // API key sent in HeaderName
+)
+
+type HTTPAuth struct {
+	Type       HTTPAuthType `json:"type"`
+	Token      string       `json:"token"` // encrypted, bearer and header auth
+	HeaderName string       `json:"headerName"`
+	Username   string       `json:"username"`
+	Password   string       `json:"password"` // encrypted, basic auth
+}
+
+type HTTPPaginationType string
+
+const (
+	HTTPPaginationNone   HTTPPaginationType = ""
+	HTTPPaginationPage   HTTPPaginationType = "page"
+	HTTPPaginationOffset HTTPPaginationType = "offset"
+	HTTPPaginationCursor HTTPPaginationType = "cursor"
+	HTTPPaginationLink   HTTPPaginationType = "link" // rel="next" of the Link header
+)
+
+type HTTPPagination struct {
+	Type        HTTPPaginationType `json:"type"`
+	PageParam   string             `json:"pageParam"`   // page, defaults to "page"
+	StartPage   int                `json:"startPage"`   // page, defaults to 1
+	OffsetParam string             `json:"offsetParam"` // offset, defaults to "offset"
+	SizeParam   string             `json:"sizeParam"`   // receives BatchSize, if set
+	CursorParam string             `json:"cursorParam"` // cursor, defaults to "cursor"
+	CursorPath  string             `json:"cursorPath"`  // cursor, JSONPath of the next cursor in the response
+	MaxPages    int                `json:"maxPages"`    // fails the sync past this many pages, 0 for no limit
+}
+
+// HTTPConnector pulls users from a JSON REST API. RecordsPath selects the user objects of a response
+// and the connector mapper holds JSONPaths relative to a user object.
+type HTTPConnector struct {
+	URL          string            `json:"url"`
+	Headers      map[string]string `json:"headers"`
+	Query        map[string]string `json:"query"`
+	Auth         HTTPAuth          `json:"auth"`
+	Pagination   HTTPPagination    `json:"pagination"`
+	RecordsPath  string            `json:"recordsPath"` // defaults to the response itself
+	SinceParam   string            `json:"sinceParam"`  // receives the watermark on incremental syncs
+	SinceFormat  string            `json:"sinceFormat"` // Go time layout or "unix", defaults to RFC 3339
+	Timeout      time.Duration     `json:"timeout"`     // milliseconds
+	SyncSettings SyncSettings      `json:"syncSettings"`
+}
+
 type SyncSettings struct {
 	BatchSize     uint32        `json:"batchSize"`
 	IncSync       bool          `json:"incrementalSyncEnabled"`
 	IncSyncPeriod time.Duration `json:"incrementalSyncPeriod"`
+	RateLimit     RateLimit     `json:"rateLimit"`
+}
+
+// RateLimit protects fragile source directories. Limits are shared by all workers
+// through Redis, zero values mean unlimited.
+type RateLimit struct {
+	MaxPagesPerSecond     uint32        `json:"maxPagesPerSecond"`
+	MaxConcurrentSearches uint32        `json:"maxConcurrentSearches"`
+	PageInterval          time.Duration `json:"pageInterval"` // milliseconds
 }
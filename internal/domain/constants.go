@@ -11,6 +11,7 @@ const (
 	ColDisplayName   = "display_name"
 	ColEnabled       = "enabled"
 	ColLastSync      = "last_sync"
+	ColPriority      = "priority"
 
 	TableUser        string = "private.user"
 	ColUserID        string = "id"
@@ -21,6 +22,67 @@ const (
 	ColEmailVerified string = "email_verified"
 	ColActive        string = "active"
 	ColSourceID      string = "source_id"
+	ColSourceDN      string = "source_dn"
+
+	ColAttributeSources string = "attribute_sources"
+	ColSourceHashes     string = "source_hashes"
+	ColSourceDNs        string = "source_dns"
+
+	TableUserIdentity string = "private.user_identity"
+	ColExternalID     string = "external_id"
+	ColIdentityUserID string = "user_id"
+
+	TableSyncRejectedRecord string = "private.sync_rejected_record"
+	ColDN                   string = "dn"
+	ColReason               string = "reason"
+	ColRawAttributes        string = "raw_attributes"
+
+	TableProcessedFile string = "private.processed_file"
+	ColChecksum        string = "checksum"
+	ColFileName        string = "file_name"
+	ColRecordCount     string = "record_count"
+	ColProcessedAt     string = "processed_at"
+
+	TableGroup     string = "private.group"
+	ColGroupID     string = "id"
+	ColGroupName   string = "name"
+	ColDescription string = "description"
+
+	TableGroupMember string = "private.group_member"
+	ColMemberGroupID string = "group_id"
+	ColMemberDN      string = "member_dn"
+	ColMemberUserID  string = "user_id"
+	ColNested        string = "nested"
+
+	TableMapper          string = "private.mapper"
+	ColMapperConnectorID string = "connector_id"
+
+	TableIdentityConflict string = "private.identity_conflict"
+	ColConflictUserID     string = "user_id"
+	ColAttribute          string = "attribute"
+	ColCurrentSourceID    string = "current_source_id"
+	ColWinnerID           string = "winner_id"
+
+	TableWebhookSubscription string = "private.webhook_subscription"
+	ColSubscriptionID        string = "id"
+	ColURL                   string = "url"
+	ColSecret                string = "secret"
+	ColEvents                string = "events"
+
+	TableUserEvent  string = "private.user_event"
+	ColEventID      string = "id"
+	ColEventUserID  string = "user_id"
+	ColEventType    string = "event_type"
+	ColPayload      string = "payload"
+	ColDispatchedAt string = "dispatched_at"
+
+	TableWebhookDelivery      string = "private.webhook_delivery"
+	ColDeliverySubscriptionID string = "subscription_id"
+	ColDeliveryEventID        string = "event_id"
+	ColAttempt                string = "attempt"
+	ColStatusCode             string = "status_code"
+	ColError                  string = "error"
+	ColDurationMs             string = "duration_ms"
 )
 
 var (
@@ -30,6 +92,7 @@ var (
 		ColDisplayName,
 		ColEnabled,
 		ColLastSync,
+		ColPriority,
 		ColData,
 		ColCreatedAt,
 		ColUpdatedAt,
@@ -44,6 +107,7 @@ var (
 		ColEmailVerified,
 		ColActive,
 		ColSourceID,
+		ColSourceDN,
 		ColData,
 		ColCreatedAt,
 		ColUpdatedAt,
@@ -55,8 +119,64 @@ var (
 		ColFullName,
 		ColPhoneNumber,
 		ColEmail,
+		ColEmailVerified,
+		ColActive,
 		ColSourceID,
+		ColSourceDN,
+		ColData,
+		ColCreatedAt,
+		ColUpdatedAt,
+	}
+
+	// AttributeUserCols are resolved one by one between the connectors writing the same user, by
+	// private.attribute_owner then by connector priority.
+	AttributeUserCols = []string{
+		ColFullName,
+		ColPhoneNumber,
+		ColEmail,
+		ColActive,
 		ColData,
+	}
+
+	AllSyncRejectedRecordCols = []string{
+		ColSourceID,
+		ColDN,
+		ColReason,
+		ColRawAttributes,
+		ColCreatedAt,
+	}
+
+	AllProcessedFileCols = []string{
+		ColSourceID,
+		ColChecksum,
+		ColFileName,
+		ColRecordCount,
+		ColProcessedAt,
+	}
+
+	AllWebhookSubscriptionCols = []string{
+		ColSubscriptionID,
+		ColURL,
+		ColSecret,
+		ColEvents,
+		ColEnabled,
+		ColCreatedAt,
+		ColUpdatedAt,
+	}
+
+	AllUserEventCols = []string{
+		ColEventID,
+		ColEventUserID,
+		ColEventType,
+		ColPayload,
+		ColCreatedAt,
+	}
+
+	AllGroupSyncCols = []string{
+		ColSourceID,
+		ColDN,
+		ColGroupName,
+		ColDescription,
 		ColCreatedAt,
 		ColUpdatedAt,
 	}
@@ -0,0 +1,63 @@
+package domain
+
+import (
+	"fmt"
+	"github.com/tuanta7/qworker/pkg/utils"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Column limits of private.group, see migrations/postgres.
+const (
+	MaxGroupNameLength        = 255
+	MaxGroupDescriptionLength = 1000
+)
+
+// Group is a group read from a source, it is identified by its DN within the source.
+type Group struct {
+	GroupID     uint64    `json:"id"`
+	SourceID    uint64    `json:"sourceID"`
+	DN          string    `json:"dn"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// GroupMember links a group to a member DN, a user or a group of the same source. Nested marks a membership
+// inherited through a member group.
+type GroupMember struct {
+	GroupDN  string `json:"groupDN"`
+	MemberDN string `json:"memberDN"`
+	Nested   bool   `json:"nested"`
+}
+
+func (g *Group) Validate() error {
+	fields := []struct {
+		name   string
+		value  string
+		maxLen int
+		err    error
+	}{
+		{ColDN, g.DN, MaxDNLength, utils.ErrDNTooLong},
+		{ColGroupName, g.Name, MaxGroupNameLength, utils.ErrGroupNameTooLong},
+		{ColDescription, g.Description, MaxGroupDescriptionLength, utils.ErrGroupDescriptionTooLong},
+	}
+
+	if strings.TrimSpace(g.Name) == "" {
+		return utils.ErrGroupNameRequired
+	}
+
+	for _, f := range fields {
+		if !utf8.ValidString(f.value) || strings.ContainsRune(f.value, 0) {
+			return fmt.Errorf("%s: %w", f.name, utils.ErrInvalidTextEncoding)
+		}
+
+		if utf8.RuneCountInString(f.value) > f.maxLen {
+			return f.err
+		}
+	}
+
+	return nil
+}
@@ -3,10 +3,35 @@ package domain
 import (
 	"database/sql/driver"
 	"encoding/json"
+	"errors"
 	"reflect"
 )
 
+// Mapping names of the user fields, custom mappings use their own key.
+const (
+	MappingExternalID  = "external_id"
+	MappingUsername    = "username"
+	MappingFullName    = "full_name"
+	MappingEmail       = "email"
+	MappingPhoneNumber = "phone_number"
+	MappingCreatedAt   = "created_at"
+	MappingUpdatedAt   = "updated_at"
+)
+
+// AttributeType is the type hint of a custom attribute, suffixed with [] for multi-valued attributes.
+type AttributeType string
+
+const (
+	AttributeString AttributeType = "string"
+	AttributeInt    AttributeType = "int"
+	AttributeBool   AttributeType = "bool"
+	AttributeTime   AttributeType = "time"   // stored as RFC 3339 in UTC
+	AttributeBinary AttributeType = "binary" // stored as base64
+)
+
 type Mapper struct {
+	// Preset fills the fields left empty, see MapperPresets.
+	Preset      MapperPreset      `json:"preset,omitempty"`
 	ExternalID  string            `json:"external_id"`
 	Username    string            `json:"username"`
 	FullName    string            `json:"full_name"`
@@ -15,6 +40,13 @@ type Mapper struct {
 	CreatedAt   string            `json:"created_at"`
 	UpdatedAt   string            `json:"updated_at"`
 	Custom      map[string]string `json:"custom"`
+	// CustomTypes holds the type hints of the Custom keys. Without a hint, an attribute is stored as a
+	// string, or as an array of strings when the source returns several values.
+	CustomTypes map[string]AttributeType `json:"custom_types,omitempty"`
+	// Lookups holds the tables of the lookup() mapping function, by name.
+	Lookups map[string]map[string]string `json:"lookups,omitempty"`
+	// Status derives the active and email verified flags from the account attributes.
+	Status *StatusRules `json:"status,omitempty"`
 }
 
 func (m *Mapper) Scan(v any) error {
@@ -45,9 +77,48 @@ func (m *Mapper) Scan(v any) error {
 }
 
 func (m *Mapper) Value() (driver.Value, error) {
-	return json.Marshal(m)
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
 }
 
+// IsZero reports whether no mapping is set, like a connector without a stored mapper.
+func (m *Mapper) IsZero() bool {
+	return reflect.ValueOf(*m).IsZero()
+}
+
+// ToMap returns every mapping by name, the fields under their mapping name. A custom mapping named after
+// a field overrides it, and fields left empty are left out.
 func (m *Mapper) ToMap() (map[string]string, error) {
-	return nil, nil
+	mappings := make(map[string]string, 7+len(m.Custom))
+	fields := []struct {
+		name  string
+		value string
+	}{
+		{MappingExternalID, m.ExternalID},
+		{MappingUsername, m.Username},
+		{MappingFullName, m.FullName},
+		{MappingEmail, m.Email},
+		{MappingPhoneNumber, m.PhoneNumber},
+		{MappingCreatedAt, m.CreatedAt},
+		{MappingUpdatedAt, m.UpdatedAt},
+	}
+	for _, f := range fields {
+		if f.value != "" {
+			mappings[f.name] = f.value
+		}
+	}
+
+	for name, value := range m.Custom {
+		if name == "" {
+			return nil, errors.New("custom mapping with an empty name")
+		}
+		if value != "" {
+			mappings[name] = value
+		}
+	}
+
+	return mappings, nil
 }
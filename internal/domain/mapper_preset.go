@@ -0,0 +1,128 @@
+package domain
+
+import "fmt"
+
+// MapperPreset names the default mapper of a directory vendor. The fields set in a connector mapper
+// override the preset ones.
+type MapperPreset string
+
+const (
+	MapperPresetActiveDirectory MapperPreset = "active_directory"
+	MapperPresetOpenLDAP        MapperPreset = "openldap"
+	MapperPresetFreeIPA         MapperPreset = "freeipa"
+)
+
+var MapperPresets = map[MapperPreset]Mapper{
+	// userAccountControl has the ACCOUNTDISABLE bit on disabled accounts, accountExpires is a FILETIME
+	MapperPresetActiveDirectory: {
+		ExternalID:  "objectGUID",
+		Username:    "sAMAccountName",
+		FullName:    "cn",
+		PhoneNumber: "mobile",
+		Email:       "mail",
+		CreatedAt:   "whenCreated",
+		UpdatedAt:   "whenChanged",
+		Status: &StatusRules{
+			Active: []StatusRule{
+				{Attribute: "userAccountControl", Check: StatusCheckBitsClear, Value: "2"},
+				{Attribute: "accountExpires", Check: StatusCheckNotExpired, Format: StatusTimeFileTime},
+			},
+		},
+	},
+	// the ppolicy overlay sets pwdAccountLockedTime on locked accounts
+	MapperPresetOpenLDAP: {
+		ExternalID:  "entryUUID",
+		Username:    "uid",
+		FullName:    "cn",
+		PhoneNumber: "mobile",
+		Email:       "mail",
+		CreatedAt:   "createTimestamp",
+		UpdatedAt:   "modifyTimestamp",
+		Status: &StatusRules{
+			Active: []StatusRule{
+				{Attribute: "pwdAccountLockedTime", Check: StatusCheckAbsent},
+			},
+		},
+	},
+	// nsAccountLock is TRUE on disabled accounts, krbPrincipalExpiration is a generalized time
+	MapperPresetFreeIPA: {
+		ExternalID:  "ipaUniqueID",
+		Username:    "uid",
+		FullName:    "cn",
+		PhoneNumber: "mobile",
+		Email:       "mail",
+		CreatedAt:   "createTimestamp",
+		UpdatedAt:   "modifyTimestamp",
+		Status: &StatusRules{
+			Active: []StatusRule{
+				{Attribute: "nsAccountLock", Check: StatusCheckNotEquals, Value: "TRUE"},
+				{Attribute: "krbPrincipalExpiration", Check: StatusCheckNotExpired, Format: StatusTimeGeneralized},
+			},
+		},
+	},
+}
+
+// DefaultMapperPreset is the preset of the connectors that have no mapper. LDAP connectors default to
+// Active Directory, other types fall back to the defaults of their source.
+func DefaultMapperPreset(t ConnectorType) MapperPreset {
+	if t == ConnectorTypeLDAP {
+		return MapperPresetActiveDirectory
+	}
+	return ""
+}
+
+// WithPreset fills the fields left empty in the mapper from its preset.
+func (m Mapper) WithPreset() (Mapper, error) {
+	if m.Preset == "" {
+		return m, nil
+	}
+
+	preset, ok := MapperPresets[m.Preset]
+	if !ok {
+		return Mapper{}, fmt.Errorf("unknown mapper preset: %q", m.Preset)
+	}
+
+	fields := []struct {
+		value    *string
+		fallback string
+	}{
+		{&m.ExternalID, preset.ExternalID},
+		{&m.Username, preset.Username},
+		{&m.FullName, preset.FullName},
+		{&m.Email, preset.Email},
+		{&m.PhoneNumber, preset.PhoneNumber},
+		{&m.CreatedAt, preset.CreatedAt},
+		{&m.UpdatedAt, preset.UpdatedAt},
+	}
+	for _, f := range fields {
+		if *f.value == "" {
+			*f.value = f.fallback
+		}
+	}
+
+	// an active mapping of the connector takes over the active rules of the preset
+	if preset.Status != nil {
+		status := StatusRules{}
+		if m.Status != nil {
+			status = *m.Status
+		}
+		if _, ok := m.Custom[StatusActive]; !ok && len(status.Active) == 0 {
+			status.Active = preset.Status.Active
+		}
+		if len(status.EmailVerified) == 0 {
+			status.EmailVerified = preset.Status.EmailVerified
+		}
+		m.Status = &status
+	}
+
+	custom := make(map[string]string, len(preset.Custom)+len(m.Custom))
+	for k, v := range preset.Custom {
+		custom[k] = v
+	}
+	for k, v := range m.Custom {
+		custom[k] = v
+	}
+	m.Custom = custom
+
+	return m, nil
+}
@@ -50,3 +50,68 @@ func TestMapperScan(t *testing.T) {
 		assert.Equal(t, "sn", m.Custom["lastName"])
 	})
 }
+
+func TestMapperToMap(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		m := &Mapper{
+			ExternalID: "uid",
+			Username:   "uid",
+			FullName:   "cn",
+			Custom: map[string]string{
+				"lastName": "sn",
+				"username": "=lower(sAMAccountName)", // override
+			},
+		}
+
+		mappings, err := m.ToMap()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, map[string]string{
+			"external_id": "uid",
+			"username":    "=lower(sAMAccountName)",
+			"full_name":   "cn",
+			"lastName":    "sn",
+		}, mappings)
+	})
+
+	t.Run("empty_custom_name", func(t *testing.T) {
+		m := &Mapper{Custom: map[string]string{"": "sn"}}
+		_, err := m.ToMap()
+		assert.EqualError(t, err, "custom mapping with an empty name")
+	})
+}
+
+func TestMapperWithPreset(t *testing.T) {
+	t.Run("override", func(t *testing.T) {
+		m, err := Mapper{
+			Preset:   MapperPresetOpenLDAP,
+			Username: "mail",
+			Custom:   map[string]string{"title": "title"},
+		}.WithPreset()
+		assert.Equal(t, nil, err)
+		assert.Equal(t, "mail", m.Username)
+		assert.Equal(t, "modifyTimestamp", m.UpdatedAt)
+		assert.Equal(t, map[string]string{"title": "title"}, m.Custom)
+		assert.Equal(t, []StatusRule{{Attribute: "pwdAccountLockedTime", Check: StatusCheckAbsent}}, m.Status.Active)
+	})
+
+	t.Run("active_mapping", func(t *testing.T) {
+		m, err := Mapper{
+			Preset: MapperPresetActiveDirectory,
+			Custom: map[string]string{StatusActive: "=eq(enabled, \"yes\")"},
+			Status: &StatusRules{EmailVerified: []StatusRule{{Attribute: "mail", Check: StatusCheckPresent}}},
+		}.WithPreset()
+		assert.Equal(t, nil, err)
+		assert.Empty(t, m.Status.Active)
+		assert.Equal(t, []StatusRule{{Attribute: "mail", Check: StatusCheckPresent}}, m.Status.EmailVerified)
+	})
+
+	t.Run("unknown_preset", func(t *testing.T) {
+		_, err := Mapper{Preset: "novell"}.WithPreset()
+		assert.EqualError(t, err, `unknown mapper preset: "novell"`)
+	})
+
+	t.Run("default_preset", func(t *testing.T) {
+		assert.Equal(t, MapperPresetActiveDirectory, DefaultMapperPreset(ConnectorTypeLDAP))
+		assert.Equal(t, MapperPreset(""), DefaultMapperPreset(ConnectorTypeSQL))
+	})
+}
@@ -2,9 +2,25 @@ package domain
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/tuanta7/qworker/pkg/utils"
 	"time"
 )
 
+// QueueMessageVersion is the payload version produced by this build. Version 0 is the legacy payload
+// that only carried the connector ID and the task type, it is still understood by workers.
+const QueueMessageVersion = 1
+
+type TriggerSource string
+
+const (
+	TriggerCron    TriggerSource = "cron"
+	TriggerManual  TriggerSource = "manual"
+	TriggerAPI     TriggerSource = "api"
+	TriggerCatchUp TriggerSource = "catch_up"
+)
+
 type NotifyMessage struct {
 	Table  string `json:"table"`
 	Action string `json:"action"`
@@ -12,8 +28,45 @@ type NotifyMessage struct {
 }
 
 type QueueMessage struct {
-	ConnectorID uint64 `json:"connector_id"`
-	TaskType    string `json:"task_type"`
+	Version       int               `json:"version"`
+	ConnectorID   uint64            `json:"connector_id"`
+	TaskType      string            `json:"task_type"`
+	Trigger       TriggerSource     `json:"trigger,omitempty"`
+	RequestedBy   string            `json:"requested_by,omitempty"`
+	CorrelationID string            `json:"correlation_id,omitempty"`
+	Since         *time.Time        `json:"since,omitempty"` // overrides the connector last sync for incremental sync
+	DryRun        bool              `json:"dry_run,omitempty"`
+	ScheduledAt   time.Time         `json:"scheduled_at"`
+	TraceContext  map[string]string `json:"trace_context,omitempty"`
+}
+
+func NewQueueMessage(connectorID uint64, taskType string, trigger TriggerSource) *QueueMessage {
+	return &QueueMessage{
+		Version:     QueueMessageVersion,
+		ConnectorID: connectorID,
+		TaskType:    taskType,
+		Trigger:     trigger,
+	}
+}
+
+// DecodeQueueMessage parses a task payload and rejects versions this worker does not understand.
+func DecodeQueueMessage(payload []byte) (*QueueMessage, error) {
+	message := &QueueMessage{}
+	err := json.Unmarshal(payload, message)
+	if err != nil {
+		return nil, err
+	}
+
+	if message.Version < 0 || message.Version > QueueMessageVersion {
+		return nil, fmt.Errorf("%w: got version %d, support up to %d",
+			utils.ErrUnsupportedMessageVersion, message.Version, QueueMessageVersion)
+	}
+
+	if message.Trigger == "" {
+		message.Trigger = TriggerCron
+	}
+
+	return message, nil
 }
 
 type Task struct {
@@ -0,0 +1,37 @@
+package domain
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/tuanta7/qworker/pkg/utils"
+	"testing"
+)
+
+func TestDecodeQueueMessage(t *testing.T) {
+	t.Run("legacy_payload", func(t *testing.T) {
+		m, err := DecodeQueueMessage([]byte(`{"connector_id":2,"task_type":"user:full_sync"}`))
+		assert.Equal(t, nil, err)
+		assert.Equal(t, 0, m.Version)
+		assert.Equal(t, uint64(2), m.ConnectorID)
+		assert.Equal(t, TriggerCron, m.Trigger)
+	})
+
+	t.Run("current_version", func(t *testing.T) {
+		m, err := DecodeQueueMessage([]byte(`{"version":1,"connector_id":2,"task_type":"user:incremental_sync",` +
+			`"trigger":"manual","requested_by":"jdoe","since":"2025-03-01T00:00:00Z","dry_run":true}`))
+		assert.Equal(t, nil, err)
+		assert.Equal(t, TriggerManual, m.Trigger)
+		assert.Equal(t, "jdoe", m.RequestedBy)
+		assert.Equal(t, true, m.DryRun)
+		assert.Equal(t, 2025, m.Since.Year())
+	})
+
+	t.Run("unknown_version", func(t *testing.T) {
+		_, err := DecodeQueueMessage([]byte(`{"version":99,"connector_id":2}`))
+		assert.ErrorIs(t, err, utils.ErrUnsupportedMessageVersion)
+	})
+
+	t.Run("invalid_json", func(t *testing.T) {
+		_, err := DecodeQueueMessage([]byte(`{`))
+		assert.NotEqual(t, nil, err)
+	})
+}
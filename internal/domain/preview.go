@@ -0,0 +1,33 @@
+package domain
+
+import "encoding/json"
+
+// PreviewRequest selects a stored connector, or describes a connector that is not stored yet. Mapper
+// replaces the connector mapper as a draft, its preset is applied.
+type PreviewRequest struct {
+	ConnectorID   uint64        `json:"connectorId,omitempty"`
+	ConnectorType ConnectorType `json:"connectorType,omitempty"`
+	// Data is the connector config, with its secrets encrypted like a stored connector.
+	Data   json.RawMessage `json:"data,omitempty"`
+	Mapper *Mapper         `json:"mapper,omitempty"`
+	Limit  int             `json:"limit,omitempty"`
+}
+
+type PreviewResult struct {
+	ConnectorID   uint64          `json:"connectorId,omitempty"`
+	ConnectorType ConnectorType   `json:"connectorType"`
+	Entries       []*PreviewEntry `json:"entries"`
+}
+
+// PreviewEntry shows a sample record next to the user it maps to.
+type PreviewEntry struct {
+	ID         string              `json:"id"`
+	Attributes map[string][]string `json:"attributes"`
+	User       *User               `json:"user,omitempty"`
+	// Error is the mapping or validation error that would reject the record.
+	Error string `json:"error,omitempty"`
+	// Missing lists the attributes referenced by the mapper that the record does not have.
+	Missing []string `json:"missing,omitempty"`
+	// Warnings flags values that the sync would silently drop, such as unparseable timestamps.
+	Warnings []string `json:"warnings,omitempty"`
+}
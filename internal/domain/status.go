@@ -0,0 +1,58 @@
+package domain
+
+// Status flags of a user that the mapper status rules derive.
+const (
+	StatusActive        = "active"
+	StatusEmailVerified = "email_verified"
+)
+
+// StatusCheck is the test a status rule applies to the values of its attribute. A missing attribute fails
+// present, equals, matches and bits_set, and passes the other checks.
+type StatusCheck string
+
+const (
+	StatusCheckPresent    StatusCheck = "present"
+	StatusCheckAbsent     StatusCheck = "absent"
+	StatusCheckEquals     StatusCheck = "equals"     // a value equals Value, case-insensitively
+	StatusCheckNotEquals  StatusCheck = "not_equals" // no value equals Value, case-insensitively
+	StatusCheckMatches    StatusCheck = "matches"    // a value matches the regular expression Value
+	StatusCheckBitsSet    StatusCheck = "bits_set"   // the integer value has every bit of the mask Value
+	StatusCheckBitsClear  StatusCheck = "bits_clear" // the integer value has no bit of the mask Value
+	StatusCheckNotExpired StatusCheck = "not_expired"
+)
+
+// StatusTimeFormat is the format of the expiry time of a not_expired rule.
+type StatusTimeFormat string
+
+const (
+	// StatusTimeFileTime counts 100 nanoseconds since 1601 like Active Directory accountExpires, where 0 and
+	// 9223372036854775807 mean never.
+	StatusTimeFileTime StatusTimeFormat = "filetime"
+	// StatusTimeGeneralized is the LDAP generalized time, RFC 3339 is accepted too.
+	StatusTimeGeneralized StatusTimeFormat = "generalized"
+)
+
+type StatusRule struct {
+	Attribute string           `json:"attribute"`
+	Check     StatusCheck      `json:"check"`
+	Value     string           `json:"value,omitempty"`
+	Format    StatusTimeFormat `json:"format,omitempty"` // not_expired only, defaults to generalized
+}
+
+// StatusRules derive the status flags from the account attributes, a flag is set when every one of its rules
+// passes. A flag without rules falls back to its mapping, the active custom mapping for Active.
+type StatusRules struct {
+	Active        []StatusRule `json:"active,omitempty"`
+	EmailVerified []StatusRule `json:"email_verified,omitempty"`
+}
+
+// Flags returns the rules by status flag.
+func (s *StatusRules) Flags() map[string][]StatusRule {
+	if s == nil {
+		return nil
+	}
+	return map[string][]StatusRule{
+		StatusActive:        s.Active,
+		StatusEmailVerified: s.EmailVerified,
+	}
+}
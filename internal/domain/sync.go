@@ -0,0 +1,43 @@
+package domain
+
+import (
+	"github.com/tuanta7/qworker/pkg/sqlxx"
+	"time"
+)
+
+// SyncResult summarizes a single sync run of a connector.
+type SyncResult struct {
+	ConnectorID uint64 `json:"connectorId"`
+	Total       int    `json:"total"`
+	Synced      int    `json:"synced"`
+	Rejected    int    `json:"rejected"`
+	DryRun      bool   `json:"dryRun"`
+
+	// synced users by outcome, left at zero by dry runs
+	Created   int `json:"created"`
+	Updated   int `json:"updated"`
+	Unchanged int `json:"unchanged"`
+
+	// group pass, only run by sources that sync groups
+	Groups         int `json:"groups,omitempty"`
+	RejectedGroups int `json:"rejectedGroups,omitempty"`
+	Memberships    int `json:"memberships,omitempty"`
+}
+
+// RejectedRecord is a source entry that failed validation and was left out of the upsert.
+type RejectedRecord struct {
+	SourceID      uint64         `json:"sourceId"`
+	DN            string         `json:"dn"`
+	Reason        string         `json:"reason"`
+	RawAttributes sqlxx.TextData `json:"rawAttributes"`
+	CreatedAt     time.Time      `json:"createdAt"`
+}
+
+// ProcessedFile remembers a file imported by a file connector, so that the same content is not imported twice.
+type ProcessedFile struct {
+	SourceID    uint64    `json:"sourceId"`
+	Checksum    string    `json:"checksum"` // hex encoded SHA-256 of the content
+	FileName    string    `json:"fileName"`
+	RecordCount int       `json:"recordCount"`
+	ProcessedAt time.Time `json:"processedAt"`
+}
@@ -1,19 +1,96 @@
 package domain
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/tuanta7/qworker/pkg/sqlxx"
+	"github.com/tuanta7/qworker/pkg/utils"
+	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
+)
+
+// Column limits of private.user, see migrations/postgres.
+const (
+	MaxUsernameLength    = 255
+	MaxExternalIDLength  = 255
+	MaxFullNameLength    = 1000
+	MaxPhoneNumberLength = 20
+	MaxEmailLength       = 1000
+	MaxDNLength          = 2000
 )
 
 type User struct {
-	UserID        string    `json:"id"`
-	Username      string    `json:"username"`
-	FullName      string    `json:"fullName"`
-	PhoneNumber   string    `json:"phoneNumber"`
-	Email         string    `json:"email"`
-	EmailVerified bool      `json:"emailVerified"`
-	Active        bool      `json:"active"`
-	SourceID      *uint64   `json:"sourceID"`
-	Data          TextData  `json:"data"`
-	CreatedAt     time.Time `json:"createdAt"`
-	UpdatedAt     time.Time `json:"updatedAt"`
+	UserID        string         `json:"id"`
+	ExternalID    string         `json:"externalId"` // the identity of the user at its source, survives renames
+	Username      string         `json:"username"`
+	FullName      string         `json:"fullName"`
+	PhoneNumber   string         `json:"phoneNumber"`
+	Email         string         `json:"email"`
+	EmailVerified *bool          `json:"emailVerified"` // nil when the source does not tell, see StatusRules
+	Active        bool           `json:"active"`
+	SourceID      *uint64        `json:"sourceID"`
+	SourceDN      string         `json:"sourceDN"` // LDAP sources only, links the user to its groups
+	Data          sqlxx.TextData `json:"data"`
+	CreatedAt     time.Time      `json:"createdAt"`
+	UpdatedAt     time.Time      `json:"updatedAt"`
+	Hash          string         `json:"-"` // see ContentHash
+}
+
+// ContentHash is the hex encoded SHA-256 of the fields read from the source. The timestamps are left out, a
+// user whose content did not change is not written again.
+func (u *User) ContentHash() string {
+	data, _ := u.Data.Value()
+	text, _ := data.([]byte)
+
+	h := sha256.New()
+	fields := []string{
+		u.ExternalID, u.Username, u.FullName, u.PhoneNumber, u.Email, strconv.FormatBool(u.Active), u.SourceDN,
+		string(text),
+	}
+	// left out when unknown, so that the hashes of the sources that do not tell stay the same
+	if u.EmailVerified != nil {
+		fields = append(fields, strconv.FormatBool(*u.EmailVerified))
+	}
+	for _, field := range fields {
+		// the length prefix keeps the fields apart
+		_, _ = fmt.Fprintf(h, "%d:%s", len(field), field)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Validate checks the user against the private.user column constraints, so that a single
+// bad record can be rejected before it aborts the whole upsert transaction.
+func (u *User) Validate() error {
+	fields := []struct {
+		name   string
+		value  string
+		maxLen int
+		err    error
+	}{
+		{ColUsername, u.Username, MaxUsernameLength, utils.ErrUsernameTooLong},
+		{ColExternalID, u.ExternalID, MaxExternalIDLength, utils.ErrExternalIDTooLong},
+		{ColFullName, u.FullName, MaxFullNameLength, utils.ErrFullNameTooLong},
+		{ColPhoneNumber, u.PhoneNumber, MaxPhoneNumberLength, utils.ErrPhoneNumberTooLong},
+		{ColEmail, u.Email, MaxEmailLength, utils.ErrEmailTooLong},
+		{ColSourceDN, u.SourceDN, MaxDNLength, utils.ErrDNTooLong},
+	}
+
+	if strings.TrimSpace(u.Username) == "" {
+		return utils.ErrUsernameRequired
+	}
+
+	for _, f := range fields {
+		if !utf8.ValidString(f.value) || strings.ContainsRune(f.value, 0) {
+			return fmt.Errorf("%s: %w", f.name, utils.ErrInvalidTextEncoding)
+		}
+
+		if utf8.RuneCountInString(f.value) > f.maxLen {
+			return f.err
+		}
+	}
+
+	return nil
 }
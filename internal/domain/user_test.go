@@ -0,0 +1,56 @@
+package domain
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/tuanta7/qworker/pkg/sqlxx"
+	"github.com/tuanta7/qworker/pkg/utils"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUserValidate(t *testing.T) {
+	t.Run("valid_user", func(t *testing.T) {
+		u := &User{Username: "jdoe", FullName: "John Doe", PhoneNumber: "+84901234567", Email: "jdoe@example.com"}
+		assert.Equal(t, nil, u.Validate())
+	})
+
+	t.Run("missing_username", func(t *testing.T) {
+		u := &User{Username: "  ", Email: "jdoe@example.com"}
+		assert.ErrorIs(t, u.Validate(), utils.ErrUsernameRequired)
+	})
+
+	t.Run("phone_number_too_long", func(t *testing.T) {
+		u := &User{Username: "jdoe", PhoneNumber: strings.Repeat("1", 25)}
+		assert.ErrorIs(t, u.Validate(), utils.ErrPhoneNumberTooLong)
+	})
+
+	t.Run("multibyte_within_limit", func(t *testing.T) {
+		u := &User{Username: "jdoe", PhoneNumber: strings.Repeat("١", 20)}
+		assert.Equal(t, nil, u.Validate())
+	})
+
+	t.Run("nul_byte", func(t *testing.T) {
+		u := &User{Username: "jdoe", FullName: "John\x00Doe"}
+		assert.ErrorIs(t, u.Validate(), utils.ErrInvalidTextEncoding)
+	})
+}
+
+func TestUserContentHash(t *testing.T) {
+	u := &User{Username: "jdoe", Email: "jdoe@example.com", Data: sqlxx.TextData{Parsed: map[string]any{"b": 1, "a": "x"}}}
+	hash := u.ContentHash()
+
+	same := *u
+	same.Data = sqlxx.TextData{Raw: []byte(`{"a":"x","b":1}`)}
+	same.UpdatedAt = time.Now()
+	assert.Equal(t, hash, same.ContentHash())
+
+	// the fields are kept apart
+	moved := *u
+	moved.Username, moved.FullName = "jdo", "e"
+	assert.NotEqual(t, hash, moved.ContentHash())
+
+	inactive := *u
+	inactive.Active = true
+	assert.NotEqual(t, hash, inactive.ContentHash())
+}
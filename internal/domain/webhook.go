@@ -0,0 +1,58 @@
+package domain
+
+import (
+	"encoding/json"
+	"slices"
+	"time"
+)
+
+type UserEventType string
+
+const (
+	UserCreated       UserEventType = "user.created"
+	UserUpdated       UserEventType = "user.updated"
+	UserDeprovisioned UserEventType = "user.deprovisioned" // active went from true to false
+)
+
+// UserEvent is a change of a user recorded by the private.user trigger, in the transaction that made it.
+// Payload holds the new row under "user" and, for updates, the changed columns under "changes" with
+// their old and new values.
+type UserEvent struct {
+	EventID   uint64          `json:"id"`
+	UserID    string          `json:"userId"`
+	Type      UserEventType   `json:"type"`
+	Payload   json.RawMessage `json:"data"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
+
+// WebhookSubscription receives the user events of the listed types, every type when Events is empty.
+type WebhookSubscription struct {
+	SubscriptionID uint64          `json:"id"`
+	URL            string          `json:"url"`
+	Secret         string          `json:"-"` // encrypted, signs the deliveries
+	Events         []UserEventType `json:"events"`
+	Enabled        bool            `json:"enabled"`
+	CreatedAt      time.Time       `json:"createdAt"`
+	UpdatedAt      time.Time       `json:"updatedAt"`
+}
+
+func (s *WebhookSubscription) Accepts(t UserEventType) bool {
+	return s.Enabled && (len(s.Events) == 0 || slices.Contains(s.Events, t))
+}
+
+// WebhookTask is the payload of a delivery task, one per event and subscription.
+type WebhookTask struct {
+	EventID        uint64 `json:"event_id"`
+	SubscriptionID uint64 `json:"subscription_id"`
+}
+
+// WebhookDelivery logs an attempt to deliver an event, StatusCode is zero when no response was received.
+type WebhookDelivery struct {
+	SubscriptionID uint64        `json:"subscriptionId"`
+	EventID        uint64        `json:"eventId"`
+	Attempt        int           `json:"attempt"`
+	StatusCode     int           `json:"statusCode"`
+	Error          string        `json:"error"`
+	Duration       time.Duration `json:"duration"`
+	CreatedAt      time.Time     `json:"createdAt"`
+}
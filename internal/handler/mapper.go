@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/tuanta7/qworker/internal/domain"
+	"github.com/tuanta7/qworker/internal/usecase/connector"
+	"github.com/tuanta7/qworker/pkg/logger"
+	"github.com/tuanta7/qworker/pkg/utils"
+	"go.uber.org/zap"
+	"net/http"
+	"strconv"
+)
+
+// MapperHandler stores connector mappers for operators, behind the same admin token as the previews.
+type MapperHandler struct {
+	connectorUC *connectoruc.UseCase
+	adminToken  string
+	logger      *logger.ZapLogger
+}
+
+func NewMapperHandler(connectorUC *connectoruc.UseCase, adminToken string, zl *logger.ZapLogger) *MapperHandler {
+	return &MapperHandler{
+		connectorUC: connectorUC,
+		adminToken:  adminToken,
+		logger:      zl,
+	}
+}
+
+func (h *MapperHandler) Routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("PUT /admin/connectors/{connectorID}/mapper", h.save)
+	return mux
+}
+
+func (h *MapperHandler) save(w http.ResponseWriter, r *http.Request) {
+	if !authorized(r, h.adminToken) {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": utils.ErrInvalidToken.Error()})
+		return
+	}
+
+	connectorID, err := strconv.ParseUint(r.PathValue("connectorID"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": utils.ErrConnectorNotFound.Error()})
+		return
+	}
+
+	var mapper domain.Mapper
+	err = json.NewDecoder(http.MaxBytesReader(w, r.Body, adminMaxBodyBytes)).Decode(&mapper)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid request body: %v", err)})
+		return
+	}
+
+	err = h.connectorUC.SaveMapper(r.Context(), connectorID, &mapper)
+	if err != nil {
+		code := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, utils.ErrConnectorNotFound):
+			code = http.StatusNotFound
+		case errors.Is(err, utils.ErrInvalidMapper):
+			code = http.StatusBadRequest
+		default:
+			h.logger.Error("MapperHandler - save - h.connectorUC.SaveMapper", zap.Error(err))
+		}
+		writeJSON(w, code, map[string]string{"error": err.Error()})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/tuanta7/qworker/internal/domain"
+	"github.com/tuanta7/qworker/internal/usecase/preview"
+	"github.com/tuanta7/qworker/pkg/logger"
+	"github.com/tuanta7/qworker/pkg/utils"
+	"go.uber.org/zap"
+	"net/http"
+	"strings"
+)
+
+const adminMaxBodyBytes = 1 << 20
+
+// PreviewHandler serves mapper previews to operators, behind a static admin token.
+type PreviewHandler struct {
+	previewUC  *previewuc.UseCase
+	adminToken string
+	logger     *logger.ZapLogger
+}
+
+func NewPreviewHandler(previewUC *previewuc.UseCase, adminToken string, zl *logger.ZapLogger) *PreviewHandler {
+	return &PreviewHandler{
+		previewUC:  previewUC,
+		adminToken: adminToken,
+		logger:     zl,
+	}
+}
+
+func (h *PreviewHandler) Routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /admin/preview", h.preview)
+	return mux
+}
+
+func (h *PreviewHandler) preview(w http.ResponseWriter, r *http.Request) {
+	if !authorized(r, h.adminToken) {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": utils.ErrInvalidToken.Error()})
+		return
+	}
+
+	var req domain.PreviewRequest
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, adminMaxBodyBytes)).Decode(&req)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid request body: %v", err)})
+		return
+	}
+
+	result, err := h.previewUC.Preview(r.Context(), &req)
+	if err != nil {
+		code := http.StatusBadGateway // the source could not be read
+		switch {
+		case errors.Is(err, utils.ErrConnectorNotFound):
+			code = http.StatusNotFound
+		case errors.Is(err, utils.ErrInvalidConnector), errors.Is(err, utils.ErrInvalidMapper),
+			errors.Is(err, utils.ErrUnsupportedConnectorType):
+			code = http.StatusBadRequest
+		default:
+			h.logger.Error("PreviewHandler - preview - h.previewUC.Preview", zap.Error(err))
+		}
+		writeJSON(w, code, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+// authorized checks the bearer token of an admin request.
+func authorized(r *http.Request, adminToken string) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(adminToken), []byte(token)) == 1
+}
+
+func writeJSON(w http.ResponseWriter, code int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -2,6 +2,7 @@ package handler
 
 import (
 	"context"
+	"errors"
 	"github.com/tuanta7/qworker/config"
 	"github.com/tuanta7/qworker/internal/domain"
 	"github.com/tuanta7/qworker/internal/usecase/connector"
@@ -50,8 +51,15 @@ func (h *SchedulerHandler) Init(ctx context.Context) error {
 	return nil
 }
 
-func (h *SchedulerHandler) Clear() {
-	h.schedulerUC.ClearAllJobs()
+func (h *SchedulerHandler) Ready(_ context.Context) error {
+	if !h.schedulerUC.IsLeader() {
+		return errors.New("scheduler is not leading")
+	}
+	return nil
+}
+
+func (h *SchedulerHandler) Clear(ctx context.Context) error {
+	return h.schedulerUC.ClearAllJobs(ctx)
 }
 
 func (h *SchedulerHandler) HandleInsertConnector(ctx context.Context, message *domain.NotifyMessage) error {
@@ -86,12 +94,46 @@ func (h *SchedulerHandler) HandleUpdateConnector(ctx context.Context, message *d
 		return err
 	}
 
+	return h.reschedule(connector)
+}
+
+// HandleReload reconciles the jobs with the enabled connectors, for the changes whose notification was missed
+// while the scheduler was not listening.
+func (h *SchedulerHandler) HandleReload(ctx context.Context, _ *domain.NotifyMessage) error {
+	connectors, err := h.connectorUC.ListEnabled(ctx)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	enabled := make(map[string]struct{}, len(connectors))
+	for _, connector := range connectors {
+		enabled[strconv.FormatUint(connector.ConnectorID, 10)] = struct{}{}
+		err = h.reschedule(connector)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	for _, id := range h.schedulerUC.JobIDs() {
+		if _, ok := enabled[id]; !ok {
+			err = h.schedulerUC.CleanJob(id)
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// reschedule creates, replaces or removes the job of a connector according to its sync settings.
+func (h *SchedulerHandler) reschedule(connector *domain.Connector) error {
 	syncSettings, err := connector.GetSyncSettings()
 	if err != nil {
 		return err
 	}
 
-	sID := strconv.FormatUint(message.ID, 10)
+	sID := strconv.FormatUint(connector.ConnectorID, 10)
 	if !connector.Enabled || !syncSettings.IncSync {
 		return h.schedulerUC.CleanJob(sID)
 	}
@@ -119,10 +161,7 @@ func (h *SchedulerHandler) createIncrementalSyncJob(id uint64, period time.Durat
 	queue := config.QueueIncrementalSync
 	taskType := config.QueueTask[queue]
 
-	err := h.schedulerUC.CreateJob(period, queue, &domain.QueueMessage{
-		ConnectorID: id,
-		TaskType:    taskType,
-	})
+	err := h.schedulerUC.CreateJob(period, queue, domain.NewQueueMessage(id, taskType, domain.TriggerCron))
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,298 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/tuanta7/qworker/internal/domain"
+	"github.com/tuanta7/qworker/internal/metrics"
+	"github.com/tuanta7/qworker/internal/usecase/scim"
+	"github.com/tuanta7/qworker/pkg/logger"
+	"github.com/tuanta7/qworker/pkg/utils"
+	"go.uber.org/zap"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	scimContentType   = "application/scim+json"
+	scimMaxBodyBytes  = 1 << 20
+	scimDefaultCount  = 100
+	scimBasePath      = "/scim/v2/{connectorID}"
+	scimUsersResource = "Users"
+)
+
+// SCIMHandler serves the SCIM 2.0 /Users endpoint of every scim connector under /scim/v2/{connectorID}.
+type SCIMHandler struct {
+	scimUC      *scimuc.UseCase
+	maxPageSize uint64
+	logger      *logger.ZapLogger
+}
+
+func NewSCIMHandler(scimUC *scimuc.UseCase, maxPageSize uint64, zl *logger.ZapLogger) *SCIMHandler {
+	return &SCIMHandler{
+		scimUC:      scimUC,
+		maxPageSize: maxPageSize,
+		logger:      zl,
+	}
+}
+
+func (h *SCIMHandler) Routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("GET "+scimBasePath+"/Users", h.handle("list", h.listUsers))
+	mux.Handle("POST "+scimBasePath+"/Users", h.handle("create", h.createUser))
+	mux.Handle("GET "+scimBasePath+"/Users/{id}", h.handle("get", h.getUser))
+	mux.Handle("PUT "+scimBasePath+"/Users/{id}", h.handle("replace", h.replaceUser))
+	mux.Handle("PATCH "+scimBasePath+"/Users/{id}", h.handle("patch", h.patchUser))
+	mux.Handle("DELETE "+scimBasePath+"/Users/{id}", h.handle("delete", h.deleteUser))
+	return mux
+}
+
+// handle authenticates the request against the connector in the path and records its latency.
+func (h *SCIMHandler) handle(operation string, next func(http.ResponseWriter, *http.Request, *domain.Connector) error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, code: http.StatusOK}
+		defer func() {
+			metrics.SCIMRequestDuration.
+				WithLabelValues(operation, strconv.Itoa(rec.code)).
+				Observe(time.Since(start).Seconds())
+		}()
+
+		err := h.serve(rec, r, next)
+		if err != nil {
+			h.writeError(rec, r, operation, err)
+		}
+	})
+}
+
+func (h *SCIMHandler) serve(
+	w http.ResponseWriter,
+	r *http.Request,
+	next func(http.ResponseWriter, *http.Request, *domain.Connector) error,
+) error {
+	connectorID, err := strconv.ParseUint(r.PathValue("connectorID"), 10, 64)
+	if err != nil {
+		return utils.ErrInvalidToken
+	}
+
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return utils.ErrInvalidToken
+	}
+
+	connector, err := h.scimUC.Authenticate(r.Context(), connectorID, token)
+	if err != nil {
+		return err
+	}
+
+	return next(w, r, connector)
+}
+
+func (h *SCIMHandler) listUsers(w http.ResponseWriter, r *http.Request, c *domain.Connector) error {
+	startIndex, err := queryUint(r, "startIndex", 1)
+	if err != nil {
+		return err
+	}
+	startIndex = max(startIndex, 1)
+
+	count, err := queryUint(r, "count", scimDefaultCount)
+	if err != nil {
+		return err
+	}
+	count = min(count, h.maxPageSize)
+
+	users, total, err := h.scimUC.List(r.Context(), c, r.URL.Query().Get("filter"), startIndex, count)
+	if err != nil {
+		return err
+	}
+
+	resources := make([]map[string]any, 0, len(users))
+	for _, user := range users {
+		resources = append(resources, h.toResource(r, user))
+	}
+
+	writeSCIM(w, http.StatusOK, map[string]any{
+		"schemas":      []string{scimuc.SchemaListResponse},
+		"totalResults": total,
+		"startIndex":   startIndex,
+		"itemsPerPage": len(resources),
+		"Resources":    resources,
+	})
+	return nil
+}
+
+func (h *SCIMHandler) createUser(w http.ResponseWriter, r *http.Request, c *domain.Connector) error {
+	resource, err := decodeResource(w, r)
+	if err != nil {
+		return err
+	}
+
+	user, err := h.scimUC.Create(r.Context(), c, resource)
+	if err != nil {
+		return err
+	}
+
+	rendered := h.toResource(r, user)
+	w.Header().Set("Location", rendered["meta"].(map[string]any)["location"].(string))
+	writeSCIM(w, http.StatusCreated, rendered)
+	return nil
+}
+
+func (h *SCIMHandler) getUser(w http.ResponseWriter, r *http.Request, c *domain.Connector) error {
+	user, err := h.scimUC.Get(r.Context(), c, r.PathValue("id"))
+	if err != nil {
+		return err
+	}
+
+	writeSCIM(w, http.StatusOK, h.toResource(r, user))
+	return nil
+}
+
+func (h *SCIMHandler) replaceUser(w http.ResponseWriter, r *http.Request, c *domain.Connector) error {
+	resource, err := decodeResource(w, r)
+	if err != nil {
+		return err
+	}
+
+	user, err := h.scimUC.Replace(r.Context(), c, r.PathValue("id"), resource)
+	if err != nil {
+		return err
+	}
+
+	writeSCIM(w, http.StatusOK, h.toResource(r, user))
+	return nil
+}
+
+func (h *SCIMHandler) patchUser(w http.ResponseWriter, r *http.Request, c *domain.Connector) error {
+	body, err := decodeBody[struct {
+		Schemas    []string                `json:"schemas"`
+		Operations []scimuc.PatchOperation `json:"Operations"`
+	}](w, r)
+	if err != nil {
+		return err
+	}
+
+	user, err := h.scimUC.Patch(r.Context(), c, r.PathValue("id"), body.Operations)
+	if err != nil {
+		return err
+	}
+
+	writeSCIM(w, http.StatusOK, h.toResource(r, user))
+	return nil
+}
+
+func (h *SCIMHandler) deleteUser(w http.ResponseWriter, r *http.Request, c *domain.Connector) error {
+	err := h.scimUC.Deprovision(r.Context(), c, r.PathValue("id"))
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (h *SCIMHandler) toResource(r *http.Request, user *domain.User) map[string]any {
+	resource := scimuc.ToResource(user)
+
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	resource["meta"].(map[string]any)["location"] = fmt.Sprintf("%s://%s/scim/v2/%s/%s/%s",
+		scheme, r.Host, r.PathValue("connectorID"), scimUsersResource, user.UserID)
+
+	return resource
+}
+
+func (h *SCIMHandler) writeError(w http.ResponseWriter, r *http.Request, operation string, err error) {
+	code, scimType := http.StatusInternalServerError, ""
+	switch {
+	case errors.Is(err, utils.ErrInvalidToken):
+		code = http.StatusUnauthorized
+		w.Header().Set("WWW-Authenticate", `Bearer realm="scim"`)
+	case errors.Is(err, utils.ErrUserNotFound):
+		code = http.StatusNotFound
+	case errors.Is(err, utils.ErrUserConflict):
+		code, scimType = http.StatusConflict, "uniqueness"
+	case errors.Is(err, utils.ErrInvalidFilter):
+		code, scimType = http.StatusBadRequest, "invalidFilter"
+	case errors.Is(err, utils.ErrInvalidPatch):
+		code, scimType = http.StatusBadRequest, "invalidPath"
+	case errors.Is(err, utils.ErrInvalidResource):
+		code, scimType = http.StatusBadRequest, "invalidValue"
+	case errors.Is(err, errInvalidSyntax):
+		code, scimType = http.StatusBadRequest, "invalidSyntax"
+	}
+
+	detail := err.Error()
+	if code == http.StatusInternalServerError {
+		h.logger.Error("SCIMHandler - "+operation,
+			zap.String("path", r.URL.Path),
+			zap.Error(err))
+		detail = http.StatusText(code)
+	}
+
+	body := map[string]any{
+		"schemas": []string{scimuc.SchemaError},
+		"status":  strconv.Itoa(code),
+		"detail":  detail,
+	}
+	if scimType != "" {
+		body["scimType"] = scimType
+	}
+	writeSCIM(w, code, body)
+}
+
+var errInvalidSyntax = errors.New("invalid request syntax")
+
+func decodeBody[T any](w http.ResponseWriter, r *http.Request) (T, error) {
+	var body T
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, scimMaxBodyBytes)).Decode(&body)
+	if err != nil {
+		return body, fmt.Errorf("%w: %w", errInvalidSyntax, err)
+	}
+	return body, nil
+}
+
+func decodeResource(w http.ResponseWriter, r *http.Request) (map[string]any, error) {
+	resource, err := decodeBody[map[string]any](w, r)
+	if err != nil {
+		return nil, err
+	}
+	if resource == nil {
+		return nil, fmt.Errorf("%w: body must be a JSON object", errInvalidSyntax)
+	}
+	return resource, nil
+}
+
+func queryUint(r *http.Request, name string, fallback uint64) (uint64, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return fallback, nil
+	}
+
+	n, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s must be a non-negative integer", errInvalidSyntax, name)
+	}
+	return n, nil
+}
+
+func writeSCIM(w http.ResponseWriter, code int, body any) {
+	w.Header().Set("Content-Type", scimContentType)
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	code int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.code = code
+	r.ResponseWriter.WriteHeader(code)
+}
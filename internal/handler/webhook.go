@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/hibiken/asynq"
+	"github.com/tuanta7/qworker/internal/domain"
+	"github.com/tuanta7/qworker/internal/usecase/webhook"
+	"github.com/tuanta7/qworker/pkg/logger"
+	"github.com/tuanta7/qworker/pkg/utils"
+	"go.uber.org/zap"
+)
+
+type WebhookHandler struct {
+	webhookUC *webhookuc.UseCase
+	logger    *logger.ZapLogger
+}
+
+func NewWebhookHandler(webhookUC *webhookuc.UseCase, zl *logger.ZapLogger) *WebhookHandler {
+	return &WebhookHandler{
+		webhookUC: webhookUC,
+		logger:    zl,
+	}
+}
+
+// HandleDelivery posts an event to a subscription, a failed delivery is retried by asynq with the webhook
+// backoff. Deliveries whose subscription or event is gone are not retried.
+func (h *WebhookHandler) HandleDelivery(ctx context.Context, task *asynq.Task) error {
+	var payload domain.WebhookTask
+	err := json.Unmarshal(task.Payload(), &payload)
+	if err != nil {
+		h.logger.Error("WebhookHandler - HandleDelivery - json.Unmarshal", zap.Error(err))
+		return fmt.Errorf("%w: %w", err, asynq.SkipRetry)
+	}
+
+	retried, _ := asynq.GetRetryCount(ctx)
+	err = h.webhookUC.Deliver(ctx, &payload, retried+1)
+	if errors.Is(err, utils.ErrSubscriptionNotFound) || errors.Is(err, utils.ErrEventNotFound) {
+		return fmt.Errorf("%w: %w", err, asynq.SkipRetry)
+	}
+	if err != nil {
+		h.logger.Warn("WebhookHandler - HandleDelivery - h.webhookUC.Deliver",
+			zap.Uint64("event_id", payload.EventID),
+			zap.Uint64("subscription_id", payload.SubscriptionID),
+			zap.Int("attempt", retried+1),
+			zap.Error(err))
+	}
+	return err
+}
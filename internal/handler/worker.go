@@ -4,12 +4,19 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/hibiken/asynq"
 	"github.com/tuanta7/qworker/config"
 	"github.com/tuanta7/qworker/internal/domain"
+	"github.com/tuanta7/qworker/internal/metrics"
 	"github.com/tuanta7/qworker/internal/usecase/connector"
 	"github.com/tuanta7/qworker/internal/usecase/worker"
 	"github.com/tuanta7/qworker/pkg/logger"
+	"github.com/tuanta7/qworker/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"strconv"
 	"time"
 )
 
@@ -27,13 +34,18 @@ func NewWorkerHandler(workerUC *workeruc.UseCase, connectorUC *connectoruc.UseCa
 	}
 }
 
-func (h *WorkerHandler) HandleIncrementalSync(ctx context.Context, task *asynq.Task) error {
-	message := &domain.QueueMessage{}
-	err := json.Unmarshal(task.Payload(), message)
+func (h *WorkerHandler) HandleIncrementalSync(ctx context.Context, task *asynq.Task) (err error) {
+	message, err := h.decodeMessage(task)
 	if err != nil {
 		return err
 	}
 
+	ctx, span := h.startSpan(ctx, "HandleIncrementalSync", task, message)
+	defer func() { tracing.End(span, err) }()
+
+	outcome := metrics.OutcomeError
+	defer h.observeTask(task.Type(), message.ConnectorID, &outcome, time.Now())
+
 	fullSyncTask, err := h.workerUC.GetTask(message.ConnectorID, config.QueueFullSync)
 	if err != nil {
 		return err
@@ -41,24 +53,31 @@ func (h *WorkerHandler) HandleIncrementalSync(ctx context.Context, task *asynq.T
 
 	if fullSyncTask != nil {
 		// terminate current task to run full sync task (w strict priority)
+		outcome = metrics.OutcomeSkipped
 		return nil
 	}
 
-	err = h.workerUC.RunIncrementalSyncTask(ctx, message)
+	result, err := h.workerUC.RunIncrementalSyncTask(ctx, message)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	outcome = metrics.OutcomeSuccess
+	return h.writeResult(task, result)
 }
 
-func (h *WorkerHandler) HandleFullSync(ctx context.Context, task *asynq.Task) error {
-	message := &domain.QueueMessage{}
-	err := json.Unmarshal(task.Payload(), message)
+func (h *WorkerHandler) HandleFullSync(ctx context.Context, task *asynq.Task) (err error) {
+	message, err := h.decodeMessage(task)
 	if err != nil {
 		return err
 	}
 
+	ctx, span := h.startSpan(ctx, "HandleFullSync", task, message)
+	defer func() { tracing.End(span, err) }()
+
+	outcome := metrics.OutcomeError
+	defer h.observeTask(task.Type(), message.ConnectorID, &outcome, time.Now())
+
 	for {
 		incSyncTask, err := h.workerUC.GetTask(message.ConnectorID, config.QueueIncrementalSync)
 		if err != nil {
@@ -69,15 +88,99 @@ func (h *WorkerHandler) HandleFullSync(ctx context.Context, task *asynq.Task) er
 		}
 
 		if incSyncTask != nil && incSyncTask.State == asynq.TaskStateActive {
-			time.Sleep(10 * time.Second)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(10 * time.Second):
+			}
 			continue
 		}
 		break
 	}
 
-	err = h.workerUC.RunFullSyncTask(ctx, message)
+	result, err := h.workerUC.RunFullSyncTask(ctx, message)
+	if err != nil {
+		return err
+	}
+
+	outcome = metrics.OutcomeSuccess
+	return h.writeResult(task, result)
+}
+
+// decodeMessage rejects malformed payloads and unknown versions without retrying them.
+func (h *WorkerHandler) decodeMessage(task *asynq.Task) (*domain.QueueMessage, error) {
+	message, err := domain.DecodeQueueMessage(task.Payload())
+	if err != nil {
+		h.logger.Error("WorkerHandler - decodeMessage - domain.DecodeQueueMessage",
+			zap.String("type", task.Type()),
+			zap.Error(err))
+		return nil, fmt.Errorf("%w: %w", err, asynq.SkipRetry)
+	}
+
+	h.logger.Info("task received",
+		zap.String("type", task.Type()),
+		zap.Uint64("connector_id", message.ConnectorID),
+		zap.String("trigger", string(message.Trigger)),
+		zap.String("requested_by", message.RequestedBy),
+		zap.String("correlation_id", message.CorrelationID),
+		zap.Bool("dry_run", message.DryRun),
+		zap.Time("scheduled_at", message.ScheduledAt))
+	return message, nil
+}
+
+// startSpan continues the trace started by the scheduler tick that enqueued the task.
+func (h *WorkerHandler) startSpan(
+	ctx context.Context,
+	name string,
+	task *asynq.Task,
+	message *domain.QueueMessage,
+) (context.Context, trace.Span) {
+	taskID, _ := asynq.GetTaskID(ctx)
+	ctx = tracing.Extract(ctx, message.TraceContext)
+
+	return tracing.Tracer().Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String(tracing.AttrConnectorID, strconv.FormatUint(message.ConnectorID, 10)),
+			attribute.String(tracing.AttrTaskID, taskID),
+			attribute.String(tracing.AttrTaskType, task.Type()),
+			attribute.String(tracing.AttrTrigger, string(message.Trigger)),
+			attribute.String(tracing.AttrCorrelationID, message.CorrelationID),
+			attribute.Bool(tracing.AttrDryRun, message.DryRun),
+		))
+}
+
+// observeTask takes the outcome by pointer so that a deferred call sees its final value.
+func (h *WorkerHandler) observeTask(taskType string, connectorID uint64, outcome *string, start time.Time) {
+	metrics.TaskDuration.
+		WithLabelValues(taskType, strconv.FormatUint(connectorID, 10), *outcome).
+		Observe(time.Since(start).Seconds())
+}
+
+func (h *WorkerHandler) writeResult(task *asynq.Task, result *domain.SyncResult) error {
+	if result == nil {
+		return nil
+	}
+
+	if !result.DryRun {
+		connectorID := strconv.FormatUint(result.ConnectorID, 10)
+		metrics.SyncRecords.WithLabelValues(connectorID, "synced").Add(float64(result.Synced))
+		metrics.SyncRecords.WithLabelValues(connectorID, "rejected").Add(float64(result.Rejected))
+	}
+
+	if task.ResultWriter() == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(result)
 	if err != nil {
 		return err
 	}
+
+	_, err = task.ResultWriter().Write(data)
+	if err != nil {
+		h.logger.Warn("WorkerHandler - writeResult - task.ResultWriter().Write", zap.Error(err))
+	}
+
 	return nil
 }
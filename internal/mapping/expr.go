@@ -0,0 +1,324 @@
+package mapping
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// node is a compiled expression. An expression evaluates to the values of an attribute, most attributes
+// having a single value.
+type node interface {
+	eval(env *env) []string
+}
+
+// env is the state of a single evaluation.
+type env struct {
+	values func(attr string) []string
+}
+
+type literal struct {
+	value string
+}
+
+func (n *literal) eval(*env) []string {
+	return []string{n.value}
+}
+
+type reference struct {
+	attr string
+}
+
+func (n *reference) eval(e *env) []string {
+	return e.values(n.attr)
+}
+
+// concat joins the first values of its parts. It has no value when no attribute it references has one,
+// so that default(givenName + " " + sn, cn) falls back to cn.
+type concat struct {
+	parts []node
+}
+
+func (n *concat) eval(e *env) []string {
+	var sb strings.Builder
+	found := false
+	for _, part := range n.parts {
+		values := part.eval(e)
+		if _, ok := part.(*literal); !ok && len(values) > 0 && values[0] != "" {
+			found = true
+		}
+		if len(values) > 0 {
+			sb.WriteString(values[0])
+		}
+	}
+
+	if !found {
+		return nil
+	}
+	return []string{sb.String()}
+}
+
+type call struct {
+	fn   *function
+	args []node
+	// arguments that must be literals, compiled once
+	static any
+}
+
+func (n *call) eval(e *env) []string {
+	return n.fn.eval(e, n)
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenLParen
+	tokenRParen
+	tokenComma
+	tokenPlus
+)
+
+func (k tokenKind) String() string {
+	return [...]string{"end of expression", "attribute", "string", `"("`, `")"`, `","`, `"+"`}[k]
+}
+
+type token struct {
+	kind  tokenKind
+	value string
+	pos   int // 1-based column in the expression
+}
+
+// SyntaxError points at the column of the expression where parsing failed.
+type SyntaxError struct {
+	Pos int
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("column %d: %s", e.Pos, e.Msg)
+}
+
+// isIdentRune accepts the characters of LDAP attribute names, dotted paths, URNs and JSONPaths without
+// brackets. Other paths are referenced with attr("...").
+func isIdentRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("_.-:$@", r)
+}
+
+func tokenize(expr string) ([]token, error) {
+	var tokens []token
+	runes := []rune(expr)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		pos := i + 1
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, token{tokenLParen, "(", pos})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{tokenRParen, ")", pos})
+			i++
+		case r == ',':
+			tokens = append(tokens, token{tokenComma, ",", pos})
+			i++
+		case r == '+':
+			tokens = append(tokens, token{tokenPlus, "+", pos})
+			i++
+		case r == '"' || r == '\'':
+			var sb strings.Builder
+			i++
+			for ; i < len(runes) && runes[i] != r; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+					switch runes[i] {
+					case 'n':
+						sb.WriteRune('\n')
+					case 't':
+						sb.WriteRune('\t')
+					default:
+						sb.WriteRune(runes[i])
+					}
+					continue
+				}
+				sb.WriteRune(runes[i])
+			}
+			if i == len(runes) {
+				return nil, &SyntaxError{pos, "unterminated string"}
+			}
+			tokens = append(tokens, token{tokenString, sb.String(), pos})
+			i++
+		case isIdentRune(r):
+			start := i
+			for i < len(runes) && isIdentRune(runes[i]) {
+				i++
+			}
+			tokens = append(tokens, token{tokenIdent, string(runes[start:i]), pos})
+		default:
+			return nil, &SyntaxError{pos, fmt.Sprintf("unexpected %q", r)}
+		}
+	}
+
+	return append(tokens, token{tokenEOF, "", len(runes) + 1}), nil
+}
+
+type parser struct {
+	tokens  []token
+	pos     int
+	lookups map[string]map[string]string
+}
+
+// parse compiles an expression:
+//
+//	expr := term ("+" term)*
+//	term := string | attribute | function "(" [expr ("," expr)*] ")" | "(" expr ")"
+func parse(expr string, lookups map[string]map[string]string) (node, error) {
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens, lookups: lookups}
+	n, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, &SyntaxError{t.pos, fmt.Sprintf("unexpected %s", describe(t))}
+	}
+	return n, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) expect(kind tokenKind) (token, error) {
+	t := p.next()
+	if t.kind != kind {
+		return t, &SyntaxError{t.pos, fmt.Sprintf("expected %s, found %s", kind, describe(t))}
+	}
+	return t, nil
+}
+
+func (p *parser) expr() (node, error) {
+	first, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+
+	parts := []node{first}
+	for p.peek().kind == tokenPlus {
+		p.next()
+		part, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, part)
+	}
+
+	if len(parts) == 1 {
+		return first, nil
+	}
+	return &concat{parts: parts}, nil
+}
+
+func (p *parser) term() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenString:
+		return &literal{value: t.value}, nil
+	case tokenLParen:
+		n, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		_, err = p.expect(tokenRParen)
+		return n, err
+	case tokenIdent:
+		if p.peek().kind != tokenLParen {
+			return &reference{attr: t.value}, nil
+		}
+		return p.call(t)
+	default:
+		return nil, &SyntaxError{t.pos, fmt.Sprintf("expected a string, an attribute or a function, found %s", describe(t))}
+	}
+}
+
+func (p *parser) call(name token) (node, error) {
+	fn, ok := functions[name.value]
+	if !ok {
+		return nil, &SyntaxError{name.pos, fmt.Sprintf("unknown function %s", name.value)}
+	}
+	p.next() // (
+
+	var args []node
+	var positions []int
+	if p.peek().kind != tokenRParen {
+		for {
+			positions = append(positions, p.peek().pos)
+			arg, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+
+			if p.peek().kind != tokenComma {
+				break
+			}
+			p.next()
+		}
+	}
+	if _, err := p.expect(tokenRParen); err != nil {
+		return nil, err
+	}
+
+	if len(args) < fn.minArgs || (fn.maxArgs >= 0 && len(args) > fn.maxArgs) {
+		return nil, &SyntaxError{name.pos, fmt.Sprintf("%s expects %s, got %d", name.value, fn.arity(), len(args))}
+	}
+
+	n := &call{fn: fn, args: args}
+	if fn.compile != nil {
+		static, err := fn.compile(args, p.lookups)
+		if err != nil {
+			argPos := name.pos
+			var argErr *argumentError
+			if errors.As(err, &argErr) && argErr.index < len(positions) {
+				argPos = positions[argErr.index]
+				err = argErr.err
+			}
+			return nil, &SyntaxError{argPos, fmt.Sprintf("%s: %v", name.value, err)}
+		}
+		n.static = static
+	}
+
+	// attr("...") is a reference to a path that is not a valid identifier
+	if ref, ok := n.static.(*reference); ok {
+		return ref, nil
+	}
+	return n, nil
+}
+
+func describe(t token) string {
+	switch t.kind {
+	case tokenIdent:
+		return fmt.Sprintf("attribute %s", t.value)
+	case tokenString:
+		return fmt.Sprintf("string %q", t.value)
+	default:
+		return t.kind.String()
+	}
+}
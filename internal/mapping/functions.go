@@ -0,0 +1,318 @@
+package mapping
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type function struct {
+	minArgs int
+	maxArgs int // -1 for variadic functions
+	// compile checks the arguments that must be literals, its result is kept in call.static
+	compile func(args []node, lookups map[string]map[string]string) (any, error)
+	eval    func(e *env, n *call) []string
+}
+
+func (f *function) arity() string {
+	switch {
+	case f.maxArgs < 0:
+		return fmt.Sprintf("at least %d arguments", f.minArgs)
+	case f.minArgs == f.maxArgs && f.minArgs == 1:
+		return "1 argument"
+	case f.minArgs == f.maxArgs:
+		return fmt.Sprintf("%d arguments", f.minArgs)
+	default:
+		return fmt.Sprintf("%d to %d arguments", f.minArgs, f.maxArgs)
+	}
+}
+
+// argumentError is a compile error of the argument at index.
+type argumentError struct {
+	index int
+	err   error
+}
+
+func (e *argumentError) Error() string {
+	return e.err.Error()
+}
+
+var functions = map[string]*function{
+	"attr": {minArgs: 1, maxArgs: 1, compile: compileAttr},
+
+	"lower": {minArgs: 1, maxArgs: 1, eval: each(strings.ToLower)},
+	"upper": {minArgs: 1, maxArgs: 1, eval: each(strings.ToUpper)},
+	"trim":  {minArgs: 1, maxArgs: 1, eval: each(strings.TrimSpace)},
+	"first": {minArgs: 1, maxArgs: 1, eval: evalFirst},
+	"join":  {minArgs: 2, maxArgs: 2, eval: evalJoin},
+	"split": {minArgs: 2, maxArgs: 2, eval: evalSplit},
+
+	"replace":      {minArgs: 3, maxArgs: 3, eval: evalReplace},
+	"regexReplace": {minArgs: 3, maxArgs: 3, compile: compilePattern, eval: evalRegexReplace},
+	"extract":      {minArgs: 2, maxArgs: 3, compile: compileExtract, eval: evalExtract},
+	"lookup":       {minArgs: 2, maxArgs: 3, compile: compileLookup, eval: evalLookup},
+
+	"default": {minArgs: 2, maxArgs: -1, eval: evalDefault},
+	"if":      {minArgs: 2, maxArgs: 3, eval: evalIf},
+
+	"eq":         {minArgs: 2, maxArgs: 2, eval: compare(func(a, b string) bool { return a == b })},
+	"ne":         {minArgs: 2, maxArgs: 2, eval: compare(func(a, b string) bool { return a != b })},
+	"contains":   {minArgs: 2, maxArgs: 2, eval: compare(strings.Contains)},
+	"startsWith": {minArgs: 2, maxArgs: 2, eval: compare(strings.HasPrefix)},
+	"endsWith":   {minArgs: 2, maxArgs: 2, eval: compare(strings.HasSuffix)},
+	"matches":    {minArgs: 2, maxArgs: 2, compile: compilePattern, eval: evalMatches},
+	"empty":      {minArgs: 1, maxArgs: 1, eval: evalEmpty},
+	"not":        {minArgs: 1, maxArgs: 1, eval: evalNot},
+	"and":        {minArgs: 2, maxArgs: -1, eval: evalAnd},
+	"or":         {minArgs: 2, maxArgs: -1, eval: evalOr},
+}
+
+func literalArg(args []node, i int) (string, error) {
+	lit, ok := args[i].(*literal)
+	if !ok {
+		return "", &argumentError{i, errors.New("must be a string literal")}
+	}
+	return lit.value, nil
+}
+
+func compileAttr(args []node, _ map[string]map[string]string) (any, error) {
+	path, err := literalArg(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	if path == "" {
+		return nil, &argumentError{0, errors.New("empty attribute")}
+	}
+	return &reference{attr: path}, nil
+}
+
+func compilePattern(args []node, _ map[string]map[string]string) (any, error) {
+	pattern, err := literalArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, &argumentError{1, err}
+	}
+	return re, nil
+}
+
+type extractArgs struct {
+	re    *regexp.Regexp
+	group int
+}
+
+// compileExtract defaults to the first group of the pattern, or the whole match if it has none.
+func compileExtract(args []node, lookups map[string]map[string]string) (any, error) {
+	re, err := compilePattern(args, lookups)
+	if err != nil {
+		return nil, err
+	}
+
+	group := min(1, re.(*regexp.Regexp).NumSubexp())
+	if len(args) == 3 {
+		value, err := literalArg(args, 2)
+		if err != nil {
+			return nil, err
+		}
+		group, err = strconv.Atoi(value)
+		if err != nil || group < 0 || group > re.(*regexp.Regexp).NumSubexp() {
+			return nil, &argumentError{2, fmt.Errorf("invalid group %q", value)}
+		}
+	}
+
+	return &extractArgs{re: re.(*regexp.Regexp), group: group}, nil
+}
+
+func compileLookup(args []node, lookups map[string]map[string]string) (any, error) {
+	name, err := literalArg(args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	table, ok := lookups[name]
+	if !ok {
+		return nil, &argumentError{1, fmt.Errorf("unknown lookup table %q", name)}
+	}
+	return table, nil
+}
+
+func each(f func(string) string) func(e *env, n *call) []string {
+	return func(e *env, n *call) []string {
+		values := n.args[0].eval(e)
+		result := make([]string, len(values))
+		for i, v := range values {
+			result[i] = f(v)
+		}
+		return result
+	}
+}
+
+// firstOf returns the first value of an argument, or "" when it has none.
+func firstOf(e *env, n node) string {
+	values := n.eval(e)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func evalFirst(e *env, n *call) []string {
+	values := n.args[0].eval(e)
+	if len(values) == 0 {
+		return nil
+	}
+	return values[:1]
+}
+
+func evalJoin(e *env, n *call) []string {
+	values := n.args[0].eval(e)
+	if len(values) == 0 {
+		return nil
+	}
+	return []string{strings.Join(values, firstOf(e, n.args[1]))}
+}
+
+func evalSplit(e *env, n *call) []string {
+	sep := firstOf(e, n.args[1])
+	var result []string
+	for _, v := range n.args[0].eval(e) {
+		for _, part := range strings.Split(v, sep) {
+			if part = strings.TrimSpace(part); part != "" {
+				result = append(result, part)
+			}
+		}
+	}
+	return result
+}
+
+func evalReplace(e *env, n *call) []string {
+	old, replacement := firstOf(e, n.args[1]), firstOf(e, n.args[2])
+	return each(func(v string) string { return strings.ReplaceAll(v, old, replacement) })(e, n)
+}
+
+func evalRegexReplace(e *env, n *call) []string {
+	re, replacement := n.static.(*regexp.Regexp), firstOf(e, n.args[2])
+	return each(func(v string) string { return re.ReplaceAllString(v, replacement) })(e, n)
+}
+
+// evalExtract leaves out the values that do not match.
+func evalExtract(e *env, n *call) []string {
+	args := n.static.(*extractArgs)
+	var result []string
+	for _, v := range n.args[0].eval(e) {
+		if m := args.re.FindStringSubmatch(v); m != nil {
+			result = append(result, m[args.group])
+		}
+	}
+	return result
+}
+
+// evalLookup maps every value through the table, a value missing from it takes the default if any and
+// is left out otherwise.
+func evalLookup(e *env, n *call) []string {
+	table := n.static.(map[string]string)
+	var result []string
+	for _, v := range n.args[0].eval(e) {
+		if mapped, ok := table[v]; ok {
+			result = append(result, mapped)
+		} else if len(n.args) == 3 {
+			result = append(result, n.args[2].eval(e)...)
+		}
+	}
+	return result
+}
+
+func evalDefault(e *env, n *call) []string {
+	for _, arg := range n.args {
+		if values := arg.eval(e); !isEmpty(values) {
+			return values
+		}
+	}
+	return nil
+}
+
+func evalIf(e *env, n *call) []string {
+	if isTrue(n.args[0].eval(e)) {
+		return n.args[1].eval(e)
+	}
+	if len(n.args) == 3 {
+		return n.args[2].eval(e)
+	}
+	return nil
+}
+
+// compare checks every value of the first argument against the first value of the second one.
+func compare(f func(a, b string) bool) func(e *env, n *call) []string {
+	return func(e *env, n *call) []string {
+		b := firstOf(e, n.args[1])
+		values := n.args[0].eval(e)
+		if len(values) == 0 {
+			values = []string{""}
+		}
+		for _, a := range values {
+			if f(a, b) {
+				return boolean(true)
+			}
+		}
+		return boolean(false)
+	}
+}
+
+func evalMatches(e *env, n *call) []string {
+	re := n.static.(*regexp.Regexp)
+	for _, v := range n.args[0].eval(e) {
+		if re.MatchString(v) {
+			return boolean(true)
+		}
+	}
+	return boolean(false)
+}
+
+func evalEmpty(e *env, n *call) []string {
+	return boolean(isEmpty(n.args[0].eval(e)))
+}
+
+func evalNot(e *env, n *call) []string {
+	return boolean(!isTrue(n.args[0].eval(e)))
+}
+
+func evalAnd(e *env, n *call) []string {
+	for _, arg := range n.args {
+		if !isTrue(arg.eval(e)) {
+			return boolean(false)
+		}
+	}
+	return boolean(true)
+}
+
+func evalOr(e *env, n *call) []string {
+	for _, arg := range n.args {
+		if isTrue(arg.eval(e)) {
+			return boolean(true)
+		}
+	}
+	return boolean(false)
+}
+
+func isEmpty(values []string) bool {
+	for _, v := range values {
+		if v != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// isTrue treats a missing or empty value and "false" as false, so that an attribute can be a condition.
+func isTrue(values []string) bool {
+	return len(values) > 0 && values[0] != "" && !strings.EqualFold(values[0], "false")
+}
+
+func boolean(b bool) []string {
+	return []string{strconv.FormatBool(b)}
+}
@@ -0,0 +1,160 @@
+// Package mapping compiles the connector mapper. A mapping is either the name of a source attribute or,
+// when it starts with "=", an expression such as
+//
+//	=lower(trim(mail))
+//	=default(givenName + " " + sn, cn)
+//	=if(eq(employeeType, "contractor"), "External", lookup(departmentNumber, "departments", "Unknown"))
+//
+// Expressions are compiled once per sync run and evaluated per entry.
+package mapping
+
+import (
+	"errors"
+	"fmt"
+	"github.com/tuanta7/qworker/internal/domain"
+	"slices"
+	"strings"
+)
+
+// ExpressionPrefix marks a mapping as an expression rather than an attribute name.
+const ExpressionPrefix = "="
+
+type Mapping struct {
+	nodes  map[string]node
+	attrs  map[string]string
+	custom []string
+	types  map[string]domain.AttributeType
+	status map[string][]*statusRule
+}
+
+// Compile parses every mapping of the mapper, the error names the first invalid mapping.
+func Compile(m domain.Mapper) (*Mapping, error) {
+	mappings, err := m.ToMap()
+	if err != nil {
+		return nil, err
+	}
+
+	compiled := &Mapping{
+		nodes: make(map[string]node, len(mappings)),
+		attrs: make(map[string]string, len(mappings)),
+		types: m.CustomTypes,
+	}
+	for name, value := range mappings {
+		expr, ok := strings.CutPrefix(value, ExpressionPrefix)
+		if !ok {
+			compiled.nodes[name] = &reference{attr: value}
+			compiled.attrs[name] = value
+			continue
+		}
+
+		n, err := parse(expr, m.Lookups)
+		if err != nil {
+			var syntaxErr *SyntaxError
+			if errors.As(err, &syntaxErr) {
+				syntaxErr.Pos += len(ExpressionPrefix)
+			}
+			return nil, fmt.Errorf("mapping %q: %w", name, err)
+		}
+		compiled.nodes[name] = n
+	}
+
+	for key, value := range m.Custom {
+		if value != "" {
+			compiled.custom = append(compiled.custom, key)
+		}
+	}
+	slices.Sort(compiled.custom)
+
+	for key, t := range m.CustomTypes {
+		switch domain.AttributeType(strings.TrimSuffix(string(t), "[]")) {
+		case "", domain.AttributeString, domain.AttributeInt, domain.AttributeBool, domain.AttributeTime,
+			domain.AttributeBinary:
+		default:
+			return nil, fmt.Errorf("mapping %q: unsupported attribute type: %q", key, t)
+		}
+	}
+
+	for flag, rules := range m.Status.Flags() {
+		if len(rules) == 0 {
+			continue
+		}
+		if compiled.status == nil {
+			compiled.status = make(map[string][]*statusRule)
+		}
+		compiled.status[flag], err = compileStatus(flag, rules)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return compiled, nil
+}
+
+// Values evaluates a mapping, values returns the values of a source attribute. A mapping that is not set
+// has no value.
+func (m *Mapping) Values(name string, values func(attr string) []string) []string {
+	n, ok := m.nodes[name]
+	if !ok {
+		return nil
+	}
+	return n.eval(&env{values: values})
+}
+
+// Value returns the first value of a mapping, or "" when it has none.
+func (m *Mapping) Value(name string, values func(attr string) []string) string {
+	result := m.Values(name, values)
+	if len(result) == 0 {
+		return ""
+	}
+	return result[0]
+}
+
+// Attribute returns the source attribute of a mapping that a source filters on, such as the updated
+// timestamp of incremental syncs. Such a mapping cannot be an expression.
+func (m *Mapping) Attribute(name string) (string, error) {
+	if _, ok := m.nodes[name]; ok && m.attrs[name] == "" {
+		return "", fmt.Errorf("mapping %q: must be an attribute name, the source filters on it", name)
+	}
+	return m.attrs[name], nil
+}
+
+// Refs returns every source attribute the mappings and the status rules read, sorted.
+func (m *Mapping) Refs() []string {
+	var refs []string
+	for _, n := range m.nodes {
+		refs = appendRefs(refs, n)
+	}
+	for _, rules := range m.status {
+		for _, rule := range rules {
+			refs = append(refs, rule.attr)
+		}
+	}
+	slices.Sort(refs)
+	return slices.Compact(refs)
+}
+
+// Custom returns the custom mapping keys, sorted.
+func (m *Mapping) Custom() []string {
+	return m.custom
+}
+
+// Type returns the type hint of a custom mapping.
+func (m *Mapping) Type(key string) domain.AttributeType {
+	return m.types[key]
+}
+
+func appendRefs(refs []string, n node) []string {
+	switch n := n.(type) {
+	case *reference:
+		return append(refs, n.attr)
+	case *concat:
+		for _, part := range n.parts {
+			refs = appendRefs(refs, part)
+		}
+	case *call:
+		for _, arg := range n.args {
+			refs = appendRefs(refs, arg)
+		}
+	}
+	return refs
+}
@@ -0,0 +1,120 @@
+package mapping
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/tuanta7/qworker/internal/domain"
+	"testing"
+)
+
+func TestMapping(t *testing.T) {
+	attributes := map[string][]string{
+		"givenName":        {"John"},
+		"sn":               {"Doe"},
+		"cn":               {"Doe, John"},
+		"mail":             {"  John.Doe@Example.com "},
+		"departmentNumber": {"ENG"},
+		"employeeType":     {"contractor"},
+		"proxyAddresses":   {"SMTP:jdoe@example.com", "smtp:john@example.com"},
+		"manager":          {"CN=Jane Roe,OU=Staff,DC=example,DC=com"},
+		"telephoneNumber":  {"(028) 3812-3456"},
+		"emails[0].value":  {"home@example.com"},
+	}
+	values := func(attr string) []string { return attributes[attr] }
+	lookups := map[string]map[string]string{"departments": {"ENG": "Engineering", "HR": "Human Resources"}}
+
+	tests := []struct {
+		expr string
+		want []string
+	}{
+		{`mail`, []string{"  John.Doe@Example.com "}},
+		{`=lower(trim(mail))`, []string{"john.doe@example.com"}},
+		{`=givenName + " " + sn`, []string{"John Doe"}},
+		{`=default(middleName + " " + title, cn)`, []string{"Doe, John"}},
+		{`=default(title, "n/a")`, []string{"n/a"}},
+		{`=lookup(departmentNumber, "departments")`, []string{"Engineering"}},
+		{`=lookup(employeeType, "departments", "Unknown")`, []string{"Unknown"}},
+		{`=lookup(employeeType, "departments")`, nil},
+		{`=extract(manager, "^CN=([^,]+)")`, []string{"Jane Roe"}},
+		{`=extract(proxyAddresses, "^SMTP:(.+)$")`, []string{"jdoe@example.com"}},
+		{`=regexReplace(telephoneNumber, "[^0-9]", "")`, []string{"02838123456"}},
+		{`=replace(cn, ", ", "/")`, []string{"Doe/John"}},
+		{`=if(eq(employeeType, "contractor"), "External", "Staff")`, []string{"External"}},
+		{`=if(and(not(empty(mail)), contains(lower(mail), "@example.com")), "true", "false")`, []string{"true"}},
+		{`=if(or(startsWith(cn, "X"), matches(sn, "^D")), upper(sn))`, []string{"DOE"}},
+		{`=join(split("a; b;;c", ";"), ",")`, []string{"a,b,c"}},
+		{`=first(proxyAddresses)`, []string{"SMTP:jdoe@example.com"}},
+		{`=attr("emails[0].value")`, []string{"home@example.com"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			m, err := Compile(domain.Mapper{FullName: tt.expr, Lookups: lookups})
+			assert.Equal(t, nil, err)
+			assert.Equal(t, tt.want, m.Values(domain.MappingFullName, values))
+		})
+	}
+
+	t.Run("refs_and_attribute", func(t *testing.T) {
+		m, err := Compile(domain.Mapper{
+			Username:  "uid",
+			FullName:  `=default(displayName, givenName + " " + sn)`,
+			UpdatedAt: "modifyTimestamp",
+			Custom:    map[string]string{"title": "title", "unused": ""},
+		})
+		assert.Equal(t, nil, err)
+		assert.Equal(t, []string{"displayName", "givenName", "modifyTimestamp", "sn", "title", "uid"}, m.Refs())
+		assert.Equal(t, []string{"title"}, m.Custom())
+
+		attr, err := m.Attribute(domain.MappingUpdatedAt)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, "modifyTimestamp", attr)
+
+		_, err = m.Attribute(domain.MappingFullName)
+		assert.EqualError(t, err, `mapping "full_name": must be an attribute name, the source filters on it`)
+	})
+}
+
+func TestCompilePresets(t *testing.T) {
+	for preset, mapper := range domain.MapperPresets {
+		t.Run(string(preset), func(t *testing.T) {
+			m, err := Compile(mapper)
+			assert.Equal(t, nil, err)
+
+			_, err = m.Attribute(domain.MappingUpdatedAt)
+			assert.Equal(t, nil, err)
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		err  string
+	}{
+		{`=lower(mail`, `mapping "email": column 12: expected ")", found end of expression`},
+		{`=upper(mail, cn)`, `mapping "email": column 2: upper expects 1 argument, got 2`},
+		{`=lowercase(mail)`, `mapping "email": column 2: unknown function lowercase`},
+		{`=lookup(dept, "teams")`, `mapping "email": column 15: lookup: unknown lookup table "teams"`},
+		{`=extract(mail, "(")`, "mapping \"email\": column 16: extract: error parsing regexp: missing closing ): `(`"},
+		{`=matches(mail, pattern)`, `mapping "email": column 16: matches: must be a string literal`},
+		{`="unterminated`, `mapping "email": column 2: unterminated string`},
+		{`=mail cn`, `mapping "email": column 7: unexpected attribute cn`},
+		{`=mail + `, `mapping "email": column 9: expected a string, an attribute or a function, found end of expression`},
+		{`=mail # comment`, `mapping "email": column 7: unexpected '#'`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := Compile(domain.Mapper{Email: tt.expr})
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+
+	t.Run("unsupported_type", func(t *testing.T) {
+		_, err := Compile(domain.Mapper{
+			Custom:      map[string]string{"age": "age"},
+			CustomTypes: map[string]domain.AttributeType{"age": "float"},
+		})
+		assert.EqualError(t, err, `mapping "age": unsupported attribute type: "float"`)
+	})
+}
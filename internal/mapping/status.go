@@ -0,0 +1,177 @@
+package mapping
+
+import (
+	"errors"
+	"fmt"
+	"github.com/tuanta7/qworker/internal/domain"
+	"github.com/tuanta7/qworker/pkg/utils"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fileTimeUnixOffset is the number of seconds between 1601, the origin of the Windows FILETIME, and 1970.
+const fileTimeUnixOffset = 11644473600
+
+// statusRule is a compiled domain.StatusRule.
+type statusRule struct {
+	attr  string
+	check domain.StatusCheck
+	value string
+	re    *regexp.Regexp
+	mask  uint64
+	parse func(value string) (time.Time, bool, error)
+}
+
+func compileStatus(flag string, rules []domain.StatusRule) ([]*statusRule, error) {
+	compiled := make([]*statusRule, 0, len(rules))
+	for i, rule := range rules {
+		r, err := compileStatusRule(rule)
+		if err != nil {
+			return nil, fmt.Errorf("status %q rule %d: %w", flag, i+1, err)
+		}
+		compiled = append(compiled, r)
+	}
+	return compiled, nil
+}
+
+func compileStatusRule(rule domain.StatusRule) (*statusRule, error) {
+	if rule.Attribute == "" {
+		return nil, errors.New("empty attribute")
+	}
+
+	r := &statusRule{attr: rule.Attribute, check: rule.Check, value: rule.Value}
+	switch rule.Check {
+	case domain.StatusCheckPresent, domain.StatusCheckAbsent, domain.StatusCheckEquals, domain.StatusCheckNotEquals:
+	case domain.StatusCheckMatches:
+		re, err := regexp.Compile(rule.Value)
+		if err != nil {
+			return nil, err
+		}
+		r.re = re
+	case domain.StatusCheckBitsSet, domain.StatusCheckBitsClear:
+		mask, err := strconv.ParseUint(rule.Value, 0, 64)
+		if err != nil || mask == 0 {
+			return nil, fmt.Errorf("invalid bit mask %q", rule.Value)
+		}
+		r.mask = mask
+	case domain.StatusCheckNotExpired:
+		switch rule.Format {
+		case domain.StatusTimeFileTime:
+			r.parse = parseFileTime
+		case "", domain.StatusTimeGeneralized:
+			r.parse = parseGeneralizedTime
+		default:
+			return nil, fmt.Errorf("unsupported time format: %q", rule.Format)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported check: %q", rule.Check)
+	}
+	return r, nil
+}
+
+// HasStatus reports whether the mapper has rules for a status flag.
+func (m *Mapping) HasStatus(flag string) bool {
+	_, ok := m.status[flag]
+	return ok
+}
+
+// Status evaluates the rules of a status flag, ok is false when the flag has none. A value that cannot be
+// read, such as an expiry that is not a time, is an error rather than a guess.
+func (m *Mapping) Status(flag string, values func(attr string) []string) (status bool, ok bool, err error) {
+	rules, ok := m.status[flag]
+	if !ok {
+		return false, false, nil
+	}
+
+	for _, rule := range rules {
+		pass, err := rule.eval(values(rule.attr))
+		if err != nil {
+			return false, true, fmt.Errorf("%s: %s: %w", flag, rule.attr, err)
+		}
+		if !pass {
+			return false, true, nil
+		}
+	}
+	return true, true, nil
+}
+
+func (r *statusRule) eval(values []string) (bool, error) {
+	values = nonEmpty(values)
+	switch r.check {
+	case domain.StatusCheckPresent:
+		return len(values) > 0, nil
+	case domain.StatusCheckAbsent:
+		return len(values) == 0, nil
+	case domain.StatusCheckEquals, domain.StatusCheckNotEquals:
+		found := false
+		for _, v := range values {
+			found = found || strings.EqualFold(strings.TrimSpace(v), r.value)
+		}
+		return found == (r.check == domain.StatusCheckEquals), nil
+	case domain.StatusCheckMatches:
+		for _, v := range values {
+			if r.re.MatchString(v) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case domain.StatusCheckBitsSet, domain.StatusCheckBitsClear:
+		if len(values) == 0 {
+			return r.check == domain.StatusCheckBitsClear, nil
+		}
+		// userAccountControl is a signed 32-bit integer, large flags come as negative numbers
+		n, err := strconv.ParseInt(strings.TrimSpace(values[0]), 10, 64)
+		if err != nil {
+			return false, err
+		}
+		if r.check == domain.StatusCheckBitsSet {
+			return uint64(n)&r.mask == r.mask, nil
+		}
+		return uint64(n)&r.mask == 0, nil
+	case domain.StatusCheckNotExpired:
+		if len(values) == 0 {
+			return true, nil
+		}
+		expiry, never, err := r.parse(strings.TrimSpace(values[0]))
+		if err != nil {
+			return false, err
+		}
+		return never || time.Now().Before(expiry), nil
+	}
+	return false, nil
+}
+
+func parseFileTime(value string) (time.Time, bool, error) {
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	if n <= 0 || n == math.MaxInt64 {
+		return time.Time{}, true, nil
+	}
+	return time.Unix(n/10_000_000-fileTimeUnixOffset, n%10_000_000*100), false, nil
+}
+
+func parseGeneralizedTime(value string) (time.Time, bool, error) {
+	t, err := utils.ParseGeneralizedTime(value)
+	if err != nil {
+		var rfcErr error
+		if t, rfcErr = time.Parse(time.RFC3339Nano, value); rfcErr != nil {
+			return time.Time{}, false, err
+		}
+	}
+	return t, false, nil
+}
+
+func nonEmpty(values []string) []string {
+	result := values[:0:0]
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			result = append(result, v)
+		}
+	}
+	return result
+}
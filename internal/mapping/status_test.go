@@ -0,0 +1,103 @@
+package mapping
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/tuanta7/qworker/internal/domain"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func fileTime(t time.Time) string {
+	return strconv.FormatInt((t.Unix()+fileTimeUnixOffset)*10_000_000, 10)
+}
+
+func TestStatus(t *testing.T) {
+	future, past := time.Now().Add(24*time.Hour), time.Now().Add(-24*time.Hour)
+
+	tests := []struct {
+		name       string
+		preset     domain.MapperPreset
+		attributes map[string][]string
+		want       bool
+	}{
+		{"ad_enabled", domain.MapperPresetActiveDirectory,
+			map[string][]string{"userAccountControl": {"512"}, "accountExpires": {"9223372036854775807"}}, true},
+		{"ad_disabled", domain.MapperPresetActiveDirectory,
+			map[string][]string{"userAccountControl": {"514"}, "accountExpires": {"0"}}, false},
+		{"ad_never_expires", domain.MapperPresetActiveDirectory,
+			map[string][]string{"userAccountControl": {"66048"}, "accountExpires": {"0"}}, true},
+		{"ad_expires_later", domain.MapperPresetActiveDirectory,
+			map[string][]string{"userAccountControl": {"512"}, "accountExpires": {fileTime(future)}}, true},
+		{"ad_expired", domain.MapperPresetActiveDirectory,
+			map[string][]string{"userAccountControl": {"512"}, "accountExpires": {fileTime(past)}}, false},
+		{"openldap_unlocked", domain.MapperPresetOpenLDAP, map[string][]string{}, true},
+		{"openldap_locked", domain.MapperPresetOpenLDAP,
+			map[string][]string{"pwdAccountLockedTime": {"20250101000000Z"}}, false},
+		{"freeipa_unlocked", domain.MapperPresetFreeIPA,
+			map[string][]string{"nsAccountLock": {"FALSE"}, "krbPrincipalExpiration": {future.UTC().Format("20060102150405Z")}}, true},
+		{"freeipa_locked", domain.MapperPresetFreeIPA, map[string][]string{"nsAccountLock": {"true"}}, false},
+		{"freeipa_expired", domain.MapperPresetFreeIPA,
+			map[string][]string{"krbPrincipalExpiration": {past.UTC().Format("20060102150405Z")}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mapper, err := domain.Mapper{Preset: tt.preset}.WithPreset()
+			assert.Equal(t, nil, err)
+			m, err := Compile(mapper)
+			assert.Equal(t, nil, err)
+
+			active, ok, err := m.Status(domain.StatusActive, func(attr string) []string { return tt.attributes[attr] })
+			assert.Equal(t, nil, err)
+			assert.True(t, ok)
+			assert.Equal(t, tt.want, active)
+		})
+	}
+
+	t.Run("email_verified", func(t *testing.T) {
+		m, err := Compile(domain.Mapper{Status: &domain.StatusRules{
+			EmailVerified: []domain.StatusRule{
+				{Attribute: "mail", Check: domain.StatusCheckMatches, Value: `@example\.com$`},
+				{Attribute: "flags", Check: domain.StatusCheckBitsSet, Value: "0x4"},
+			},
+		}})
+		assert.Equal(t, nil, err)
+		assert.False(t, m.HasStatus(domain.StatusActive))
+		assert.Equal(t, []string{"flags", "mail"}, m.Refs())
+
+		attributes := map[string][]string{"mail": {"jdoe@example.com"}, "flags": {"6"}}
+		verified, ok, err := m.Status(domain.StatusEmailVerified, func(attr string) []string { return attributes[attr] })
+		assert.Equal(t, nil, err)
+		assert.True(t, ok && verified)
+
+		attributes["flags"] = []string{"2"}
+		verified, _, _ = m.Status(domain.StatusEmailVerified, func(attr string) []string { return attributes[attr] })
+		assert.False(t, verified)
+
+		attributes["flags"] = []string{"x"}
+		_, _, err = m.Status(domain.StatusEmailVerified, func(attr string) []string { return attributes[attr] })
+		assert.EqualError(t, err, `email_verified: flags: strconv.ParseInt: parsing "x": invalid syntax`)
+	})
+}
+
+func TestCompileStatusErrors(t *testing.T) {
+	tests := []struct {
+		rule domain.StatusRule
+		err  string
+	}{
+		{domain.StatusRule{Check: domain.StatusCheckPresent}, `status "active" rule 1: empty attribute`},
+		{domain.StatusRule{Attribute: "uac", Check: "odd"}, `status "active" rule 1: unsupported check: "odd"`},
+		{domain.StatusRule{Attribute: "uac", Check: domain.StatusCheckBitsClear, Value: "two"},
+			`status "active" rule 1: invalid bit mask "two"`},
+		{domain.StatusRule{Attribute: "exp", Check: domain.StatusCheckNotExpired, Format: "unix"},
+			`status "active" rule 1: unsupported time format: "unix"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.rule.Check), func(t *testing.T) {
+			_, err := Compile(domain.Mapper{Status: &domain.StatusRules{Active: []domain.StatusRule{tt.rule}}})
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+}
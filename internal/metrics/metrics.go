@@ -0,0 +1,114 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "qworker"
+
+// Refusal reasons of the scheduler when a tick does not end up in the queue.
+const (
+	RefusalFullSyncPending = "full_sync_pending"
+	RefusalInspectorError  = "inspector_error"
+	RefusalEnqueueError    = "enqueue_error"
+	RefusalMarshalError    = "marshal_error"
+)
+
+// Outcomes of a worker task.
+const (
+	OutcomeSuccess = "success"
+	OutcomeError   = "error"
+	OutcomeSkipped = "skipped"
+)
+
+var (
+	SchedulerTicks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "scheduler",
+		Name:      "ticks_total",
+		Help:      "Number of cron ticks fired by the scheduler.",
+	}, []string{"queue"})
+
+	SchedulerEnqueues = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "scheduler",
+		Name:      "enqueues_total",
+		Help:      "Number of tasks enqueued by the scheduler.",
+	}, []string{"queue"})
+
+	SchedulerRefusals = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "scheduler",
+		Name:      "refusals_total",
+		Help:      "Number of cron ticks that did not enqueue a task, by reason.",
+	}, []string{"queue", "reason"})
+
+	SchedulerJobs = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "scheduler",
+		Name:      "jobs",
+		Help:      "Number of cron jobs currently scheduled.",
+	})
+
+	Notifications = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "scheduler",
+		Name:      "notifications_total",
+		Help:      "Number of connector change notifications received, by action.",
+	}, []string{"action"})
+
+	NotificationErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "scheduler",
+		Name:      "notification_errors_total",
+		Help:      "Number of connector change notifications that failed to be handled, by action.",
+	}, []string{"action"})
+
+	TaskDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "worker",
+		Name:      "task_duration_seconds",
+		Help:      "Duration of worker tasks by task type, connector and outcome.",
+		Buckets:   []float64{0.1, 0.5, 1, 5, 15, 30, 60, 120, 300, 600, 1800},
+	}, []string{"task_type", "connector_id", "outcome"})
+
+	SyncRecords = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "worker",
+		Name:      "sync_records_total",
+		Help:      "Number of source records processed by sync runs, by status.",
+	}, []string{"connector_id", "status"})
+
+	LDAPSearchDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "ldap",
+		Name:      "search_duration_seconds",
+		Help:      "Latency of a single LDAP search page.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"connector_id"})
+
+	LDAPEntriesPerPage = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "ldap",
+		Name:      "entries_per_page",
+		Help:      "Number of entries returned by a single LDAP search page.",
+		Buckets:   prometheus.ExponentialBuckets(1, 4, 8),
+	}, []string{"connector_id"})
+
+	PostgresBatchDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "postgres",
+		Name:      "upsert_batch_duration_seconds",
+		Help:      "Latency of a single batch statement executed in a sync transaction.",
+		Buckets:   prometheus.DefBuckets,
+	})
+
+	SCIMRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "scim",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of inbound SCIM requests by operation and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation", "code"})
+)
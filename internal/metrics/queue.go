@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"github.com/hibiken/asynq"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	queueSizeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "queue", "size"),
+		"Number of tasks in a queue, by state.",
+		[]string{"queue", "state"}, nil,
+	)
+	queueLatencyDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "queue", "latency_seconds"),
+		"Time the oldest pending task of a queue has been waiting.",
+		[]string{"queue"}, nil,
+	)
+)
+
+// QueueCollector samples queue depth and latency from asynq on every scrape.
+type QueueCollector struct {
+	inspector *asynq.Inspector
+	queues    []string
+}
+
+func NewQueueCollector(inspector *asynq.Inspector, queues []string) *QueueCollector {
+	return &QueueCollector{
+		inspector: inspector,
+		queues:    queues,
+	}
+}
+
+func (c *QueueCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- queueSizeDesc
+	ch <- queueLatencyDesc
+}
+
+func (c *QueueCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, queue := range c.queues {
+		info, err := c.inspector.GetQueueInfo(queue)
+		if err != nil {
+			// the queue does not exist until the first task is enqueued
+			continue
+		}
+
+		states := map[string]int{
+			"pending":   info.Pending,
+			"active":    info.Active,
+			"scheduled": info.Scheduled,
+			"retry":     info.Retry,
+			"archived":  info.Archived,
+		}
+		for state, size := range states {
+			ch <- prometheus.MustNewConstMetric(queueSizeDesc, prometheus.GaugeValue, float64(size), queue, state)
+		}
+		ch <- prometheus.MustNewConstMetric(queueLatencyDesc, prometheus.GaugeValue, info.Latency.Seconds(), queue)
+	}
+}
@@ -22,6 +22,7 @@ func NewConnectorRepository(pc db.PostgresClient) *ConnectorRepository {
 func (r *ConnectorRepository) List(ctx context.Context, page, pageSize uint64) ([]*domain.Connector, error) {
 	query, args, err := r.PostgresClient.QueryBuilder().
 		Select(domain.AllConnectorCols...).
+		Column(mapperColumn).
 		From(domain.TableConnector).
 		Offset((page - 1) * pageSize).
 		OrderBy(fmt.Sprintf("%s DESC", domain.ColCreatedAt)).
@@ -34,9 +35,11 @@ func (r *ConnectorRepository) List(ctx context.Context, page, pageSize uint64) (
 	return r.list(ctx, query, args)
 }
 
+// ListByEnabled returns the connectors whose mapper resolves, see list.
 func (r *ConnectorRepository) ListByEnabled(ctx context.Context, enabled bool) ([]*domain.Connector, error) {
 	query, args, err := r.PostgresClient.QueryBuilder().
 		Select(domain.AllConnectorCols...).
+		Column(mapperColumn).
 		From(domain.TableConnector).
 		Where(squirrel.Eq{domain.ColEnabled: enabled}).
 		ToSql()
@@ -50,6 +53,7 @@ func (r *ConnectorRepository) ListByEnabled(ctx context.Context, enabled bool) (
 func (r *ConnectorRepository) GetByID(ctx context.Context, id uint64) (*domain.Connector, error) {
 	query, args, err := r.PostgresClient.QueryBuilder().
 		Select(domain.AllConnectorCols...).
+		Column(mapperColumn).
 		From(domain.TableConnector).
 		Where(squirrel.Eq{domain.ColConnectorID: id}).
 		ToSql()
@@ -57,17 +61,7 @@ func (r *ConnectorRepository) GetByID(ctx context.Context, id uint64) (*domain.C
 		return nil, err
 	}
 
-	c := &domain.Connector{}
-	err = r.Pool().QueryRow(ctx, query, args...).Scan(
-		&c.ConnectorID,
-		&c.ConnectorType,
-		&c.DisplayName,
-		&c.Enabled,
-		&c.LastSync,
-		&c.Data,
-		&c.CreatedAt,
-		&c.UpdatedAt,
-	)
+	c, err := scanConnector(r.Pool().QueryRow(ctx, query, args...))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, utils.ErrConnectorNotFound
@@ -75,18 +69,9 @@ func (r *ConnectorRepository) GetByID(ctx context.Context, id uint64) (*domain.C
 		return nil, err
 	}
 
-	// Active Directory
-	c.Mapper = domain.Mapper{
-		ExternalID:  "uuid",
-		Username:    "sAMAccountName",
-		FullName:    "cn",
-		PhoneNumber: "mobile",
-		Email:       "mail",
-		CreatedAt:   "whenCreated",
-		UpdatedAt:   "whenChanged",
-		Custom: map[string]string{
-			"active": "accountExpires",
-		},
+	err = resolveMapper(c)
+	if err != nil {
+		return nil, err
 	}
 
 	return c, nil
@@ -107,31 +92,95 @@ func (r *ConnectorRepository) UpdateSyncInfo(ctx context.Context, c *domain.Conn
 	return err
 }
 
+// list skips the connectors whose mapper does not resolve rather than failing the others, they are returned
+// alongside in an error wrapping utils.ErrInvalidMapper.
 func (r *ConnectorRepository) list(ctx context.Context, query string, args []any) ([]*domain.Connector, error) {
 	rows, err := r.Pool().Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
 
+	defer rows.Close()
+
 	connectors := make([]*domain.Connector, 0)
+	var skipped []error
 	for rows.Next() {
-		var c domain.Connector
-		err = rows.Scan(
-			&c.ConnectorID,
-			&c.ConnectorType,
-			&c.DisplayName,
-			&c.Enabled,
-			&c.LastSync,
-			&c.Data,
-			&c.CreatedAt,
-			&c.UpdatedAt,
-		)
+		c, err := scanConnector(rows)
 		if err != nil {
 			return nil, err
 		}
 
-		connectors = append(connectors, &c)
+		err = resolveMapper(c)
+		if err != nil {
+			skipped = append(skipped, err)
+			continue
+		}
+
+		connectors = append(connectors, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return connectors, errors.Join(skipped...)
+}
+
+// SaveMapper stores the mapper of a connector, replacing the previous one.
+func (r *ConnectorRepository) SaveMapper(ctx context.Context, connectorID uint64, mapper *domain.Mapper) error {
+	query, args, err := r.PostgresClient.QueryBuilder().
+		Insert(domain.TableMapper).
+		Columns(domain.ColMapperConnectorID, domain.ColData, domain.ColCreatedAt, domain.ColUpdatedAt).
+		Values(connectorID, mapper, squirrel.Expr("NOW()"), squirrel.Expr("NOW()")).
+		Suffix(fmt.Sprintf("ON CONFLICT (%s) DO UPDATE SET %s = EXCLUDED.%s, %s = EXCLUDED.%s",
+			domain.ColMapperConnectorID, domain.ColData, domain.ColData, domain.ColUpdatedAt, domain.ColUpdatedAt)).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = r.Pool().Exec(ctx, query, args...)
+	return err
+}
+
+// mapperColumn reads the connector mapper, NULL when the connector has none.
+var mapperColumn = fmt.Sprintf("(SELECT m.%s FROM %s m WHERE m.%s = %s.%s) AS mapper",
+	domain.ColData, domain.TableMapper, domain.ColMapperConnectorID, domain.TableConnector, domain.ColConnectorID)
+
+// scanConnector reads a connector, its mapper preset is resolved by resolveMapper.
+func scanConnector(row interface{ Scan(dest ...any) error }) (*domain.Connector, error) {
+	c := &domain.Connector{}
+	err := row.Scan(
+		&c.ConnectorID,
+		&c.ConnectorType,
+		&c.DisplayName,
+		&c.Enabled,
+		&c.LastSync,
+		&c.Priority,
+		&c.Data,
+		&c.CreatedAt,
+		&c.UpdatedAt,
+		&c.Mapper,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// resolveMapper resolves the mapper preset of a connector, connectors without a mapper get the default
+// preset of their type.
+func resolveMapper(c *domain.Connector) error {
+	if c.Mapper.IsZero() {
+		c.Mapper.Preset = domain.DefaultMapperPreset(c.ConnectorType)
+	}
+
+	mapper, err := c.Mapper.WithPreset()
+	if err != nil {
+		return fmt.Errorf("connector %d: %w: %w", c.ConnectorID, utils.ErrInvalidMapper, err)
 	}
 
-	return connectors, nil
+	c.Mapper = mapper
+	return nil
 }
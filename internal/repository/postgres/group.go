@@ -0,0 +1,153 @@
+package pgrepo
+
+import (
+	"fmt"
+	"github.com/Masterminds/squirrel"
+	"github.com/tuanta7/qworker/internal/domain"
+	"github.com/tuanta7/qworker/pkg/db"
+)
+
+type GroupRepository struct {
+	db.PostgresClient
+}
+
+func NewGroupRepository(pc db.PostgresClient) *GroupRepository {
+	return &GroupRepository{pc}
+}
+
+func (r *GroupRepository) BuildBulkUpsertQuery(groups []*domain.Group) *squirrel.InsertBuilder {
+	if len(groups) == 0 {
+		return nil
+	}
+
+	insertQuery := r.QueryBuilder().Insert(domain.TableGroup).Columns(domain.AllGroupSyncCols...)
+	for _, group := range groups {
+		insertQuery = insertQuery.Values(
+			group.SourceID,
+			group.DN,
+			group.Name,
+			group.Description,
+			group.CreatedAt,
+			group.UpdatedAt,
+		)
+	}
+
+	upsertQuery := insertQuery.Suffix(
+		"ON CONFLICT (source_id, dn) DO UPDATE " +
+			"SET name = EXCLUDED.name, " +
+			"description = EXCLUDED.description, " +
+			"updated_at = EXCLUDED.updated_at ",
+	)
+
+	return &upsertQuery
+}
+
+// BuildDeleteMembersQuery clears the memberships of the given groups, direct and nested, before they are
+// written again from the source.
+func (r *GroupRepository) BuildDeleteMembersQuery(sourceID uint64, groupDNs []string) squirrel.Sqlizer {
+	return r.QueryBuilder().
+		Delete(domain.TableGroupMember).
+		Where(squirrel.Expr(
+			fmt.Sprintf("%s IN (SELECT %s FROM %s WHERE %s = ? AND %s = ANY(?))",
+				domain.ColMemberGroupID, domain.ColGroupID, domain.TableGroup, domain.ColSourceID, domain.ColDN),
+			sourceID, groupDNs,
+		))
+}
+
+// BuildBulkInsertMembersQuery links the members to the groups of the source by group DN, the groups must
+// have been upserted earlier in the transaction. A membership already stored is kept as is.
+func (r *GroupRepository) BuildBulkInsertMembersQuery(sourceID uint64, members []*domain.GroupMember) squirrel.Sqlizer {
+	if len(members) == 0 {
+		return nil
+	}
+
+	groupDNs := make([]string, len(members))
+	memberDNs := make([]string, len(members))
+	nested := make([]bool, len(members))
+	for i, m := range members {
+		groupDNs[i], memberDNs[i], nested[i] = m.GroupDN, m.MemberDN, m.Nested
+	}
+
+	selectQuery := squirrel.
+		Select("g."+domain.ColGroupID, "m.member_dn", "m.nested").
+		From(domain.TableGroup+" AS g").
+		Join("unnest(?::text[], ?::text[], ?::boolean[]) AS m (group_dn, member_dn, nested) ON m.group_dn = g.dn",
+			groupDNs, memberDNs, nested).
+		Where(squirrel.Eq{"g." + domain.ColSourceID: sourceID})
+
+	return r.QueryBuilder().
+		Insert(domain.TableGroupMember).
+		Columns(domain.ColMemberGroupID, domain.ColMemberDN, domain.ColNested).
+		Select(selectQuery).
+		Suffix("ON CONFLICT (group_id, member_dn) DO NOTHING")
+}
+
+// BuildDeleteMissingQuery deletes the groups of the source that a full sync did not read, their
+// memberships go with them. A sync that read no group deletes them all, NOT (dn = ANY(NULL)) would match none.
+func (r *GroupRepository) BuildDeleteMissingQuery(sourceID uint64, seenDNs []string) squirrel.Sqlizer {
+	query := r.QueryBuilder().
+		Delete(domain.TableGroup).
+		Where(squirrel.Eq{domain.ColSourceID: sourceID})
+	if len(seenDNs) == 0 {
+		return query
+	}
+
+	return query.Where(squirrel.Expr(fmt.Sprintf("NOT (%s = ANY(?))", domain.ColDN), seenDNs))
+}
+
+// BuildExpandNestedQueries rebuilds the nested memberships of the source from the direct ones: a member
+// group passes its members, recursively, to the groups it belongs to. Cycles are cut by the path.
+func (r *GroupRepository) BuildExpandNestedQueries(sourceID uint64) []squirrel.Sqlizer {
+	deleteQuery := r.QueryBuilder().
+		Delete(domain.TableGroupMember).
+		Where(squirrel.Eq{domain.ColNested: true}).
+		Where(squirrel.Expr(
+			fmt.Sprintf("%s IN (SELECT %s FROM %s WHERE %s = ?)",
+				domain.ColMemberGroupID, domain.ColGroupID, domain.TableGroup, domain.ColSourceID),
+			sourceID,
+		))
+
+	expandQuery := squirrel.Expr(`WITH RECURSIVE closure (group_id, member_dn, path) AS (
+    SELECT gm.group_id, gm.member_dn, ARRAY [gm.group_id]
+    FROM private.group_member gm
+             JOIN private.group g ON g.id = gm.group_id
+    WHERE g.source_id = $1 AND NOT gm.nested
+    UNION ALL
+    SELECT c.group_id, gm.member_dn, c.path || sub.id
+    FROM closure c
+             JOIN private.group sub ON sub.source_id = $1 AND lower(sub.dn) = lower(c.member_dn)
+             JOIN private.group_member gm ON gm.group_id = sub.id AND NOT gm.nested
+    WHERE NOT sub.id = ANY (c.path)
+)
+INSERT INTO private.group_member (group_id, member_dn, nested)
+SELECT DISTINCT group_id, member_dn, true
+FROM closure
+ON CONFLICT (group_id, member_dn) DO NOTHING`, sourceID)
+
+	return []squirrel.Sqlizer{deleteQuery, expandQuery}
+}
+
+// BuildResolveMembersQuery links the memberships of the source to its users by the DN the source synced
+// them with, kept in source_dns even when another connector holds the user. DNs are compared
+// case-insensitively, a DN left behind by another user resolving to the latest written one. Members that
+// are not, or no longer, users of the source are unlinked.
+func (r *GroupRepository) BuildResolveMembersQuery(sourceID uint64) squirrel.Sqlizer {
+	return squirrel.Expr(`WITH resolved AS (
+    SELECT gm.group_id, gm.member_dn, u.id AS user_id
+    FROM private.group_member gm
+             JOIN private.group g ON g.id = gm.group_id AND g.source_id = $1
+             LEFT JOIN LATERAL (
+        SELECT id
+        FROM private.user
+        WHERE source_dns @> jsonb_build_object(($1::INTEGER)::text, lower(gm.member_dn))
+        ORDER BY updated_at DESC
+        LIMIT 1
+        ) u ON true
+)
+UPDATE private.group_member gm
+SET user_id = r.user_id
+FROM resolved r
+WHERE gm.group_id = r.group_id
+  AND gm.member_dn = r.member_dn
+  AND gm.user_id IS DISTINCT FROM r.user_id`, sourceID)
+}
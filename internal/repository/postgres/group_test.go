@@ -0,0 +1,31 @@
+package pgrepo_test
+
+import (
+	"github.com/stretchr/testify/assert"
+	pgrepo "github.com/tuanta7/qworker/internal/repository/postgres"
+	"testing"
+)
+
+func TestGroupQueries(t *testing.T) {
+	r := pgrepo.NewGroupRepository(queryBuilderOnly{})
+
+	t.Run("delete_missing", func(t *testing.T) {
+		query, args, err := r.BuildDeleteMissingQuery(2, []string{"cn=admins,dc=example,dc=com"}).ToSql()
+		assert.NoError(t, err)
+		assert.Equal(t, "DELETE FROM private.group WHERE source_id = $1 AND NOT (dn = ANY($2))", query)
+		assert.Equal(t, []any{uint64(2), []string{"cn=admins,dc=example,dc=com"}}, args)
+
+		query, args, err = r.BuildDeleteMissingQuery(2, nil).ToSql()
+		assert.NoError(t, err)
+		assert.Equal(t, "DELETE FROM private.group WHERE source_id = $1", query)
+		assert.Equal(t, []any{uint64(2)}, args)
+	})
+
+	t.Run("resolve_members", func(t *testing.T) {
+		query, args, err := r.BuildResolveMembersQuery(2).ToSql()
+		assert.NoError(t, err)
+		assert.Contains(t, query, "source_dns @> jsonb_build_object(($1::INTEGER)::text, lower(gm.member_dn))")
+		assert.NotContains(t, query, "u.source_id")
+		assert.Equal(t, []any{uint64(2)}, args)
+	})
+}
@@ -0,0 +1,54 @@
+package pgrepo
+
+import (
+	"context"
+	"github.com/Masterminds/squirrel"
+	"github.com/tuanta7/qworker/internal/domain"
+	"github.com/tuanta7/qworker/pkg/db"
+)
+
+type ProcessedFileRepository struct {
+	db.PostgresClient
+}
+
+func NewProcessedFileRepository(pc db.PostgresClient) *ProcessedFileRepository {
+	return &ProcessedFileRepository{pc}
+}
+
+func (r *ProcessedFileRepository) Exists(ctx context.Context, sourceID uint64, checksum string) (bool, error) {
+	query, args, err := r.QueryBuilder().
+		Select("1").
+		Prefix("SELECT EXISTS (").
+		From(domain.TableProcessedFile).
+		Where(squirrel.Eq{domain.ColSourceID: sourceID, domain.ColChecksum: checksum}).
+		Suffix(")").
+		ToSql()
+	if err != nil {
+		return false, err
+	}
+
+	var exists bool
+	err = r.Pool().QueryRow(ctx, query, args...).Scan(&exists)
+	return exists, err
+}
+
+// BuildBulkInsertQuery ignores files already recorded, which happens when two runs import the same file.
+func (r *ProcessedFileRepository) BuildBulkInsertQuery(files []*domain.ProcessedFile) *squirrel.InsertBuilder {
+	if len(files) == 0 {
+		return nil
+	}
+
+	insertQuery := r.QueryBuilder().Insert(domain.TableProcessedFile).Columns(domain.AllProcessedFileCols...)
+	for _, file := range files {
+		insertQuery = insertQuery.Values(
+			file.SourceID,
+			file.Checksum,
+			file.FileName,
+			file.RecordCount,
+			file.ProcessedAt,
+		)
+	}
+
+	insertQuery = insertQuery.Suffix("ON CONFLICT (source_id, checksum) DO NOTHING")
+	return &insertQuery
+}
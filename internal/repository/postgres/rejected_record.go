@@ -0,0 +1,34 @@
+package pgrepo
+
+import (
+	"github.com/Masterminds/squirrel"
+	"github.com/tuanta7/qworker/internal/domain"
+	"github.com/tuanta7/qworker/pkg/db"
+)
+
+type RejectedRecordRepository struct {
+	db.PostgresClient
+}
+
+func NewRejectedRecordRepository(pc db.PostgresClient) *RejectedRecordRepository {
+	return &RejectedRecordRepository{pc}
+}
+
+func (r *RejectedRecordRepository) BuildBulkInsertQuery(records []*domain.RejectedRecord) *squirrel.InsertBuilder {
+	if len(records) == 0 {
+		return nil
+	}
+
+	insertQuery := r.QueryBuilder().Insert(domain.TableSyncRejectedRecord).Columns(domain.AllSyncRejectedRecordCols...)
+	for _, record := range records {
+		insertQuery = insertQuery.Values(
+			record.SourceID,
+			record.DN,
+			record.Reason,
+			record.RawAttributes,
+			record.CreatedAt,
+		)
+	}
+
+	return &insertQuery
+}
@@ -2,12 +2,26 @@ package pgrepo
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 	"github.com/Masterminds/squirrel"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/tuanta7/qworker/internal/domain"
+	"github.com/tuanta7/qworker/internal/metrics"
 	"github.com/tuanta7/qworker/pkg/db"
+	"github.com/tuanta7/qworker/pkg/sqlxx"
+	"github.com/tuanta7/qworker/pkg/utils"
+	"slices"
+	"strings"
+	"time"
 )
 
+// uniqueViolation is the SQLSTATE of a unique constraint violation.
+const uniqueViolation = "23505"
+
 type UserRepository struct {
 	db.PostgresClient
 }
@@ -16,40 +30,480 @@ func NewUserRepository(pc db.PostgresClient) *UserRepository {
 	return &UserRepository{pc}
 }
 
+// CountedQuery is an upsert whose rows ExecuteTransaction counts into the created and updated users of
+// the result, collecting the ids of the written rows in IDs.
+type CountedQuery struct {
+	squirrel.Sqlizer
+	Result *domain.SyncResult
+	IDs    []string
+}
+
+// BuildBulkSyncQueries writes the users of a sync page, in order: the renames of the linked users, the
+// conflicts, the upsert and the links of the new identities. The upsert is counted into result if any.
+func (r *UserRepository) BuildBulkSyncQueries(
+	sourceID uint64,
+	users []*domain.User,
+	result *domain.SyncResult,
+) []squirrel.Sqlizer {
+	if len(users) == 0 {
+		return nil
+	}
+
+	var upsertQuery squirrel.Sqlizer = r.BuildBulkUpsertQuery(users)
+	if result != nil {
+		upsertQuery = &CountedQuery{Sqlizer: upsertQuery, Result: result}
+	}
+
+	queries := []squirrel.Sqlizer{
+		r.BuildRecordConflictsQuery(sourceID, users),
+		upsertQuery,
+	}
+	if query := r.BuildRenameQuery(sourceID, users); query != nil {
+		queries = append([]squirrel.Sqlizer{query}, queries...)
+	}
+	if query := r.BuildLinkQuery(sourceID, users); query != nil {
+		queries = append(queries, query)
+	}
+	return queries
+}
+
+// linkedUsername resolves the username a synced user is stored under, which is the username of the user
+// linked to its external id if any. It takes the source id, then the external id and username expressions.
+const linkedUsername = "COALESCE((SELECT lu.username FROM private.user lu " +
+	"JOIN private.user_identity l ON l.user_id = lu.id WHERE l.source_id = ? AND l.external_id = %s), %s)"
+
+// upsertSnapshot keeps the rows the upsert may update as they were, by username or linked external id, so
+// that it can tell the rows whose columns changed from those where the source won nothing.
+var upsertSnapshot = fmt.Sprintf("WITH snapshot AS (SELECT id, %s FROM private.user WHERE username = ANY(?) "+
+	"OR id IN (SELECT user_id FROM private.user_identity WHERE source_id = ? AND external_id = ANY(?)))",
+	strings.Join(writtenUserCols, ", "))
+
+// writtenUserCols are the columns of a user the upsert may change, besides the bookkeeping ones.
+var writtenUserCols = append(slices.Clone(domain.AttributeUserCols),
+	domain.ColEmailVerified, domain.ColSourceID, domain.ColSourceDN)
+
+// BuildBulkUpsertQuery inserts the users of a source or merges them into the users of the same username,
+// users with an external id already linked are merged into the linked user. An existing user keeps the
+// attribute values written by connectors that take precedence over the source, see precedenceSet.
+// source_hashes keeps the content hash of the user per connector, a user is only updated when the hash
+// of the source changed. source_dns keeps the DN of the user per connector, whichever connector holds it. Timestamps the source did not provide are set to the time of the write, an existing
+// user keeping its creation time. The query returns the id of each written row, whether it was created, and
+// whether any of its columns changed. All the users belong to the same source.
 func (r *UserRepository) BuildBulkUpsertQuery(users []*domain.User) *squirrel.InsertBuilder {
 	if len(users) == 0 {
 		return nil
 	}
 
-	insertQuery := r.QueryBuilder().Insert(domain.TableUser).Columns(domain.AllUserSyncCols...)
+	usernames := make([]string, len(users))
+	var externalIDs []string
+	for i, user := range users {
+		usernames[i] = user.Username
+		if user.ExternalID != "" {
+			externalIDs = append(externalIDs, user.ExternalID)
+		}
+	}
+
+	insertQuery := r.QueryBuilder().
+		Insert(domain.TableUser+" AS u").
+		Prefix(upsertSnapshot, usernames, users[0].SourceID, externalIDs).
+		Columns(domain.AllUserSyncCols...).
+		Columns(domain.ColAttributeSources, domain.ColSourceHashes, domain.ColSourceDNs)
 	for _, user := range users {
+		var username any = user.Username
+		if user.ExternalID != "" {
+			username = squirrel.Expr(fmt.Sprintf(linkedUsername, "?", "?"), user.SourceID, user.ExternalID, user.Username)
+		}
+
 		insertQuery = insertQuery.Values(
 			uuid.NewString(),
-			user.Username,
+			username,
 			user.FullName,
 			user.PhoneNumber,
 			user.Email,
+			user.EmailVerified,
+			user.Active,
 			user.SourceID,
+			nullString(user.SourceDN),
 			user.Data,
-			user.CreatedAt,
-			user.UpdatedAt,
+			squirrel.Expr("COALESCE(?::TIMESTAMP, NOW())", nullTime(user.CreatedAt)),
+			squirrel.Expr("COALESCE(?::TIMESTAMP, NOW())", nullTime(user.UpdatedAt)),
+			squirrel.Expr("private.merge_sources('{}', ?)", user.SourceID),
+			squirrel.Expr("jsonb_build_object((?::INTEGER)::text, ?::text)", user.SourceID, user.Hash),
+			squirrel.Expr("jsonb_strip_nulls(jsonb_build_object((?::INTEGER)::text, lower(?::text)))",
+				user.SourceID, nullString(user.SourceDN)),
 		)
 	}
 
-	upsertQuery := insertQuery.Suffix(
-		"ON CONFLICT (username) DO UPDATE " +
-			"SET full_name = EXCLUDED.full_name, " +
-			"phone_number = EXCLUDED.phone_number, " +
-			"email = EXCLUDED.email, " +
-			"data = EXCLUDED.data, " +
-			"source_id = EXCLUDED.source_id, " +
-			"created_at = EXCLUDED.created_at, " +
-			"updated_at = EXCLUDED.updated_at ",
-	)
-
+	columns := strings.Join(writtenUserCols, ", ")
+	upsertQuery := insertQuery.Suffix("ON CONFLICT (username) DO UPDATE SET " + precedenceSet +
+		" WHERE u.source_hashes IS DISTINCT FROM u.source_hashes || EXCLUDED.source_hashes" +
+		" RETURNING u.id, xmax = 0, NOT EXISTS (SELECT 1 FROM snapshot o WHERE o.id = u.id AND " +
+		"(o." + strings.ReplaceAll(columns, ", ", ", o.") + ") IS NOT DISTINCT FROM " +
+		"(u." + strings.ReplaceAll(columns, ", ", ", u.") + "))")
 	return &upsertQuery
 }
 
+// precedenceSet writes an attribute when private.source_wins lets the source write it: the owner of the
+// attribute always does, otherwise the connector of higher priority. The user moves to the source, with
+// its DN, when the source outranks the connector holding it. email_verified is only written by the sources
+// deriving it, when it is unknown or the source outranks the connector holding the user. updated_at only
+// moves when one of those writes changes a value, created_at is kept from the first write.
+var precedenceSet = func() string {
+	wins := make(map[string]string, len(writtenUserCols))
+	for _, col := range domain.AttributeUserCols {
+		wins[col] = sourceWins(col, "EXCLUDED.source_id")
+	}
+	for _, col := range []string{domain.ColSourceID, domain.ColSourceDN} {
+		wins[col] = "private.outranks(EXCLUDED.source_id, u.source_id)"
+	}
+	wins[domain.ColEmailVerified] = "EXCLUDED.email_verified IS NOT NULL AND " +
+		"(u.email_verified IS NULL OR private.outranks(EXCLUDED.source_id, u.source_id))"
+
+	set := make([]string, 0, len(writtenUserCols)+3)
+	changed := make([]string, 0, len(writtenUserCols))
+	for _, col := range writtenUserCols {
+		set = append(set, fmt.Sprintf("%[1]s = CASE WHEN %[2]s THEN EXCLUDED.%[1]s ELSE u.%[1]s END", col, wins[col]))
+		changed = append(changed, fmt.Sprintf("(%[2]s AND EXCLUDED.%[1]s IS DISTINCT FROM u.%[1]s)", col, wins[col]))
+	}
+	return strings.Join(append(set,
+		"attribute_sources = private.merge_sources(u.attribute_sources, EXCLUDED.source_id)",
+		"source_hashes = u.source_hashes || EXCLUDED.source_hashes",
+		"source_dns = u.source_dns || EXCLUDED.source_dns",
+		"updated_at = CASE WHEN "+strings.Join(changed, " OR ")+" THEN EXCLUDED.updated_at ELSE u.updated_at END",
+	), ", ")
+}()
+
+// sourceWins tells whether the source, an SQL expression, writes the attribute of the user u.
+func sourceWins(col, source string) string {
+	return fmt.Sprintf("private.source_wins('%s', %s, u.attribute_sources)", col, source)
+}
+
+// BuildRecordConflictsQuery records the attributes of the users about to be upserted whose stored value was
+// written by another connector and differs from the synced one, along with the connector that keeps its
+// value. Users whose content hash did not change are skipped. It must run before the upsert.
+func (r *UserRepository) BuildRecordConflictsQuery(sourceID uint64, users []*domain.User) squirrel.Sqlizer {
+	if len(users) == 0 {
+		return nil
+	}
+
+	keys := make([]string, len(users))
+	for i, user := range users {
+		keys[i] = user.ExternalID
+	}
+	return r.recordConflicts(sourceID, users, keys, "u.username = "+fmt.Sprintf(linkedUsername, "i.key", "i.username"),
+		sourceID)
+}
+
+// recordConflicts builds the conflicts query of BuildRecordConflictsQuery, the users being matched to
+// their stored row through i.key by the join condition on, followed by its args.
+func (r *UserRepository) recordConflicts(
+	sourceID uint64,
+	users []*domain.User,
+	keys []string,
+	on string,
+	onArgs ...any,
+) squirrel.Sqlizer {
+	usernames := make([]string, len(users))
+	fullNames := make([]string, len(users))
+	phoneNumbers := make([]string, len(users))
+	emails := make([]string, len(users))
+	active := make([]bool, len(users))
+	data := make([]*string, len(users))
+	hashes := make([]string, len(users))
+	for i, user := range users {
+		hashes[i] = user.Hash
+		usernames[i] = user.Username
+		fullNames[i], phoneNumbers[i] = user.FullName, user.PhoneNumber
+		emails[i], active[i], data[i] = user.Email, user.Active, textData(user.Data)
+	}
+
+	selectQuery := squirrel.
+		Select("u."+domain.ColUserID, "a.attribute").
+		Column("?::INTEGER", sourceID).
+		Column("(u.attribute_sources ->> a.attribute)::INTEGER").
+		Column("CASE WHEN private.source_wins(a.attribute, ?, u.attribute_sources) THEN ?::INTEGER "+
+			"ELSE (u.attribute_sources ->> a.attribute)::INTEGER END", sourceID, sourceID).
+		From(domain.TableUser+" AS u").
+		Join("unnest(?::text[], ?::text[], ?::text[], ?::text[], ?::text[], ?::boolean[], ?::text[], ?::text[]) "+
+			"AS i (key, username, full_name, phone_number, email, active, data, hash) "+
+			"ON "+on,
+			append([]any{keys, usernames, fullNames, phoneNumbers, emails, active, data, hashes}, onArgs...)...).
+		JoinClause("CROSS JOIN LATERAL (VALUES "+
+			"('full_name', COALESCE(u.full_name, '') <> i.full_name), "+
+			"('phone_number', COALESCE(u.phone_number, '') <> i.phone_number), "+
+			"('email', u.email <> i.email), "+
+			"('active', COALESCE(u.active, false) <> i.active), "+
+			"('data', u.data IS DISTINCT FROM i.data)"+
+			") AS a (attribute, differs)").
+		Where("a.differs").
+		Where("(u.attribute_sources ->> a.attribute)::INTEGER IS DISTINCT FROM ?", sourceID).
+		Where("u.source_hashes ->> (?::INTEGER)::text IS DISTINCT FROM i.hash", sourceID)
+
+	return r.QueryBuilder().
+		Insert(domain.TableIdentityConflict).
+		Columns(
+			domain.ColConflictUserID,
+			domain.ColAttribute,
+			domain.ColSourceID,
+			domain.ColCurrentSourceID,
+			domain.ColWinnerID,
+		).
+		Select(selectQuery).
+		Suffix(fmt.Sprintf("ON CONFLICT (%[1]s, %[2]s, %[3]s) DO UPDATE SET "+
+			"%[4]s = EXCLUDED.%[4]s, %[5]s = EXCLUDED.%[5]s, %[6]s = NOW()",
+			domain.ColConflictUserID, domain.ColAttribute, domain.ColSourceID,
+			domain.ColCurrentSourceID, domain.ColWinnerID, domain.ColUpdatedAt))
+}
+
+// BuildRenameQuery renames the users linked to the external ids of the source whose username changed at
+// the source, such as an Active Directory account renamed. A source only renames the users it holds, and
+// never onto the username of another user.
+func (r *UserRepository) BuildRenameQuery(sourceID uint64, users []*domain.User) squirrel.Sqlizer {
+	externalIDs, usernames := identities(users)
+	if len(externalIDs) == 0 {
+		return nil
+	}
+
+	linked := squirrel.
+		Select("l."+domain.ColIdentityUserID, "i.username").
+		From(domain.TableUserIdentity+" AS l").
+		Join("unnest(?::text[], ?::text[]) AS i (external_id, username) ON i.external_id = l.external_id",
+			externalIDs, usernames).
+		Where(squirrel.Eq{"l." + domain.ColSourceID: sourceID})
+
+	return r.QueryBuilder().
+		Update(domain.TableUser+" AS u").
+		Set(domain.ColUsername, squirrel.Expr("i.username")).
+		FromSelect(linked, "i").
+		Where("i.user_id = u.id AND u.username <> i.username").
+		Where("private.outranks(?, u.source_id)", sourceID).
+		Where("NOT EXISTS (SELECT 1 FROM private.user o WHERE o.username = i.username)")
+}
+
+// BuildLinkQuery links the external ids of the source that are not linked yet to the users upserted under
+// their username. It must run after the upsert.
+func (r *UserRepository) BuildLinkQuery(sourceID uint64, users []*domain.User) squirrel.Sqlizer {
+	externalIDs, usernames := identities(users)
+	if len(externalIDs) == 0 {
+		return nil
+	}
+
+	selectQuery := squirrel.
+		Select().
+		Column("?::INTEGER", sourceID).
+		Column("i.external_id").
+		Column("u."+domain.ColUserID).
+		From(domain.TableUser+" AS u").
+		Join("unnest(?::text[], ?::text[]) AS i (external_id, username) ON i.username = u.username",
+			externalIDs, usernames).
+		Where("NOT EXISTS (SELECT 1 FROM private.user_identity l WHERE l.source_id = ? AND l.external_id = i.external_id)",
+			sourceID)
+
+	return r.QueryBuilder().
+		Insert(domain.TableUserIdentity).
+		Columns(domain.ColSourceID, domain.ColExternalID, domain.ColIdentityUserID).
+		Select(selectQuery).
+		Suffix(fmt.Sprintf("ON CONFLICT (%s, %s) DO NOTHING", domain.ColSourceID, domain.ColExternalID))
+}
+
+// identities returns the external ids of the users that have one, with their usernames.
+func identities(users []*domain.User) (externalIDs, usernames []string) {
+	for _, user := range users {
+		if user.ExternalID != "" {
+			externalIDs = append(externalIDs, user.ExternalID)
+			usernames = append(usernames, user.Username)
+		}
+	}
+	return externalIDs, usernames
+}
+
+// GetByID only returns users owned by the given source.
+func (r *UserRepository) GetByID(ctx context.Context, sourceID uint64, id string) (*domain.User, error) {
+	return r.get(ctx, squirrel.Eq{domain.ColSourceID: sourceID, domain.ColUserID: id})
+}
+
+// HeldByHigherPriority reports whether the user of the username belongs to a connector the source does not
+// outrank, which the source cannot take over.
+func (r *UserRepository) HeldByHigherPriority(ctx context.Context, sourceID uint64, username string) (bool, error) {
+	query, args, err := r.QueryBuilder().
+		Select("1").
+		From(domain.TableUser).
+		Where(squirrel.Eq{domain.ColUsername: username}).
+		Where("NOT private.outranks(?, "+domain.ColSourceID+")", sourceID).
+		Prefix("SELECT EXISTS (").
+		Suffix(")").
+		ToSql()
+	if err != nil {
+		return false, err
+	}
+
+	var held bool
+	err = r.Pool().QueryRow(ctx, query, args...).Scan(&held)
+	return held, err
+}
+
+func (r *UserRepository) GetByUsername(ctx context.Context, sourceID uint64, username string) (*domain.User, error) {
+	return r.get(ctx, squirrel.Eq{domain.ColSourceID: sourceID, domain.ColUsername: username})
+}
+
+// GetLinkedUsernames returns the usernames of the users linked to external ids of the source, by external id.
+func (r *UserRepository) GetLinkedUsernames(
+	ctx context.Context,
+	sourceID uint64,
+	externalIDs []string,
+) (map[string]string, error) {
+	query, args, err := r.QueryBuilder().
+		Select("l."+domain.ColExternalID, "u."+domain.ColUsername).
+		From(domain.TableUserIdentity+" AS l").
+		Join(domain.TableUser+" AS u ON u.id = l."+domain.ColIdentityUserID).
+		Where(squirrel.Eq{"l." + domain.ColSourceID: sourceID}).
+		Where("l."+domain.ColExternalID+" = ANY(?)", externalIDs).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.Pool().Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	linked := make(map[string]string, len(externalIDs))
+	for rows.Next() {
+		var externalID, username string
+		err = rows.Scan(&externalID, &username)
+		if err != nil {
+			return nil, err
+		}
+		linked[externalID] = username
+	}
+	return linked, rows.Err()
+}
+
+// List returns a page of the users owned by the given source matching the column filter, and their total count.
+func (r *UserRepository) List(
+	ctx context.Context,
+	sourceID uint64,
+	filter map[string]any,
+	offset, limit uint64,
+) ([]*domain.User, uint64, error) {
+	where := squirrel.And{squirrel.Eq{domain.ColSourceID: sourceID}}
+	if len(filter) > 0 {
+		where = append(where, squirrel.Eq(filter))
+	}
+
+	query, args, err := r.QueryBuilder().
+		Select("COUNT(*)").
+		From(domain.TableUser).
+		Where(where).
+		ToSql()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var total uint64
+	err = r.Pool().QueryRow(ctx, query, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	query, args, err = r.QueryBuilder().
+		Select(domain.AllUserCols...).
+		From(domain.TableUser).
+		Where(where).
+		OrderBy(fmt.Sprintf("%s ASC", domain.ColCreatedAt), fmt.Sprintf("%s ASC", domain.ColUserID)).
+		Offset(offset).
+		Limit(limit).
+		ToSql()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.Pool().Query(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	users := make([]*domain.User, 0)
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		users = append(users, user)
+	}
+
+	return users, total, rows.Err()
+}
+
+// BuildReplaceQueries replace a user of the source in place by its id, in order: the conflicts, the update
+// and the identity of the source. Unlike the upsert it allows renaming the username.
+func (r *UserRepository) BuildReplaceQueries(user *domain.User) []squirrel.Sqlizer {
+	queries := []squirrel.Sqlizer{
+		r.recordConflicts(*user.SourceID, []*domain.User{user}, []string{user.UserID}, "u.id = i.key::UUID"),
+		r.BuildUpdateQuery(user),
+	}
+	return append(queries, r.BuildReplaceIdentityQueries(*user.SourceID, user)...)
+}
+
+// BuildUpdateQuery updates a user of the source by its id, each attribute being written only when
+// private.source_wins lets the source write it, as the upsert does.
+func (r *UserRepository) BuildUpdateQuery(user *domain.User) squirrel.Sqlizer {
+	values := map[string]any{
+		domain.ColFullName:    user.FullName,
+		domain.ColPhoneNumber: user.PhoneNumber,
+		domain.ColEmail:       user.Email,
+		domain.ColActive:      user.Active,
+		domain.ColData:        user.Data,
+	}
+
+	updateQuery := r.QueryBuilder().
+		Update(domain.TableUser+" AS u").
+		Set(domain.ColUsername, user.Username)
+	for _, col := range domain.AttributeUserCols {
+		updateQuery = updateQuery.Set(col, squirrel.Expr(
+			fmt.Sprintf("CASE WHEN %s THEN ? ELSE u.%s END", sourceWins(col, "?::INTEGER"), col),
+			user.SourceID, values[col]))
+	}
+	return updateQuery.
+		Set(domain.ColEmailVerified, squirrel.Expr("COALESCE(?, u."+domain.ColEmailVerified+")", user.EmailVerified)).
+		Set(domain.ColAttributeSources, squirrel.Expr("private.merge_sources(u.attribute_sources, ?)", user.SourceID)).
+		Set(domain.ColUpdatedAt, user.UpdatedAt).
+		Set(domain.ColSourceHashes, squirrel.Expr(
+			"u."+domain.ColSourceHashes+" || jsonb_build_object((?::INTEGER)::text, ?::text)", user.SourceID, user.Hash)).
+		Where(squirrel.Eq{"u." + domain.ColSourceID: user.SourceID, "u." + domain.ColUserID: user.UserID})
+}
+
+// BuildReplaceIdentityQueries links the user to its external id at the source in place of the previous
+// one, a user without external id is no longer linked to the source.
+func (r *UserRepository) BuildReplaceIdentityQueries(sourceID uint64, user *domain.User) []squirrel.Sqlizer {
+	deleteQuery := r.QueryBuilder().
+		Delete(domain.TableUserIdentity).
+		Where(squirrel.Eq{domain.ColSourceID: sourceID, domain.ColIdentityUserID: user.UserID}).
+		Where(squirrel.NotEq{domain.ColExternalID: user.ExternalID})
+	if user.ExternalID == "" {
+		return []squirrel.Sqlizer{deleteQuery}
+	}
+
+	insertQuery := r.QueryBuilder().
+		Insert(domain.TableUserIdentity).
+		Columns(domain.ColSourceID, domain.ColExternalID, domain.ColIdentityUserID).
+		Values(sourceID, user.ExternalID, user.UserID).
+		Suffix(fmt.Sprintf("ON CONFLICT (%s, %s) DO NOTHING", domain.ColSourceID, domain.ColExternalID))
+	return []squirrel.Sqlizer{deleteQuery, insertQuery}
+}
+
+// BuildDeprovisionQuery deactivates a user, the row is kept so that its history and references survive.
+func (r *UserRepository) BuildDeprovisionQuery(sourceID uint64, id string, at time.Time) squirrel.Sqlizer {
+	return r.QueryBuilder().
+		Update(domain.TableUser).
+		Set(domain.ColActive, false).
+		Set(domain.ColUpdatedAt, at).
+		Where(squirrel.Eq{domain.ColSourceID: sourceID, domain.ColUserID: id})
+}
+
 func (r *UserRepository) ExecuteTransaction(ctx context.Context, queries []squirrel.Sqlizer) error {
 	tx, err := r.Pool().Begin(ctx)
 	if err != nil {
@@ -63,7 +517,20 @@ func (r *UserRepository) ExecuteTransaction(ctx context.Context, queries []squir
 			return err
 		}
 
-		_, err = tx.Exec(ctx, sqlStr, args...)
+		start := time.Now()
+		if counted, ok := query.(*CountedQuery); ok {
+			err = countRows(ctx, tx, counted, sqlStr, args)
+		} else {
+			_, err = tx.Exec(ctx, sqlStr, args...)
+		}
+		if err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+				return fmt.Errorf("%w: %w", utils.ErrUserConflict, err)
+			}
+			return err
+		}
+		metrics.PostgresBatchDuration.Observe(time.Since(start).Seconds())
 	}
 
 	if err := tx.Commit(ctx); err != nil {
@@ -72,3 +539,131 @@ func (r *UserRepository) ExecuteTransaction(ctx context.Context, queries []squir
 
 	return nil
 }
+
+// countRows runs an upsert returning the id of each written row, whether it was created and whether it
+// changed, the rows that did not change are left to the unchanged count.
+func countRows(ctx context.Context, tx pgx.Tx, counted *CountedQuery, sqlStr string, args []any) error {
+	rows, err := tx.Query(ctx, sqlStr, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+		var created, changed bool
+		err = rows.Scan(&id, &created, &changed)
+		if err != nil {
+			return err
+		}
+
+		counted.IDs = append(counted.IDs, id)
+		if created {
+			counted.Result.Created++
+		} else if changed {
+			counted.Result.Updated++
+		}
+	}
+	return rows.Err()
+}
+
+func (r *UserRepository) get(ctx context.Context, where squirrel.Sqlizer) (*domain.User, error) {
+	query, args, err := r.QueryBuilder().
+		Select(domain.AllUserCols...).
+		From(domain.TableUser).
+		Where(where).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := scanUser(r.Pool().QueryRow(ctx, query, args...))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, utils.ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// scanUser reads the columns of AllUserCols, most of which are nullable.
+func scanUser(row interface{ Scan(dest ...any) error }) (*domain.User, error) {
+	var (
+		user                  domain.User
+		sourceID              uint64
+		fullName, phoneNumber *string
+		sourceDN, data        *string
+		emailVerified, active *bool
+	)
+
+	err := row.Scan(
+		&user.UserID,
+		&user.Username,
+		&fullName,
+		&phoneNumber,
+		&user.Email,
+		&emailVerified,
+		&active,
+		&sourceID,
+		&sourceDN,
+		&data,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	user.SourceID = &sourceID
+	if fullName != nil {
+		user.FullName = *fullName
+	}
+	if phoneNumber != nil {
+		user.PhoneNumber = *phoneNumber
+	}
+	if sourceDN != nil {
+		user.SourceDN = *sourceDN
+	}
+	if data != nil {
+		user.Data = sqlxx.TextData{Raw: []byte(*data)}
+		// rows written before the data held JSON keep their raw value only
+		_ = user.Data.ParseJSON()
+	}
+	user.EmailVerified = emailVerified
+	user.Active = active != nil && *active
+
+	return &user, nil
+}
+
+// textData returns the JSON text stored for a data column.
+func textData(data sqlxx.TextData) *string {
+	value, err := data.Value()
+	if err != nil {
+		return nil
+	}
+
+	text, ok := value.([]byte)
+	if !ok || text == nil {
+		return nil
+	}
+	s := string(text)
+	return &s
+}
+
+// nullString stores an empty optional column as NULL.
+func nullString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// nullTime leaves a timestamp the source did not provide to the database.
+func nullTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,102 @@
+package pgrepo_test
+
+import (
+	"github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/tuanta7/qworker/internal/domain"
+	pgrepo "github.com/tuanta7/qworker/internal/repository/postgres"
+	"strings"
+	"testing"
+)
+
+type queryBuilderOnly struct{}
+
+func (queryBuilderOnly) Pool() *pgxpool.Pool { return nil }
+func (queryBuilderOnly) QueryBuilder() squirrel.StatementBuilderType {
+	return squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+}
+func (queryBuilderOnly) Close() {}
+
+func TestUserPrecedence(t *testing.T) {
+	sourceID := uint64(2)
+	users := []*domain.User{
+		{Username: "alice", ExternalID: "1", Email: "alice@example.com", SourceID: &sourceID},
+		{Username: "bob", Email: "bob@example.com", SourceID: &sourceID},
+	}
+	r := pgrepo.NewUserRepository(queryBuilderOnly{})
+
+	t.Run("upsert", func(t *testing.T) {
+		query, args, err := r.BuildBulkUpsertQuery(users).ToSql()
+		assert.NoError(t, err)
+		assert.Len(t, args, 3+2*(len(domain.AllUserSyncCols)+5)+2)
+		assert.Equal(t, []any{[]string{"alice", "bob"}, &sourceID, []string{"1"}}, args[:3])
+		assert.True(t, strings.HasPrefix(query, "WITH snapshot AS (SELECT id, "))
+		for _, col := range domain.AttributeUserCols {
+			assert.Contains(t, query, "private.source_wins('"+col+"', EXCLUDED.source_id, u.attribute_sources)")
+		}
+		assert.Contains(t, query, "source_id = CASE WHEN private.outranks(EXCLUDED.source_id, u.source_id)")
+		assert.Contains(t, query, "source_dns = u.source_dns || EXCLUDED.source_dns")
+		assert.NotContains(t, query, "created_at = EXCLUDED.created_at")
+		assert.Contains(t, query, "updated_at = CASE WHEN (private.source_wins(")
+		assert.Contains(t, query, "AND EXCLUDED.email IS DISTINCT FROM u.email) OR ")
+		assert.Contains(t, query,
+			"WHERE u.source_hashes IS DISTINCT FROM u.source_hashes || EXCLUDED.source_hashes RETURNING u.id, xmax = 0, NOT EXISTS (")
+	})
+
+	t.Run("conflicts", func(t *testing.T) {
+		query, args, err := r.BuildRecordConflictsQuery(sourceID, users).ToSql()
+		assert.NoError(t, err)
+		assert.Len(t, args, 14)
+		assert.Equal(t, []any{sourceID, sourceID, sourceID}, args[:3])
+		assert.Equal(t, []string{"1", ""}, args[3])
+		assert.Equal(t, []any{sourceID, sourceID, sourceID}, args[11:])
+		assert.True(t, strings.HasPrefix(query, "INSERT INTO private.identity_conflict"))
+		assert.Contains(t, query, "$14")
+	})
+
+	t.Run("replace", func(t *testing.T) {
+		user := &domain.User{UserID: "0d8151f7-2e5c-464d-90d0-b0ef1a8ec1f9", Username: "alice", ExternalID: "2",
+			Email: "alice@example.com", SourceID: &sourceID}
+		queries := r.BuildReplaceQueries(user)
+		assert.Len(t, queries, 4)
+
+		query, args, err := queries[0].ToSql()
+		assert.NoError(t, err)
+		assert.True(t, strings.HasPrefix(query, "INSERT INTO private.identity_conflict"))
+		assert.Contains(t, query, "ON u.id = i.key::UUID")
+		assert.Equal(t, []string{user.UserID}, args[3])
+
+		query, _, err = queries[1].ToSql()
+		assert.NoError(t, err)
+		assert.Contains(t, query, "email = CASE WHEN private.source_wins('email', $")
+		assert.Contains(t, query, "attribute_sources = private.merge_sources(u.attribute_sources, $")
+
+		query, args, err = queries[2].ToSql()
+		assert.NoError(t, err)
+		assert.Equal(t, "DELETE FROM private.user_identity WHERE source_id = $1 AND user_id = $2 AND external_id <> $3", query)
+		assert.Equal(t, []any{sourceID, user.UserID, "2"}, args)
+
+		user.ExternalID = ""
+		assert.Len(t, r.BuildReplaceQueries(user), 3)
+	})
+
+	t.Run("identities", func(t *testing.T) {
+		result := &domain.SyncResult{}
+		queries := r.BuildBulkSyncQueries(sourceID, users, result)
+		assert.Len(t, queries, 4)
+		assert.Equal(t, result, queries[2].(*pgrepo.CountedQuery).Result)
+
+		query, args, err := queries[0].ToSql()
+		assert.NoError(t, err)
+		assert.True(t, strings.HasPrefix(query, "UPDATE private.user AS u SET username = i.username"))
+		assert.Equal(t, []any{[]string{"1"}, []string{"alice"}, sourceID, sourceID}, args)
+
+		query, args, err = queries[3].ToSql()
+		assert.NoError(t, err)
+		assert.True(t, strings.HasPrefix(query, "INSERT INTO private.user_identity"))
+		assert.Equal(t, []any{sourceID, []string{"1"}, []string{"alice"}, sourceID}, args)
+
+		assert.Len(t, r.BuildBulkSyncQueries(sourceID, users[1:], nil), 2)
+	})
+}
@@ -0,0 +1,235 @@
+package pgrepo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/Masterminds/squirrel"
+	"github.com/tuanta7/qworker/internal/domain"
+	"github.com/tuanta7/qworker/pkg/db"
+	"github.com/tuanta7/qworker/pkg/utils"
+)
+
+type WebhookRepository struct {
+	db.PostgresClient
+}
+
+func NewWebhookRepository(pc db.PostgresClient) *WebhookRepository {
+	return &WebhookRepository{pc}
+}
+
+func (r *WebhookRepository) ListEnabledSubscriptions(ctx context.Context) ([]*domain.WebhookSubscription, error) {
+	query, args, err := r.QueryBuilder().
+		Select(domain.AllWebhookSubscriptionCols...).
+		From(domain.TableWebhookSubscription).
+		Where(squirrel.Eq{domain.ColEnabled: true}).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.Pool().Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subscriptions := make([]*domain.WebhookSubscription, 0)
+	for rows.Next() {
+		s, err := scanSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		subscriptions = append(subscriptions, s)
+	}
+
+	return subscriptions, rows.Err()
+}
+
+func (r *WebhookRepository) GetSubscription(ctx context.Context, id uint64) (*domain.WebhookSubscription, error) {
+	query, args, err := r.QueryBuilder().
+		Select(domain.AllWebhookSubscriptionCols...).
+		From(domain.TableWebhookSubscription).
+		Where(squirrel.Eq{domain.ColSubscriptionID: id}).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := scanSubscription(r.Pool().QueryRow(ctx, query, args...))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, utils.ErrSubscriptionNotFound
+		}
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (r *WebhookRepository) GetEvent(ctx context.Context, id uint64) (*domain.UserEvent, error) {
+	query, args, err := r.QueryBuilder().
+		Select(domain.AllUserEventCols...).
+		From(domain.TableUserEvent).
+		Where(squirrel.Eq{domain.ColEventID: id}).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	e, err := scanEvent(r.Pool().QueryRow(ctx, query, args...))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, utils.ErrEventNotFound
+		}
+		return nil, err
+	}
+
+	return e, nil
+}
+
+// DispatchEvents locks the oldest events not dispatched yet, up to limit, and marks them dispatched once
+// dispatch returns without error. Events locked by a concurrent dispatcher are skipped.
+func (r *WebhookRepository) DispatchEvents(
+	ctx context.Context,
+	limit uint64,
+	dispatch func(events []*domain.UserEvent) error,
+) (int, error) {
+	tx, err := r.Pool().Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	query, args, err := r.QueryBuilder().
+		Select(domain.AllUserEventCols...).
+		From(domain.TableUserEvent).
+		Where(squirrel.Eq{domain.ColDispatchedAt: nil}).
+		OrderBy(domain.ColEventID).
+		Limit(limit).
+		Suffix("FOR UPDATE SKIP LOCKED").
+		ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := tx.Query(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	var events []*domain.UserEvent
+	var ids []uint64
+	for rows.Next() {
+		e, err := scanEvent(rows)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		events = append(events, e)
+		ids = append(ids, e.EventID)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(events) == 0 {
+		return 0, nil
+	}
+
+	err = dispatch(events)
+	if err != nil {
+		return 0, err
+	}
+
+	query, args, err = r.QueryBuilder().
+		Update(domain.TableUserEvent).
+		Set(domain.ColDispatchedAt, squirrel.Expr("NOW()")).
+		Where(squirrel.Eq{domain.ColEventID: ids}).
+		ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(events), tx.Commit(ctx)
+}
+
+func (r *WebhookRepository) InsertDelivery(ctx context.Context, d *domain.WebhookDelivery) error {
+	query, args, err := r.QueryBuilder().
+		Insert(domain.TableWebhookDelivery).
+		Columns(
+			domain.ColDeliverySubscriptionID,
+			domain.ColDeliveryEventID,
+			domain.ColAttempt,
+			domain.ColStatusCode,
+			domain.ColError,
+			domain.ColDurationMs,
+			domain.ColCreatedAt,
+		).
+		Values(
+			d.SubscriptionID,
+			d.EventID,
+			d.Attempt,
+			nullInt(d.StatusCode),
+			nullString(d.Error),
+			d.Duration.Milliseconds(),
+			d.CreatedAt,
+		).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = r.Pool().Exec(ctx, query, args...)
+	return err
+}
+
+func scanSubscription(row interface{ Scan(dest ...any) error }) (*domain.WebhookSubscription, error) {
+	s := &domain.WebhookSubscription{}
+	var events []string
+	err := row.Scan(
+		&s.SubscriptionID,
+		&s.URL,
+		&s.Secret,
+		&events,
+		&s.Enabled,
+		&s.CreatedAt,
+		&s.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range events {
+		s.Events = append(s.Events, domain.UserEventType(e))
+	}
+	return s, nil
+}
+
+func scanEvent(row interface{ Scan(dest ...any) error }) (*domain.UserEvent, error) {
+	e := &domain.UserEvent{}
+	err := row.Scan(
+		&e.EventID,
+		&e.UserID,
+		&e.Type,
+		&e.Payload,
+		&e.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// nullInt stores a zero optional column as NULL.
+func nullInt(i int) *int {
+	if i == 0 {
+		return nil
+	}
+	return &i
+}
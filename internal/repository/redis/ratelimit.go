@@ -0,0 +1,84 @@
+package redisrepo
+
+import (
+	"context"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+// pageWindowScript counts pages in a one-second window and returns how many milliseconds
+// the caller has to wait, 0 means the page is allowed.
+var pageWindowScript = redis.NewScript(`
+local n = redis.call("INCR", KEYS[1])
+if n == 1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+if n <= tonumber(ARGV[2]) then
+	return 0
+end
+local ttl = redis.call("PTTL", KEYS[1])
+if ttl < 0 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+	return tonumber(ARGV[1])
+end
+return ttl
+`)
+
+// semaphoreAcquireScript keeps holders in a sorted set scored by lease expiry, so slots held by
+// crashed workers are reclaimed once their lease runs out. Redis time is used to avoid clock skew.
+var semaphoreAcquireScript = redis.NewScript(`
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now)
+if redis.call("ZCARD", KEYS[1]) < tonumber(ARGV[1]) then
+	redis.call("ZADD", KEYS[1], now + tonumber(ARGV[2]), ARGV[3])
+	redis.call("PEXPIRE", KEYS[1], ARGV[2])
+	return 1
+end
+return 0
+`)
+
+const pageWindow = time.Second
+
+type RateLimitRepository struct {
+	*redis.Client
+}
+
+func NewRateLimitRepository(client *redis.Client) *RateLimitRepository {
+	return &RateLimitRepository{client}
+}
+
+// TakePage reserves a page in the current window of the connector and returns the time to wait
+// before retrying when the window is full.
+func (r *RateLimitRepository) TakePage(ctx context.Context, connectorID uint64, maxPerSecond uint32) (time.Duration, error) {
+	key := fmt.Sprintf("qworker:ratelimit:{%d}:pages", connectorID)
+	wait, err := pageWindowScript.Run(ctx, r.Client, []string{key}, pageWindow.Milliseconds(), maxPerSecond).Int64()
+	if err != nil {
+		return 0, err
+	}
+
+	return time.Duration(wait) * time.Millisecond, nil
+}
+
+// AcquireSearch takes one of the maxConcurrent search slots of the connector for at most lease.
+func (r *RateLimitRepository) AcquireSearch(
+	ctx context.Context,
+	connectorID uint64,
+	holder string,
+	maxConcurrent uint32,
+	lease time.Duration,
+) (bool, error) {
+	key := fmt.Sprintf("qworker:ratelimit:{%d}:searches", connectorID)
+	ok, err := semaphoreAcquireScript.Run(ctx, r.Client, []string{key}, maxConcurrent, lease.Milliseconds(), holder).Int()
+	if err != nil {
+		return false, err
+	}
+
+	return ok == 1, nil
+}
+
+func (r *RateLimitRepository) ReleaseSearch(ctx context.Context, connectorID uint64, holder string) error {
+	key := fmt.Sprintf("qworker:ratelimit:{%d}:searches", connectorID)
+	return r.Client.ZRem(ctx, key, holder).Err()
+}
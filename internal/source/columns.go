@@ -0,0 +1,144 @@
+package source
+
+import (
+	"fmt"
+	"github.com/tuanta7/qworker/internal/domain"
+	"github.com/tuanta7/qworker/internal/mapping"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultColumnMapper maps records whose attributes are columns named after the user fields, such as
+// file exports and SQL rows. Fields left empty in the connector mapper fall back to it.
+var DefaultColumnMapper = domain.Mapper{
+	ExternalID:  "id",
+	Username:    "username",
+	FullName:    "full_name",
+	Email:       "email",
+	PhoneNumber: "phone_number",
+	CreatedAt:   "created_at",
+	UpdatedAt:   "updated_at",
+	Custom: map[string]string{
+		"active": "active",
+	},
+}
+
+// columnTimeLayouts are tried in order for the created and updated columns.
+var columnTimeLayouts = []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999", "2006-01-02"}
+
+func WithDefaultColumns(m domain.Mapper) domain.Mapper {
+	fields := []struct {
+		value    *string
+		fallback string
+	}{
+		{&m.ExternalID, DefaultColumnMapper.ExternalID},
+		{&m.Username, DefaultColumnMapper.Username},
+		{&m.FullName, DefaultColumnMapper.FullName},
+		{&m.Email, DefaultColumnMapper.Email},
+		{&m.PhoneNumber, DefaultColumnMapper.PhoneNumber},
+		{&m.CreatedAt, DefaultColumnMapper.CreatedAt},
+		{&m.UpdatedAt, DefaultColumnMapper.UpdatedAt},
+	}
+	for _, f := range fields {
+		if *f.value == "" {
+			*f.value = f.fallback
+		}
+	}
+
+	if _, ok := m.Custom["active"]; !ok {
+		custom := map[string]string{"active": DefaultColumnMapper.Custom["active"]}
+		for k, v := range m.Custom {
+			custom[k] = v
+		}
+		m.Custom = custom
+	}
+
+	return m
+}
+
+// MapColumns maps a record of columns. Exports and HR tables usually only list current staff, so a
+// missing or empty active column means active.
+func MapColumns(record *Record, m *mapping.Mapping) (*domain.User, error) {
+	values := func(attr string) []string { return Values(record, attr) }
+	column := func(name string) string { return strings.TrimSpace(m.Value(name, values)) }
+
+	now := time.Now()
+	createdAt, err := parseColumnTime(column(domain.MappingCreatedAt), now)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", domain.MappingCreatedAt, err)
+	}
+
+	updatedAt, err := parseColumnTime(column(domain.MappingUpdatedAt), now)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", domain.MappingUpdatedAt, err)
+	}
+
+	active := true
+	if value := column("active"); value != "" && !m.HasStatus(domain.StatusActive) {
+		active, err = strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("active: %w", err)
+		}
+	}
+
+	data, err := CustomData(m, values)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &domain.User{
+		ExternalID:  column(domain.MappingExternalID),
+		Username:    column(domain.MappingUsername),
+		FullName:    column(domain.MappingFullName),
+		PhoneNumber: column(domain.MappingPhoneNumber),
+		Email:       column(domain.MappingEmail),
+		Active:      active,
+		Data:        data,
+		CreatedAt:   createdAt,
+		UpdatedAt:   updatedAt,
+	}
+	return user, ApplyStatus(user, m, values)
+}
+
+// Column returns the first value of a column, trimmed.
+func Column(record *Record, name string) string {
+	values := Values(record, name)
+	if len(values) == 0 {
+		return ""
+	}
+	return strings.TrimSpace(values[0])
+}
+
+// Values returns the values of a column, column names are matched case-insensitively.
+func Values(record *Record, name string) []string {
+	if name == "" {
+		return nil
+	}
+
+	values, ok := record.Attributes[name]
+	if !ok {
+		for column, v := range record.Attributes {
+			if strings.EqualFold(column, name) {
+				return v
+			}
+		}
+	}
+	return values
+}
+
+func parseColumnTime(value string, fallback time.Time) (time.Time, error) {
+	if value == "" {
+		return fallback, nil
+	}
+
+	var err error
+	for _, layout := range columnTimeLayouts {
+		var t time.Time
+		t, err = time.Parse(layout, value)
+		if err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
@@ -0,0 +1,91 @@
+package source
+
+import (
+	"encoding/base64"
+	"fmt"
+	"github.com/tuanta7/qworker/internal/domain"
+	"github.com/tuanta7/qworker/internal/mapping"
+	"github.com/tuanta7/qworker/pkg/sqlxx"
+	"github.com/tuanta7/qworker/pkg/utils"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CustomData converts the values of every custom mapping into the JSON object stored in User.Data, values
+// returns the values of a source attribute. Mappings without values are left out.
+func CustomData(m *mapping.Mapping, values func(attr string) []string) (sqlxx.TextData, error) {
+	data := make(map[string]any, len(m.Custom()))
+	for _, key := range m.Custom() {
+		raw := m.Values(key, values)
+		if len(raw) == 0 {
+			continue
+		}
+
+		value, err := convertAttribute(raw, m.Type(key))
+		if err != nil {
+			return sqlxx.TextData{}, fmt.Errorf("%s: %w", key, err)
+		}
+		data[key] = value
+	}
+
+	if len(data) == 0 {
+		return sqlxx.TextData{}, nil
+	}
+	return sqlxx.TextData{Parsed: data}, nil
+}
+
+func convertAttribute(raw []string, hint domain.AttributeType) (any, error) {
+	elem, multi := strings.CutSuffix(string(hint), "[]")
+	if hint == "" {
+		multi = len(raw) > 1
+	}
+
+	converted := make([]any, len(raw))
+	for i, value := range raw {
+		var err error
+		converted[i], err = convertValue(value, domain.AttributeType(elem))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if multi {
+		return converted, nil
+	}
+	return converted[0], nil
+}
+
+func convertValue(value string, t domain.AttributeType) (any, error) {
+	switch t {
+	case "", domain.AttributeString:
+		return value, nil
+	case domain.AttributeInt:
+		return strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	case domain.AttributeBool:
+		return strconv.ParseBool(strings.TrimSpace(value))
+	case domain.AttributeTime:
+		t, err := ParseTime(strings.TrimSpace(value))
+		if err != nil {
+			return nil, err
+		}
+		return t.UTC().Format(time.RFC3339Nano), nil
+	case domain.AttributeBinary:
+		return base64.StdEncoding.EncodeToString([]byte(value)), nil
+	default:
+		return nil, fmt.Errorf("unsupported attribute type: %q", t)
+	}
+}
+
+// ParseTime accepts the column layouts and the LDAP generalized time.
+func ParseTime(value string) (time.Time, error) {
+	t, err := parseColumnTime(value, time.Time{})
+	if err == nil {
+		return t, nil
+	}
+
+	if t, ldapErr := utils.ParseGeneralizedTime(value); ldapErr == nil {
+		return t, nil
+	}
+	return time.Time{}, err
+}
@@ -0,0 +1,84 @@
+package source
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/tuanta7/qworker/internal/domain"
+	"github.com/tuanta7/qworker/internal/mapping"
+	"github.com/tuanta7/qworker/pkg/sqlxx"
+	"testing"
+)
+
+func compile(t *testing.T, m domain.Mapper) *mapping.Mapping {
+	compiled, err := mapping.Compile(m)
+	assert.Equal(t, nil, err)
+	return compiled
+}
+
+func TestCustomData(t *testing.T) {
+	attributes := map[string][]string{
+		"proxyAddresses":  {"SMTP:jdoe@example.com", "smtp:john.doe@example.com"},
+		"otherMobile":     {"+84901234567"},
+		"department":      {"Engineering"},
+		"employeeNumber":  {"1024"},
+		"lockoutTime":     {"20250301100000.0Z"},
+		"hireDate":        {"2024-05-06"},
+		"enabled":         {"TRUE"},
+		"objectGUID":      {"\x01\x02\xff"},
+		"badgeNumbers":    {"7", "8"},
+		"unusedAttribute": {"x"},
+	}
+	values := func(attr string) []string { return attributes[attr] }
+
+	t.Run("type_hints", func(t *testing.T) {
+		data, err := CustomData(compile(t, domain.Mapper{
+			Custom: map[string]string{
+				"emails":      "proxyAddresses",
+				"mobiles":     "otherMobile",
+				"department":  "department",
+				"employee":    "employeeNumber",
+				"lockedAt":    "lockoutTime",
+				"hiredAt":     "hireDate",
+				"enabled":     "enabled",
+				"guid":        "objectGUID",
+				"badges":      "badgeNumbers",
+				"missingAttr": "manager",
+			},
+			CustomTypes: map[string]domain.AttributeType{
+				"mobiles":  "string[]",
+				"employee": domain.AttributeInt,
+				"lockedAt": domain.AttributeTime,
+				"hiredAt":  domain.AttributeTime,
+				"enabled":  domain.AttributeBool,
+				"guid":     domain.AttributeBinary,
+				"badges":   "int[]",
+			},
+		}), values)
+
+		assert.Equal(t, nil, err)
+		assert.Equal(t, sqlxx.TextData{Parsed: map[string]any{
+			"emails":     []any{"SMTP:jdoe@example.com", "smtp:john.doe@example.com"},
+			"mobiles":    []any{"+84901234567"},
+			"department": "Engineering",
+			"employee":   int64(1024),
+			"lockedAt":   "2025-03-01T10:00:00Z",
+			"hiredAt":    "2024-05-06T00:00:00Z",
+			"enabled":    true,
+			"guid":       "AQL/",
+			"badges":     []any{int64(7), int64(8)},
+		}}, data)
+	})
+
+	t.Run("invalid_value", func(t *testing.T) {
+		_, err := CustomData(compile(t, domain.Mapper{
+			Custom:      map[string]string{"employee": "department"},
+			CustomTypes: map[string]domain.AttributeType{"employee": domain.AttributeInt},
+		}), values)
+		assert.EqualError(t, err, `employee: strconv.ParseInt: parsing "Engineering": invalid syntax`)
+	})
+
+	t.Run("no_values", func(t *testing.T) {
+		data, err := CustomData(compile(t, domain.Mapper{Custom: map[string]string{"manager": "manager"}}), values)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, sqlxx.TextData{}, data)
+	})
+}
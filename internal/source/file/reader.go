@@ -0,0 +1,223 @@
+package filesource
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/tuanta7/qworker/internal/domain"
+	"github.com/tuanta7/qworker/internal/source"
+	"hash"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxLineSize bounds a single JSONL line, exports with larger objects should use the json format.
+const maxLineSize = 1 << 20
+
+// recordReader streams the records of a file one at a time. Read returns io.EOF after the last record.
+type recordReader interface {
+	Read() (map[string][]string, error)
+	// Position locates the last record read, for rejected records and error reports.
+	Position() string
+}
+
+// fileReader reads a file while hashing it, so that the checksum is known once the last record is read.
+type fileReader struct {
+	recordReader
+	file *os.File
+	hash hash.Hash
+}
+
+func openFile(path string, format domain.FileFormat, delimiter string) (*fileReader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	h := sha256.New()
+	r := io.TeeReader(file, h)
+
+	var reader recordReader
+	switch format {
+	case domain.FileFormatCSV:
+		reader, err = newCSVReader(r, delimiter)
+	case domain.FileFormatJSON:
+		reader, err = newJSONReader(r)
+	case domain.FileFormatJSONL:
+		reader = newJSONLReader(r)
+	default:
+		err = fmt.Errorf("unsupported file format %q", format)
+	}
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	return &fileReader{recordReader: reader, file: file, hash: h}, nil
+}
+
+// Checksum drains what the parser left unread, such as trailing whitespace, and returns the SHA-256.
+func (r *fileReader) Checksum() (string, error) {
+	_, err := io.Copy(r.hash, r.file)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", r.hash.Sum(nil)), nil
+}
+
+func (r *fileReader) Close() error {
+	return r.file.Close()
+}
+
+type csvReader struct {
+	reader *csv.Reader
+	header []string
+	line   int
+}
+
+func newCSVReader(r io.Reader, delimiter string) (*csvReader, error) {
+	reader := csv.NewReader(r)
+	reader.ReuseRecord = true
+	if delimiter != "" {
+		comma, size := utf8.DecodeRuneInString(delimiter)
+		if size != len(delimiter) {
+			return nil, fmt.Errorf("csv delimiter must be a single character, got %q", delimiter)
+		}
+		reader.Comma = comma
+	}
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("csv file has no header")
+		}
+		return nil, err
+	}
+
+	header = append([]string(nil), header...)
+	header[0] = strings.TrimPrefix(header[0], "\ufeff") // byte order mark of spreadsheet exports
+
+	return &csvReader{reader: reader, header: header, line: 1}, nil
+}
+
+func (r *csvReader) Read() (map[string][]string, error) {
+	row, err := r.reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	r.line, _ = r.reader.FieldPos(0)
+
+	attributes := make(map[string][]string, len(r.header))
+	for i, name := range r.header {
+		if row[i] != "" {
+			attributes[name] = []string{row[i]}
+		}
+	}
+	return attributes, nil
+}
+
+func (r *csvReader) Position() string {
+	return "line " + strconv.Itoa(r.line)
+}
+
+type jsonReader struct {
+	decoder *json.Decoder
+	index   int
+}
+
+func newJSONReader(r io.Reader) (*jsonReader, error) {
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return nil, errors.New("json file must hold an array of objects")
+	}
+
+	return &jsonReader{decoder: decoder}, nil
+}
+
+func (r *jsonReader) Read() (map[string][]string, error) {
+	if !r.decoder.More() {
+		token, err := r.decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		if delim, ok := token.(json.Delim); !ok || delim != ']' {
+			return nil, fmt.Errorf("unexpected %v after the last record", token)
+		}
+		return nil, io.EOF
+	}
+
+	r.index++
+	var object map[string]any
+	err := r.decoder.Decode(&object)
+	if err != nil {
+		return nil, err
+	}
+	if object == nil {
+		return nil, errors.New("record is not an object")
+	}
+
+	return source.Flatten(object), nil
+}
+
+func (r *jsonReader) Position() string {
+	return "record " + strconv.Itoa(r.index)
+}
+
+type jsonlReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func newJSONLReader(r io.Reader) *jsonlReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxLineSize)
+	return &jsonlReader{scanner: scanner}
+}
+
+func (r *jsonlReader) Read() (map[string][]string, error) {
+	for r.scanner.Scan() {
+		r.line++
+		line := r.scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		var object map[string]any
+		decoder := json.NewDecoder(bytes.NewReader(line))
+		decoder.UseNumber()
+		err := decoder.Decode(&object)
+		if err != nil {
+			return nil, err
+		}
+		if object == nil {
+			return nil, errors.New("record is not an object")
+		}
+		if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+			return nil, errors.New("unexpected data after the object")
+		}
+
+		return source.Flatten(object), nil
+	}
+
+	if err := r.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+func (r *jsonlReader) Position() string {
+	return "line " + strconv.Itoa(r.line)
+}
@@ -0,0 +1,387 @@
+package filesource
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"github.com/Masterminds/squirrel"
+	"github.com/tuanta7/qworker/internal/domain"
+	"github.com/tuanta7/qworker/internal/mapping"
+	"github.com/tuanta7/qworker/internal/source"
+	"github.com/tuanta7/qworker/pkg/logger"
+	"go.uber.org/zap"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	defaultBatchSize = 1000
+	defaultFailedDir = "failed"
+)
+
+var extensions = map[string]domain.FileFormat{
+	".csv":    domain.FileFormatCSV,
+	".json":   domain.FileFormatJSON,
+	".jsonl":  domain.FileFormatJSONL,
+	".ndjson": domain.FileFormatJSONL,
+}
+
+type ProcessedFileRepository interface {
+	Exists(ctx context.Context, sourceID uint64, checksum string) (bool, error)
+	BuildBulkInsertQuery(files []*domain.ProcessedFile) *squirrel.InsertBuilder
+}
+
+// Register adds the file source to the registry.
+func Register(r *source.Registry, processedFiles ProcessedFileRepository, zl *logger.ZapLogger) {
+	source.Register(r, domain.ConnectorTypeFile, source.JSONDecoder[domain.FileConnector](),
+		func(connector *domain.Connector, config *domain.FileConnector) (source.SyncSource, error) {
+			if config.Path == "" {
+				return nil, errors.New("file connector has no path")
+			}
+
+			m, err := mapping.Compile(source.WithDefaultColumns(connector.Mapper))
+			if err != nil {
+				return nil, err
+			}
+
+			return &Source{
+				connector:      connector,
+				config:         config,
+				mapping:        m,
+				processedFiles: processedFiles,
+				logger:         zl,
+			}, nil
+		})
+}
+
+// Source imports every file that has not been imported before, whatever the watermark. Files that cannot
+// be read are moved to the failed directory with an error report once the sync has committed, files that
+// cannot be parsed as soon as the error is met, which fails the sync.
+type Source struct {
+	connector      *domain.Connector
+	config         *domain.FileConnector
+	mapping        *mapping.Mapping
+	processedFiles ProcessedFileRepository
+	logger         *logger.ZapLogger
+
+	pending  []*candidate
+	failed   []*candidate
+	imported []*domain.ProcessedFile
+	current  *fileReader
+}
+
+type candidate struct {
+	path     string
+	format   domain.FileFormat
+	checksum string
+	records  int
+	err      error
+}
+
+// Connect hashes every candidate file, without parsing it, and skips the files whose content was already
+// imported. The records are parsed while iterating.
+func (s *Source) Connect(ctx context.Context) error {
+	candidates, err := s.listFiles()
+	if err != nil {
+		s.logger.Error("FileSource - Connect - s.listFiles", zap.String("path", s.config.Path), zap.Error(err))
+		return err
+	}
+
+	seen := make(map[string]struct{}, len(candidates))
+	for _, c := range candidates {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		c.checksum, c.err = checksumFile(c.path)
+		if c.err != nil {
+			s.logger.Warn("file rejected", zap.String("path", c.path), zap.Error(c.err))
+			s.failed = append(s.failed, c)
+			continue
+		}
+
+		if _, ok := seen[c.checksum]; ok {
+			continue
+		}
+		seen[c.checksum] = struct{}{}
+
+		processed, err := s.processedFiles.Exists(ctx, s.connector.ConnectorID, c.checksum)
+		if err != nil {
+			s.logger.Error("FileSource - Connect - s.processedFiles.Exists", zap.Error(err))
+			return err
+		}
+		if processed {
+			s.logger.Debug("file already imported", zap.String("path", c.path), zap.String("checksum", c.checksum))
+			continue
+		}
+
+		s.pending = append(s.pending, c)
+	}
+
+	return nil
+}
+
+func (s *Source) Iterate(_ time.Time) source.PageIterator {
+	batchSize := int(s.config.SyncSettings.BatchSize)
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+
+	return &pageIterator{source: s, files: s.pending, batchSize: batchSize}
+}
+
+func (s *Source) Map(record *source.Record) (*domain.User, error) {
+	return source.MapColumns(record, s.mapping)
+}
+
+func (s *Source) Mapping() *mapping.Mapping {
+	return s.mapping
+}
+
+func (s *Source) Values(record *source.Record) func(attr string) []string {
+	return func(attr string) []string { return source.Values(record, attr) }
+}
+
+func (s *Source) CommitQueries() []squirrel.Sqlizer {
+	if len(s.imported) == 0 {
+		return nil
+	}
+	return []squirrel.Sqlizer{s.processedFiles.BuildBulkInsertQuery(s.imported)}
+}
+
+func (s *Source) Committed(_ context.Context) error {
+	var errs []error
+	for _, c := range s.failed {
+		err := s.moveAside(c)
+		if err != nil {
+			s.logger.Error("FileSource - Committed - s.moveAside", zap.String("path", c.path), zap.Error(err))
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Source) Close() error {
+	if s.current == nil {
+		return nil
+	}
+	return s.current.Close()
+}
+
+// listFiles returns the candidate files oldest first. Hidden files are ignored, so that uploads can be
+// written under a dot name and renamed once complete.
+func (s *Source) listFiles() ([]*candidate, error) {
+	info, err := os.Stat(s.config.Path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			s.logger.Warn("file connector path does not exist", zap.String("path", s.config.Path))
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		return []*candidate{{path: s.config.Path, format: s.formatOf(s.config.Path)}}, nil
+	}
+
+	entries, err := os.ReadDir(s.config.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	type file struct {
+		name    string
+		modTime time.Time
+	}
+	var files []file
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() || strings.HasPrefix(name, ".") || !s.matches(name) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file{name: name, modTime: info.ModTime()})
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		if !files[i].modTime.Equal(files[j].modTime) {
+			return files[i].modTime.Before(files[j].modTime)
+		}
+		return files[i].name < files[j].name
+	})
+
+	candidates := make([]*candidate, 0, len(files))
+	for _, f := range files {
+		path := filepath.Join(s.config.Path, f.name)
+		candidates = append(candidates, &candidate{path: path, format: s.formatOf(path)})
+	}
+	return candidates, nil
+}
+
+func (s *Source) matches(name string) bool {
+	if s.config.Pattern != "" {
+		ok, _ := filepath.Match(s.config.Pattern, name)
+		return ok
+	}
+
+	format, ok := extensions[strings.ToLower(filepath.Ext(name))]
+	return ok && (s.config.Format == "" || format == s.config.Format)
+}
+
+func (s *Source) formatOf(path string) domain.FileFormat {
+	if s.config.Format != "" {
+		return s.config.Format
+	}
+	return extensions[strings.ToLower(filepath.Ext(path))]
+}
+
+// checksumFile returns the SHA-256 of the file content.
+func checksumFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	_, err = io.Copy(h, file)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// moveAside moves a failed file to the failed directory next to a report of the error.
+func (s *Source) moveAside(c *candidate) error {
+	dir := s.config.FailedDir
+	if dir == "" {
+		dir = filepath.Join(filepath.Dir(c.path), defaultFailedDir)
+		if info, err := os.Stat(s.config.Path); err == nil && info.IsDir() {
+			dir = filepath.Join(s.config.Path, defaultFailedDir)
+		}
+	}
+
+	err := os.MkdirAll(dir, 0o750)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	target := filepath.Join(dir, now.Format("20060102T150405Z")+"-"+filepath.Base(c.path))
+	err = os.Rename(c.path, target)
+	if err != nil {
+		return err
+	}
+
+	report := fmt.Sprintf("file: %s\nconnector: %d\nfailed at: %s\nerror: %v\n",
+		c.path, s.connector.ConnectorID, now.Format(time.RFC3339), c.err)
+	return os.WriteFile(target+".error.txt", []byte(report), 0o640)
+}
+
+// pageIterator streams the pending files in pages of batchSize records, a page may span several files.
+// A file that cannot be parsed is moved aside at once and fails the sync, so that none of its records
+// are committed and the next sync imports the other files.
+type pageIterator struct {
+	source    *Source
+	files     []*candidate
+	file      *candidate
+	batchSize int
+	done      bool
+}
+
+func (it *pageIterator) Next(ctx context.Context) ([]*source.Record, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	s := it.source
+	records := make([]*source.Record, 0, it.batchSize)
+	for len(records) < it.batchSize {
+		if s.current == nil {
+			if len(it.files) == 0 {
+				it.done = true
+				break
+			}
+
+			it.file, it.files = it.files[0], it.files[1:]
+			reader, err := openFile(it.file.path, it.file.format, s.config.Delimiter)
+			if err != nil {
+				return nil, it.fail(err)
+			}
+			s.current = reader
+		}
+
+		attributes, err := s.current.Read()
+		if errors.Is(err, io.EOF) {
+			err = it.finishFile()
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err != nil {
+			return nil, it.fail(fmt.Errorf("%s: %w", s.current.Position(), err))
+		}
+
+		it.file.records++
+		records = append(records, &source.Record{
+			ID:         filepath.Base(it.file.path) + ":" + s.current.Position(),
+			Attributes: attributes,
+		})
+	}
+
+	return records, nil
+}
+
+func (it *pageIterator) Done() bool {
+	return it.done
+}
+
+// fail moves the file being read aside with its error and returns the error that fails the sync.
+func (it *pageIterator) fail(err error) error {
+	s := it.source
+	if s.current != nil {
+		_ = s.current.Close()
+		s.current = nil
+	}
+
+	it.file.err = err
+	s.logger.Warn("file rejected", zap.String("path", it.file.path), zap.Error(err))
+	if moveErr := s.moveAside(it.file); moveErr != nil {
+		s.logger.Error("FileSource - fail - s.moveAside", zap.String("path", it.file.path), zap.Error(moveErr))
+	}
+	return fmt.Errorf("%s: %w", it.file.path, err)
+}
+
+// finishFile checks that the file was not rewritten since Connect hashed it, then marks it imported.
+func (it *pageIterator) finishFile() error {
+	s := it.source
+	checksum, err := s.current.Checksum()
+	_ = s.current.Close()
+	s.current = nil
+	if err != nil {
+		return err
+	}
+
+	if checksum != it.file.checksum {
+		return fmt.Errorf("%s changed during the sync", it.file.path)
+	}
+
+	s.imported = append(s.imported, &domain.ProcessedFile{
+		SourceID:    s.connector.ConnectorID,
+		Checksum:    checksum,
+		FileName:    filepath.Base(it.file.path),
+		RecordCount: it.file.records,
+		ProcessedAt: time.Now(),
+	})
+	return nil
+}
@@ -0,0 +1,154 @@
+package filesource
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/Masterminds/squirrel"
+	"github.com/stretchr/testify/assert"
+	"github.com/tuanta7/qworker/internal/domain"
+	"github.com/tuanta7/qworker/internal/source"
+	"github.com/tuanta7/qworker/pkg/logger"
+	"github.com/tuanta7/qworker/pkg/sqlxx"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type fakeProcessedFiles struct {
+	checksums map[string]bool
+}
+
+func (f *fakeProcessedFiles) Exists(_ context.Context, _ uint64, checksum string) (bool, error) {
+	return f.checksums[checksum], nil
+}
+
+func (f *fakeProcessedFiles) BuildBulkInsertQuery(files []*domain.ProcessedFile) *squirrel.InsertBuilder {
+	query := squirrel.Insert(domain.TableProcessedFile).Columns(domain.AllProcessedFileCols...)
+	for _, file := range files {
+		f.checksums[file.Checksum] = true
+		query = query.Values(file.SourceID, file.Checksum, file.FileName, file.RecordCount, file.ProcessedAt)
+	}
+	return &query
+}
+
+func writeFile(t *testing.T, dir, name, content string, modTime time.Time) {
+	path := filepath.Join(dir, name)
+	assert.Equal(t, nil, os.WriteFile(path, []byte(content), 0o600))
+	assert.Equal(t, nil, os.Chtimes(path, modTime, modTime))
+}
+
+func newSource(t *testing.T, processed *fakeProcessedFiles, config domain.FileConnector) source.SyncSource {
+	raw, _ := json.Marshal(config)
+
+	r := source.NewRegistry()
+	Register(r, processed, logger.MustNewLogger("none"))
+
+	src, err := r.New(&domain.Connector{
+		ConnectorID:   3,
+		ConnectorType: domain.ConnectorTypeFile,
+		Data:          sqlxx.TextData{Raw: raw},
+		Mapper:        domain.Mapper{Username: "login"},
+	})
+	assert.Equal(t, nil, err)
+	return src
+}
+
+// run reads every page like the sync pipeline does and returns the usernames page by page, the sync is
+// only committed when every page was read.
+func run(t *testing.T, src source.SyncSource) ([][]string, error) {
+	ctx := context.Background()
+	assert.Equal(t, nil, src.Connect(ctx))
+	defer src.Close()
+
+	var pages [][]string
+	it := src.Iterate(time.Time{})
+	for !it.Done() {
+		records, err := it.Next(ctx)
+		if err != nil {
+			return pages, err
+		}
+
+		usernames := make([]string, 0, len(records))
+		for _, record := range records {
+			user, err := src.Map(record)
+			assert.Equal(t, nil, err)
+			usernames = append(usernames, user.Username)
+		}
+		pages = append(pages, usernames)
+	}
+
+	committer := src.(source.Committer)
+	committer.CommitQueries()
+	assert.Equal(t, nil, committer.Committed(ctx))
+	return pages, nil
+}
+
+func TestFileSource(t *testing.T) {
+	dir := t.TempDir()
+	base := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+
+	csv := "\ufefflogin;full_name;email;active\n" +
+		"alice;Alice Nguyen;alice@example.com;true\n" +
+		"bob;Bob Tran;bob@example.com;false\n"
+	writeFile(t, dir, "a.csv", csv, base)
+	writeFile(t, dir, "b.json", `[{"login": "carol", "email": "carol@example.com", "updated_at": "2025-03-31"}]`, base.Add(time.Hour))
+	writeFile(t, dir, "c.jsonl", "{\"login\": \"dave\"}\n\n{\"login\": \"erin\", \"active\": \"0\"}\n", base.Add(2*time.Hour))
+	writeFile(t, dir, "d.jsonl", "{\"login\": \"frank\"}\n{\"login\": \n", base.Add(3*time.Hour))
+	writeFile(t, dir, ".e.csv", "login\nhidden\n", base)
+	writeFile(t, dir, "notes.txt", "not an export", base)
+
+	processed := &fakeProcessedFiles{checksums: make(map[string]bool)}
+	config := domain.FileConnector{
+		Path:         dir,
+		Delimiter:    ";",
+		SyncSettings: domain.SyncSettings{BatchSize: 2},
+	}
+
+	t.Run("malformed", func(t *testing.T) {
+		src := newSource(t, processed, config)
+		pages, err := run(t, src)
+		assert.ErrorContains(t, err, "d.jsonl: line 2")
+		assert.Equal(t, [][]string{{"alice", "bob"}, {"carol", "dave"}, {"erin", "frank"}}, pages)
+		assert.Equal(t, 0, len(processed.checksums))
+
+		failed, err := filepath.Glob(filepath.Join(dir, "failed", "*-d.jsonl*"))
+		assert.Equal(t, nil, err)
+		assert.Equal(t, 2, len(failed))
+
+		report, _ := os.ReadFile(failed[1])
+		assert.Contains(t, string(report), "line 2")
+	})
+
+	t.Run("import", func(t *testing.T) {
+		src := newSource(t, processed, config)
+		pages, err := run(t, src)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, [][]string{{"alice", "bob"}, {"carol", "dave"}, {"erin"}}, pages)
+		assert.Equal(t, 3, len(processed.checksums))
+	})
+
+	t.Run("map", func(t *testing.T) {
+		src := newSource(t, &fakeProcessedFiles{checksums: make(map[string]bool)}, domain.FileConnector{Path: filepath.Join(dir, "a.csv"), Delimiter: ";"})
+		assert.Equal(t, nil, src.Connect(context.Background()))
+		records, err := src.Iterate(time.Time{}).Next(context.Background())
+		assert.Equal(t, nil, err)
+		assert.Equal(t, "a.csv:line 2", records[0].ID)
+
+		user, err := src.Map(records[1])
+		assert.Equal(t, nil, err)
+		assert.Equal(t, "Bob Tran", user.FullName)
+		assert.Equal(t, "bob@example.com", user.Email)
+		assert.Equal(t, false, user.Active)
+	})
+
+	t.Run("skip_processed", func(t *testing.T) {
+		// the same export dropped again under another name
+		writeFile(t, dir, "f.csv", csv, base.Add(4*time.Hour))
+
+		src := newSource(t, processed, config)
+		pages, err := run(t, src)
+		assert.Equal(t, nil, err)
+		assert.Equal(t, [][]string{{}}, pages)
+	})
+}
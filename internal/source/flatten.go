@@ -0,0 +1,57 @@
+package source
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+// Flatten turns a JSON object into attributes, nested objects are addressed with dotted names and the
+// items of arrays become multiple values. It gives JSON based sources the shape kept for rejected records.
+func Flatten(object map[string]any) map[string][]string {
+	attributes := make(map[string][]string, len(object))
+	flattenInto("", object, attributes)
+	return attributes
+}
+
+func flattenInto(prefix string, v any, out map[string][]string) {
+	switch val := v.(type) {
+	case map[string]any:
+		for key, child := range val {
+			name := key
+			if prefix != "" {
+				name = prefix + "." + key
+			}
+			flattenInto(name, child, out)
+		}
+	case []any:
+		for _, item := range val {
+			flattenInto(prefix, item, out)
+		}
+	default:
+		if s, ok := JSONString(val); ok {
+			out[prefix] = append(out[prefix], s)
+		}
+	}
+}
+
+// JSONString formats a scalar decoded from JSON, ok is false for null.
+func JSONString(v any) (string, bool) {
+	switch val := v.(type) {
+	case nil:
+		return "", false
+	case string:
+		return val, true
+	case bool:
+		return strconv.FormatBool(val), true
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64), true
+	case json.Number:
+		return val.String(), true
+	default:
+		b, err := json.Marshal(val)
+		if err != nil {
+			return "", false
+		}
+		return string(b), true
+	}
+}
@@ -0,0 +1,195 @@
+package httpsource
+
+import (
+	"fmt"
+	"github.com/tuanta7/qworker/internal/source"
+	"strconv"
+	"strings"
+)
+
+type stepKind int
+
+const (
+	stepField stepKind = iota
+	stepIndex
+	stepWildcard
+	stepFilter
+)
+
+type step struct {
+	kind  stepKind
+	name  string // field name, or the attribute compared by a filter
+	index int
+	value string // value compared by a filter
+}
+
+// jsonPath is a compiled JSONPath. The supported subset is $, .name, ['name'], [n], [*], .* and
+// equality filters such as [?(@.type=='work')]. A path without the leading $ is relative to the root.
+type jsonPath struct {
+	raw   string
+	steps []step
+}
+
+func compilePath(raw string) (*jsonPath, error) {
+	p := &jsonPath{raw: raw}
+	path := strings.TrimSpace(raw)
+	switch {
+	case path == "" || path == "$":
+		return p, nil
+	case strings.HasPrefix(path, "$"):
+		path = path[1:]
+	case !strings.HasPrefix(path, "["):
+		path = "." + path
+	}
+
+	for len(path) > 0 {
+		var s step
+		var err error
+		switch path[0] {
+		case '.':
+			s, path, err = parseDot(path[1:])
+		case '[':
+			s, path, err = parseBracket(path[1:])
+		default:
+			err = fmt.Errorf("unexpected %q", path[0])
+		}
+		if err != nil {
+			return nil, fmt.Errorf("jsonpath %q: %w", raw, err)
+		}
+		p.steps = append(p.steps, s)
+	}
+
+	return p, nil
+}
+
+func parseDot(path string) (step, string, error) {
+	if strings.HasPrefix(path, "*") {
+		return step{kind: stepWildcard}, path[1:], nil
+	}
+
+	end := strings.IndexAny(path, ".[")
+	if end < 0 {
+		end = len(path)
+	}
+	if end == 0 {
+		return step{}, "", fmt.Errorf("empty field name, recursive descent is not supported")
+	}
+	return step{kind: stepField, name: path[:end]}, path[end:], nil
+}
+
+func parseBracket(path string) (step, string, error) {
+	end := strings.Index(path, "]")
+	if end < 0 {
+		return step{}, "", fmt.Errorf("unterminated [")
+	}
+	inner, rest := strings.TrimSpace(path[:end]), path[end+1:]
+
+	switch {
+	case inner == "*":
+		return step{kind: stepWildcard}, rest, nil
+	case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+		return step{kind: stepField, name: inner[1 : len(inner)-1]}, rest, nil
+	case strings.HasPrefix(inner, "?(") && strings.HasSuffix(inner, ")"):
+		attr, value, ok := strings.Cut(inner[2:len(inner)-1], "==")
+		attr, value = strings.TrimSpace(attr), strings.TrimSpace(value)
+		if !ok || !strings.HasPrefix(attr, "@.") {
+			return step{}, "", fmt.Errorf("unsupported filter %q, expected [?(@.attr=='value')]", inner)
+		}
+		return step{kind: stepFilter, name: attr[2:], value: strings.Trim(value, `'"`)}, rest, nil
+	default:
+		index, err := strconv.Atoi(inner)
+		if err != nil {
+			return step{}, "", fmt.Errorf("unsupported selector [%s]", inner)
+		}
+		return step{kind: stepIndex, index: index}, rest, nil
+	}
+}
+
+// eval returns the values selected in root. A field selected on an array applies to each item, so that
+// $.emails.value reads every email.
+func (p *jsonPath) eval(root any) []any {
+	nodes := []any{root}
+	for _, s := range p.steps {
+		var next []any
+		for _, node := range nodes {
+			next = append(next, s.apply(node)...)
+		}
+		nodes = next
+	}
+	return nodes
+}
+
+// strings returns the scalar values selected in root.
+func (p *jsonPath) strings(root any) []string {
+	var values []string
+	for _, node := range p.eval(root) {
+		if items, ok := node.([]any); ok {
+			for _, item := range items {
+				if s, ok := source.JSONString(item); ok {
+					values = append(values, s)
+				}
+			}
+			continue
+		}
+		if s, ok := source.JSONString(node); ok {
+			values = append(values, s)
+		}
+	}
+	return values
+}
+
+func (s step) apply(node any) []any {
+	switch s.kind {
+	case stepField:
+		switch val := node.(type) {
+		case map[string]any:
+			if v, ok := val[s.name]; ok {
+				return []any{v}
+			}
+		case []any:
+			var result []any
+			for _, item := range val {
+				result = append(result, s.apply(item)...)
+			}
+			return result
+		}
+	case stepIndex:
+		if items, ok := node.([]any); ok {
+			i := s.index
+			if i < 0 {
+				i += len(items)
+			}
+			if i >= 0 && i < len(items) {
+				return []any{items[i]}
+			}
+		}
+	case stepWildcard:
+		switch val := node.(type) {
+		case []any:
+			return val
+		case map[string]any:
+			result := make([]any, 0, len(val))
+			for _, v := range val {
+				result = append(result, v)
+			}
+			return result
+		}
+	case stepFilter:
+		items, ok := node.([]any)
+		if !ok {
+			return nil
+		}
+		var result []any
+		for _, item := range items {
+			obj, ok := item.(map[string]any)
+			if !ok {
+				continue
+			}
+			if v, ok := source.JSONString(obj[s.name]); ok && v == s.value {
+				result = append(result, item)
+			}
+		}
+		return result
+	}
+	return nil
+}
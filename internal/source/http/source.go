@@ -0,0 +1,570 @@
+package httpsource
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/tuanta7/qworker/internal/domain"
+	"github.com/tuanta7/qworker/internal/mapping"
+	"github.com/tuanta7/qworker/internal/source"
+	"github.com/tuanta7/qworker/pkg/cipherx"
+	"github.com/tuanta7/qworker/pkg/logger"
+	"github.com/tuanta7/qworker/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultBatchSize   = 100
+	defaultTimeout     = 30 * time.Second
+	defaultRetryAfter  = time.Second
+	maxRetryAfter      = time.Minute
+	maxAttempts        = 3
+	maxErrorBody       = 4 << 10
+	defaultPageParam   = "page"
+	defaultOffsetParam = "offset"
+	defaultCursorParam = "cursor"
+)
+
+// Register adds the REST/JSON source to the registry.
+func Register(r *source.Registry, client *http.Client, cipher cipherx.Cipher, zl *logger.ZapLogger) {
+	source.Register(r, domain.ConnectorTypeHTTP, source.JSONDecoder[domain.HTTPConnector](),
+		func(connector *domain.Connector, config *domain.HTTPConnector) (source.SyncSource, error) {
+			if config.URL == "" {
+				return nil, errors.New("http connector has no url")
+			}
+			if _, err := url.Parse(config.URL); err != nil {
+				return nil, fmt.Errorf("invalid http connector url: %w", err)
+			}
+
+			m, err := mapping.Compile(source.WithDefaultColumns(connector.Mapper))
+			if err != nil {
+				return nil, err
+			}
+
+			s := &Source{
+				connector: connector,
+				config:    config,
+				mapping:   m,
+				client:    client,
+				cipher:    cipher,
+				logger:    zl,
+			}
+
+			s.recordsPath, err = compilePath(config.RecordsPath)
+			if err != nil {
+				return nil, err
+			}
+
+			switch config.Pagination.Type {
+			case domain.HTTPPaginationNone, domain.HTTPPaginationPage, domain.HTTPPaginationOffset,
+				domain.HTTPPaginationLink:
+			case domain.HTTPPaginationCursor:
+				if config.Pagination.CursorPath == "" {
+					return nil, errors.New("http connector cursor pagination has no cursor path")
+				}
+				s.cursorPath, err = compilePath(config.Pagination.CursorPath)
+				if err != nil {
+					return nil, err
+				}
+			default:
+				return nil, fmt.Errorf("unsupported http pagination type: %q", config.Pagination.Type)
+			}
+
+			// mapping paths are compiled once so that a typo fails the run before the first request
+			s.paths = make(map[string]*jsonPath)
+			for _, raw := range m.Refs() {
+				s.paths[raw], err = compilePath(raw)
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			return s, nil
+		})
+}
+
+type Source struct {
+	connector   *domain.Connector
+	config      *domain.HTTPConnector
+	mapping     *mapping.Mapping
+	client      *http.Client
+	cipher      cipherx.Cipher
+	logger      *logger.ZapLogger
+	recordsPath *jsonPath
+	cursorPath  *jsonPath
+	paths       map[string]*jsonPath
+	authHeader  string
+	authValue   string
+}
+
+// Connect only prepares the credentials, the first request is made by the page iterator.
+func (s *Source) Connect(_ context.Context) error {
+	auth := s.config.Auth
+	switch auth.Type {
+	case domain.HTTPAuthNone:
+	case domain.HTTPAuthBearer, domain.HTTPAuthHeader:
+		token, err := s.cipher.Decrypt(auth.Token)
+		if err != nil {
+			s.logger.Error("HTTPSource - Connect - s.cipher.Decrypt", zap.Error(err))
+			return err
+		}
+		s.authHeader, s.authValue = "Authorization", "Bearer "+token
+		if auth.Type == domain.HTTPAuthHeader {
+			if auth.HeaderName == "" {
+				return errors.New("http connector header auth has no header name")
+			}
+			s.authHeader, s.authValue = auth.HeaderName, token
+		}
+	case domain.HTTPAuthBasic:
+		password, err := s.cipher.Decrypt(auth.Password)
+		if err != nil {
+			s.logger.Error("HTTPSource - Connect - s.cipher.Decrypt", zap.Error(err))
+			return err
+		}
+		req := &http.Request{Header: http.Header{}}
+		req.SetBasicAuth(auth.Username, password)
+		s.authHeader, s.authValue = "Authorization", req.Header.Get("Authorization")
+	default:
+		return fmt.Errorf("unsupported http auth type: %s", auth.Type)
+	}
+
+	return nil
+}
+
+// Iterate sends the watermark in SinceParam, an API without an incremental filter leaves it empty and
+// gets a full read on every run.
+func (s *Source) Iterate(since time.Time) source.PageIterator {
+	size := int(s.config.SyncSettings.BatchSize)
+	if size <= 0 {
+		size = defaultBatchSize
+	}
+
+	query := url.Values{}
+	for k, v := range s.config.Query {
+		query.Set(k, v)
+	}
+	if s.config.SinceParam != "" && !since.IsZero() {
+		query.Set(s.config.SinceParam, formatSince(since, s.config.SinceFormat))
+	}
+	if s.config.Pagination.SizeParam != "" {
+		query.Set(s.config.Pagination.SizeParam, strconv.Itoa(size))
+	}
+
+	page := s.config.Pagination.StartPage
+	if page == 0 {
+		page = 1
+	}
+
+	return &pageIterator{
+		source: s,
+		query:  query,
+		size:   size,
+		page:   page,
+		seen:   make(map[string]struct{}),
+	}
+}
+
+// Map evaluates the mapping paths on the record object, then maps the values like columns.
+func (s *Source) Map(record *source.Record) (*domain.User, error) {
+	object, ok := record.Native.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("record %s is not a JSON object", record.ID)
+	}
+
+	attributes := make(map[string][]string, len(s.paths))
+	for raw, path := range s.paths {
+		if values := path.strings(object); len(values) > 0 {
+			attributes[raw] = values
+		}
+	}
+
+	return source.MapColumns(&source.Record{ID: record.ID, Attributes: attributes}, s.mapping)
+}
+
+func (s *Source) Mapping() *mapping.Mapping {
+	return s.mapping
+}
+
+func (s *Source) Values(record *source.Record) func(attr string) []string {
+	object, _ := record.Native.(map[string]any)
+	return s.values(object)
+}
+
+// values returns the values of a mapping path in the record object.
+func (s *Source) values(object map[string]any) func(attr string) []string {
+	return func(attr string) []string {
+		return s.paths[attr].strings(object)
+	}
+}
+
+func (s *Source) Close() error {
+	return nil
+}
+
+func (s *Source) timeout() time.Duration {
+	if s.config.Timeout <= 0 {
+		return defaultTimeout
+	}
+	return s.config.Timeout * time.Millisecond
+}
+
+// get fetches a page, retrying when the API asks to slow down.
+func (s *Source) get(ctx context.Context, endpoint string) (any, http.Header, error) {
+	for attempt := 1; ; attempt++ {
+		body, header, retryAfter, err := s.do(ctx, endpoint)
+		if err == nil || retryAfter == 0 || attempt == maxAttempts {
+			return body, header, err
+		}
+
+		s.logger.Warn("http source throttled", zap.Duration("retry_after", retryAfter), zap.Error(err))
+		timer := time.NewTimer(retryAfter)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// do sends a single request, retryAfter is set when the response is 429 or 503.
+func (s *Source) do(ctx context.Context, endpoint string) (any, http.Header, time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	req.Header.Set("Accept", "application/json")
+	for k, v := range s.config.Headers {
+		req.Header.Set(k, v)
+	}
+	if s.authHeader != "" {
+		req.Header.Set(s.authHeader, s.authValue)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		err = fmt.Errorf("http: GET %s: status %d", req.URL.Path, resp.StatusCode)
+		if msg := strings.TrimSpace(string(body)); msg != "" {
+			err = fmt.Errorf("%w: %s", err, msg)
+		}
+
+		var retryAfter time.Duration
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+			retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+		}
+		return nil, nil, retryAfter, err
+	}
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	// numbers are kept as written, so that large IDs are not rounded through float64
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var body any
+	err = decoder.Decode(&body)
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("http: decode response: %w", err)
+	}
+
+	return body, resp.Header, 0, nil
+}
+
+type pageIterator struct {
+	source *Source
+	query  url.Values
+	size   int
+	page   int    // page pagination
+	offset int    // offset pagination
+	cursor string // cursor pagination
+	next   string // link pagination, empty for the first page
+	seen   map[string]struct{}
+	last   [sha256.Size]byte // page and offset pagination, hash of the previous page
+	pages  int
+	done   bool
+}
+
+func (it *pageIterator) Next(ctx context.Context) ([]*source.Record, error) {
+	s := it.source
+	it.pages++
+	if maxPages := s.config.Pagination.MaxPages; maxPages > 0 && it.pages > maxPages {
+		return nil, fmt.Errorf("http: more than %d pages", maxPages)
+	}
+
+	endpoint, err := it.endpoint()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, span := tracing.Tracer().Start(ctx, "http.get",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String(tracing.AttrConnectorID, strconv.FormatUint(s.connector.ConnectorID, 10)),
+			attribute.String("http.pagination", string(s.config.Pagination.Type)),
+		))
+	body, header, err := s.get(ctx, endpoint)
+	tracing.End(span, err)
+	if err != nil {
+		s.logger.Error("HTTPSource - Next - s.get", zap.Error(err))
+		return nil, err
+	}
+
+	records, err := it.records(body)
+	if err != nil {
+		return nil, err
+	}
+
+	err = it.advance(body, header, endpoint, records)
+	if err != nil {
+		s.logger.Error("HTTPSource - Next - it.advance", zap.Error(err))
+		return nil, err
+	}
+
+	return records, nil
+}
+
+func (it *pageIterator) Done() bool {
+	return it.done
+}
+
+// endpoint returns the URL of the current page. The Link header already holds the whole query of the
+// next page, the other strategies add their parameter to the configured query.
+func (it *pageIterator) endpoint() (string, error) {
+	p := it.source.config.Pagination
+	if p.Type == domain.HTTPPaginationLink && it.next != "" {
+		return it.next, nil
+	}
+
+	u, err := url.Parse(it.source.config.URL)
+	if err != nil {
+		return "", err
+	}
+
+	query := u.Query()
+	for k, v := range it.query {
+		query[k] = v
+	}
+
+	switch p.Type {
+	case domain.HTTPPaginationPage:
+		query.Set(paramOr(p.PageParam, defaultPageParam), strconv.Itoa(it.page))
+	case domain.HTTPPaginationOffset:
+		query.Set(paramOr(p.OffsetParam, defaultOffsetParam), strconv.Itoa(it.offset))
+	case domain.HTTPPaginationCursor:
+		if it.cursor != "" {
+			query.Set(paramOr(p.CursorParam, defaultCursorParam), it.cursor)
+		}
+	}
+
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+func (it *pageIterator) records(body any) ([]*source.Record, error) {
+	s := it.source
+	var objects []any
+	for _, node := range s.recordsPath.eval(body) {
+		if items, ok := node.([]any); ok {
+			objects = append(objects, items...)
+			continue
+		}
+		objects = append(objects, node)
+	}
+
+	records := make([]*source.Record, 0, len(objects))
+	for i, node := range objects {
+		object, ok := node.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("http: record %d of %s is not a JSON object", i, s.recordsPath.raw)
+		}
+
+		id := strconv.Itoa(it.offset + i)
+		if value := s.mapping.Value(domain.MappingExternalID, s.values(object)); value != "" {
+			id = value
+		}
+		records = append(records, &source.Record{
+			ID:         id,
+			Attributes: source.Flatten(object),
+			Native:     object,
+		})
+	}
+
+	return records, nil
+}
+
+// advance moves to the next page. Page and offset pagination stop on an empty page, or on a short page
+// when the API receives the page size in SizeParam, its own page size being unknown otherwise. Cursor and
+// link pagination stop when the response has no next page. A page repeating the previous one, as returned
+// by an API ignoring the page or offset parameter, and a cursor or link seen twice are errors rather than
+// an endless sync.
+func (it *pageIterator) advance(body any, header http.Header, endpoint string, records []*source.Record) error {
+	s := it.source
+	count := len(records)
+	switch s.config.Pagination.Type {
+	case domain.HTTPPaginationPage:
+		it.page++
+		it.offset += count
+		it.done = it.lastPage(count)
+		return it.checkRepeated(records)
+	case domain.HTTPPaginationOffset:
+		it.offset += count
+		it.done = it.lastPage(count)
+		return it.checkRepeated(records)
+	case domain.HTTPPaginationCursor:
+		it.offset += count
+		it.cursor = ""
+		if cursors := s.cursorPath.strings(body); len(cursors) > 0 {
+			it.cursor = cursors[0]
+		}
+		if it.cursor == "" || count == 0 {
+			it.done = true
+			return nil
+		}
+		if _, ok := it.seen[it.cursor]; ok {
+			return fmt.Errorf("http: cursor %q was already returned", it.cursor)
+		}
+		it.seen[it.cursor] = struct{}{}
+	case domain.HTTPPaginationLink:
+		it.offset += count
+		next, err := nextLink(header, endpoint)
+		if err != nil {
+			return err
+		}
+		if next == "" || count == 0 {
+			it.done = true
+			return nil
+		}
+		if _, ok := it.seen[next]; ok {
+			return fmt.Errorf("http: next link %q was already returned", next)
+		}
+		it.seen[endpoint] = struct{}{}
+		it.seen[next] = struct{}{}
+		it.next = next
+	default:
+		it.done = true
+	}
+
+	return nil
+}
+
+func (it *pageIterator) lastPage(count int) bool {
+	return count == 0 || (it.source.config.Pagination.SizeParam != "" && count < it.size)
+}
+
+func (it *pageIterator) checkRepeated(records []*source.Record) error {
+	if it.done {
+		return nil
+	}
+
+	objects := make([]any, len(records))
+	for i, record := range records {
+		objects[i] = record.Native
+	}
+	raw, err := json.Marshal(objects)
+	if err != nil {
+		return err
+	}
+
+	sum := sha256.Sum256(raw)
+	if sum == it.last {
+		return fmt.Errorf("http: page at offset %d repeats the previous page", it.offset-len(records))
+	}
+	it.last = sum
+	return nil
+}
+
+// nextLink returns the rel="next" target of the Link header, resolved against the current URL.
+func nextLink(header http.Header, current string) (string, error) {
+	for _, value := range header.Values("Link") {
+		for _, link := range strings.Split(value, ",") {
+			target, params, ok := strings.Cut(strings.TrimSpace(link), ";")
+			target = strings.TrimSpace(target)
+			if !ok || !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+
+			for _, param := range strings.Split(params, ";") {
+				name, rel, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if !strings.EqualFold(name, "rel") || !hasRel(strings.Trim(rel, `"`), "next") {
+					continue
+				}
+
+				base, err := url.Parse(current)
+				if err != nil {
+					return "", err
+				}
+				ref, err := url.Parse(target[1 : len(target)-1])
+				if err != nil {
+					return "", fmt.Errorf("http: invalid next link: %w", err)
+				}
+				return base.ResolveReference(ref).String(), nil
+			}
+		}
+	}
+	return "", nil
+}
+
+func hasRel(rels, rel string) bool {
+	for _, r := range strings.Fields(rels) {
+		if strings.EqualFold(r, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseRetryAfter reads the seconds or HTTP date form of Retry-After, capped so that a misbehaving API
+// cannot park the worker.
+func parseRetryAfter(value string) time.Duration {
+	d := defaultRetryAfter
+	if seconds, err := strconv.Atoi(strings.TrimSpace(value)); err == nil {
+		d = time.Duration(seconds) * time.Second
+	} else if at, err := http.ParseTime(value); err == nil {
+		d = time.Until(at)
+	}
+
+	if d <= 0 {
+		d = defaultRetryAfter
+	}
+	return min(d, maxRetryAfter)
+}
+
+func formatSince(since time.Time, layout string) string {
+	switch layout {
+	case "":
+		return since.UTC().Format(time.RFC3339)
+	case "unix":
+		return strconv.FormatInt(since.Unix(), 10)
+	default:
+		return since.UTC().Format(layout)
+	}
+}
+
+func paramOr(param, fallback string) string {
+	if param == "" {
+		return fallback
+	}
+	return param
+}
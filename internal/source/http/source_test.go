@@ -0,0 +1,353 @@
+package httpsource
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/tuanta7/qworker/internal/domain"
+	"github.com/tuanta7/qworker/internal/source"
+	"github.com/tuanta7/qworker/pkg/cipherx"
+	"github.com/tuanta7/qworker/pkg/logger"
+	"github.com/tuanta7/qworker/pkg/sqlxx"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func apiUser(i int) map[string]any {
+	return map[string]any{
+		"id":      9007199254740993 + i,
+		"login":   fmt.Sprintf("user%d", i),
+		"profile": map[string]any{"displayName": fmt.Sprintf("User %d", i)},
+		"emails": []map[string]any{
+			{"address": fmt.Sprintf("user%d@home.example.com", i), "kind": "home"},
+			{"address": fmt.Sprintf("user%d@example.com", i), "kind": "work"},
+		},
+		"status":    map[string]any{"enabled": i%2 == 0},
+		"updatedAt": "2025-03-01T10:00:00Z",
+	}
+}
+
+func users(from, to int) []map[string]any {
+	result := make([]map[string]any, 0)
+	for i := from; i < to; i++ {
+		result = append(result, apiUser(i))
+	}
+	return result
+}
+
+var apiMapper = domain.Mapper{
+	ExternalID: "$.id",
+	Username:   "login",
+	FullName:   "$.profile.displayName",
+	Email:      "$.emails[?(@.kind=='work')].address",
+	UpdatedAt:  "updatedAt",
+	Custom:     map[string]string{"active": "status.enabled"},
+}
+
+func newSource(t *testing.T, config *domain.HTTPConnector, mapper domain.Mapper) source.SyncSource {
+	cipher, err := cipherx.New(cipherx.AEAD, []byte("1234567887654321"))
+	assert.Equal(t, nil, err)
+
+	if config.Auth.Token != "" {
+		config.Auth.Token, _ = cipher.Encrypt(config.Auth.Token)
+	}
+	if config.Auth.Password != "" {
+		config.Auth.Password, _ = cipher.Encrypt(config.Auth.Password)
+	}
+	raw, _ := json.Marshal(config)
+
+	r := source.NewRegistry()
+	Register(r, http.DefaultClient, cipher, logger.MustNewLogger("none"))
+
+	src, err := r.New(&domain.Connector{
+		ConnectorID:   9,
+		ConnectorType: domain.ConnectorTypeHTTP,
+		Data:          sqlxx.TextData{Raw: raw},
+		Mapper:        mapper,
+	})
+	assert.Equal(t, nil, err)
+	return src
+}
+
+func readAll(t *testing.T, src source.SyncSource, since time.Time) ([]*source.Record, []*domain.User) {
+	ctx := context.Background()
+	assert.Equal(t, nil, src.Connect(ctx))
+	defer src.Close()
+
+	var records []*source.Record
+	var users []*domain.User
+	pages := src.Iterate(since)
+	for !pages.Done() {
+		page, err := pages.Next(ctx)
+		assert.Equal(t, nil, err)
+		for _, record := range page {
+			user, err := src.Map(record)
+			assert.Equal(t, nil, err)
+			records = append(records, record)
+			users = append(users, user)
+		}
+	}
+	return records, users
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func TestHTTPSource(t *testing.T) {
+	t.Run("page_bearer_since", func(t *testing.T) {
+		var queries []url.Values
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer s3cr3t" || r.Header.Get("X-Tenant") != "acme" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			queries = append(queries, r.URL.Query())
+			page, _ := strconv.Atoi(r.URL.Query().Get("p"))
+			size, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+			from := (page - 1) * size
+			writeJSON(w, map[string]any{"data": map[string]any{"users": users(from, min(from+size, 5))}})
+		}))
+		defer srv.Close()
+
+		src := newSource(t, &domain.HTTPConnector{
+			URL:          srv.URL + "/api/users?status=any",
+			Headers:      map[string]string{"X-Tenant": "acme"},
+			Auth:         domain.HTTPAuth{Type: domain.HTTPAuthBearer, Token: "s3cr3t"},
+			Pagination:   domain.HTTPPagination{Type: domain.HTTPPaginationPage, PageParam: "p", SizeParam: "per_page"},
+			RecordsPath:  "$.data.users",
+			SinceParam:   "updated_since",
+			SinceFormat:  "unix",
+			SyncSettings: domain.SyncSettings{BatchSize: 2},
+		}, apiMapper)
+
+		records, got := readAll(t, src, time.Unix(1700000000, 0))
+		assert.Equal(t, 3, len(queries))
+		assert.Equal(t, "1700000000", queries[0].Get("updated_since"))
+		assert.Equal(t, "any", queries[0].Get("status"))
+		assert.Equal(t, "3", queries[2].Get("p"))
+
+		assert.Equal(t, 5, len(got))
+		assert.Equal(t, "9007199254740993", records[0].ID)
+		assert.Equal(t, "user0", got[0].Username)
+		assert.Equal(t, "User 0", got[0].FullName)
+		assert.Equal(t, "user0@example.com", got[0].Email)
+		assert.Equal(t, true, got[0].Active)
+		assert.Equal(t, false, got[1].Active)
+		assert.Equal(t, time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC), got[0].UpdatedAt)
+	})
+
+	t.Run("offset_basic", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			username, password, ok := r.BasicAuth()
+			if !ok || username != "sync" || password != "password" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			offset, _ := strconv.Atoi(r.URL.Query().Get("skip"))
+			writeJSON(w, users(offset, min(offset+3, 7)))
+		}))
+		defer srv.Close()
+
+		src := newSource(t, &domain.HTTPConnector{
+			URL:          srv.URL,
+			Auth:         domain.HTTPAuth{Type: domain.HTTPAuthBasic, Username: "sync", Password: "password"},
+			Pagination:   domain.HTTPPagination{Type: domain.HTTPPaginationOffset, OffsetParam: "skip"},
+			SyncSettings: domain.SyncSettings{BatchSize: 3},
+		}, apiMapper)
+
+		_, got := readAll(t, src, time.Time{})
+		assert.Equal(t, 7, len(got))
+		assert.Equal(t, "user6", got[6].Username)
+	})
+
+	t.Run("cursor_api_key", func(t *testing.T) {
+		var requests int
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			if r.Header.Get("X-API-Key") != "k3y" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			cursor, _ := strconv.Atoi(r.URL.Query().Get("after"))
+			next := ""
+			if cursor+2 < 5 {
+				next = strconv.Itoa(cursor + 2)
+			}
+			writeJSON(w, map[string]any{"items": users(cursor, min(cursor+2, 5)), "paging": map[string]any{"next": next}})
+		}))
+		defer srv.Close()
+
+		src := newSource(t, &domain.HTTPConnector{
+			URL:  srv.URL,
+			Auth: domain.HTTPAuth{Type: domain.HTTPAuthHeader, HeaderName: "X-API-Key", Token: "k3y"},
+			Pagination: domain.HTTPPagination{
+				Type:        domain.HTTPPaginationCursor,
+				CursorParam: "after",
+				CursorPath:  "$.paging.next",
+			},
+			RecordsPath: "$.items[*]",
+		}, apiMapper)
+
+		_, got := readAll(t, src, time.Time{})
+		assert.Equal(t, 3, requests)
+		assert.Equal(t, 5, len(got))
+	})
+
+	t.Run("link_retry_after", func(t *testing.T) {
+		var throttled bool
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !throttled {
+				throttled = true
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+			if page < 2 {
+				w.Header().Set("Link", fmt.Sprintf(`</users?page=%d>; rel="next", </users?page=0>; rel="first"`, page+1))
+			}
+			writeJSON(w, users(page*2, page*2+2))
+		}))
+		defer srv.Close()
+
+		src := newSource(t, &domain.HTTPConnector{
+			URL:        srv.URL + "/users",
+			Pagination: domain.HTTPPagination{Type: domain.HTTPPaginationLink},
+		}, apiMapper)
+
+		_, got := readAll(t, src, time.Time{})
+		assert.Equal(t, 6, len(got))
+		assert.Equal(t, "user5", got[5].Username)
+	})
+
+	t.Run("repeated_cursor", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, map[string]any{"items": users(0, 1), "next": "same"})
+		}))
+		defer srv.Close()
+
+		src := newSource(t, &domain.HTTPConnector{
+			URL:         srv.URL,
+			Pagination:  domain.HTTPPagination{Type: domain.HTTPPaginationCursor, CursorPath: "next"},
+			RecordsPath: "items",
+		}, apiMapper)
+
+		pages := src.Iterate(time.Time{})
+		_, err := pages.Next(context.Background())
+		assert.Equal(t, nil, err)
+		_, err = pages.Next(context.Background())
+		assert.EqualError(t, err, `http: cursor "same" was already returned`)
+	})
+
+	t.Run("page_without_size_param", func(t *testing.T) {
+		var requests int
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			// the API pages at 25 whatever the batch size
+			page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+			writeJSON(w, users((page-1)*25, min(page*25, 60)))
+		}))
+		defer srv.Close()
+
+		src := newSource(t, &domain.HTTPConnector{
+			URL:          srv.URL,
+			Pagination:   domain.HTTPPagination{Type: domain.HTTPPaginationPage},
+			SyncSettings: domain.SyncSettings{BatchSize: 100},
+		}, apiMapper)
+
+		_, got := readAll(t, src, time.Time{})
+		assert.Equal(t, 60, len(got))
+		assert.Equal(t, 4, requests)
+	})
+
+	t.Run("repeated_page", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, users(0, 2))
+		}))
+		defer srv.Close()
+
+		src := newSource(t, &domain.HTTPConnector{
+			URL:          srv.URL,
+			Pagination:   domain.HTTPPagination{Type: domain.HTTPPaginationPage},
+			SyncSettings: domain.SyncSettings{BatchSize: 2},
+		}, apiMapper)
+
+		pages := src.Iterate(time.Time{})
+		_, err := pages.Next(context.Background())
+		assert.Equal(t, nil, err)
+		_, err = pages.Next(context.Background())
+		assert.EqualError(t, err, "http: page at offset 2 repeats the previous page")
+	})
+
+	t.Run("max_pages", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+			writeJSON(w, users(offset, offset+2))
+		}))
+		defer srv.Close()
+
+		src := newSource(t, &domain.HTTPConnector{
+			URL:          srv.URL,
+			Pagination:   domain.HTTPPagination{Type: domain.HTTPPaginationOffset, MaxPages: 2},
+			SyncSettings: domain.SyncSettings{BatchSize: 2},
+		}, apiMapper)
+
+		pages := src.Iterate(time.Time{})
+		for range 2 {
+			_, err := pages.Next(context.Background())
+			assert.Equal(t, nil, err)
+		}
+		_, err := pages.Next(context.Background())
+		assert.EqualError(t, err, "http: more than 2 pages")
+	})
+
+	t.Run("invalid_path", func(t *testing.T) {
+		r := source.NewRegistry()
+		Register(r, http.DefaultClient, nil, logger.MustNewLogger("none"))
+
+		_, err := r.New(&domain.Connector{
+			ConnectorType: domain.ConnectorTypeHTTP,
+			Data:          sqlxx.TextData{Raw: []byte(`{"url":"http://localhost"}`)},
+			Mapper:        domain.Mapper{Username: "$..login"},
+		})
+		assert.EqualError(t, err, `jsonpath "$..login": empty field name, recursive descent is not supported`)
+	})
+}
+
+func TestJSONPath(t *testing.T) {
+	doc := map[string]any{
+		"users": []any{
+			map[string]any{"name": "a", "tags": []any{"x", "y"}},
+			map[string]any{"name": "b", "tags": []any{"z"}},
+		},
+		"meta": map[string]any{"next page": "c2"},
+	}
+
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{"$.users[*].name", []string{"a", "b"}},
+		{"users.name", []string{"a", "b"}},
+		{"$.users[-1].name", []string{"b"}},
+		{"$.users[0].tags", []string{"x", "y"}},
+		{"$.users[?(@.name=='b')].tags[0]", []string{"z"}},
+		{"$['meta']['next page']", []string{"c2"}},
+		{"$.missing", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			p, err := compilePath(tt.path)
+			assert.Equal(t, nil, err)
+			assert.Equal(t, tt.want, p.strings(doc))
+		})
+	}
+}
@@ -0,0 +1,380 @@
+package ldapsource
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/tuanta7/qworker/internal/domain"
+	"github.com/tuanta7/qworker/internal/source"
+	"github.com/tuanta7/qworker/pkg/tracing"
+	"github.com/tuanta7/qworker/pkg/utils"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"time"
+)
+
+const (
+	defaultGroupFilter          = "(|(objectClass=group)(objectClass=groupOfNames)(objectClass=groupOfUniqueNames))"
+	defaultNameAttribute        = "cn"
+	defaultDescriptionAttribute = "description"
+	defaultMemberAttribute      = "member"
+	defaultMemberOfAttribute    = "memberOf"
+
+	// matchingRuleInChain is LDAP_MATCHING_RULE_IN_CHAIN, Active Directory walks the nested groups for it.
+	matchingRuleInChain = "1.2.840.113556.1.4.1941"
+)
+
+func (s *Source) IterateGroups(since time.Time) source.GroupIterator {
+	settings := s.config.Groups
+	if !settings.Enabled {
+		return nil
+	}
+
+	filter := defaultGroupFilter
+	if settings.Filter != "" {
+		filter = settings.Filter
+	}
+	if !since.IsZero() {
+		filter = fmt.Sprintf("(&%s(%s>=%s))", filter, s.updatedAt, utils.TimeToLDAPString(since))
+	}
+
+	return &groupIterator{
+		source:        s,
+		filter:        filter,
+		bases:         s.groupBaseDNs(),
+		incremental:   !since.IsZero(),
+		pagingControl: ldap.NewControlPaging(s.config.SyncSettings.BatchSize),
+		read:          make(map[string]struct{}),
+	}
+}
+
+func (s *Source) ExpandNested() bool {
+	return s.config.Groups.Nested == domain.LDAPNestedGroupsExpand
+}
+
+// groupBaseDNs returns the DNs the groups are searched under with subtree scope: the group BaseDN if set,
+// otherwise every user search base, nested bases left out so that no group is read twice.
+func (s *Source) groupBaseDNs() []string {
+	if s.config.Groups.BaseDN != "" {
+		return []string{s.config.Groups.BaseDN}
+	}
+
+	dns := make([]string, 0, len(s.bases))
+	parsed := make([]*ldap.DN, len(s.bases))
+	for i, base := range s.bases {
+		parsed[i], _ = ldap.ParseDN(base.dn)
+	}
+	for i, base := range s.bases {
+		nested := false
+		for j, other := range parsed {
+			if j == i || parsed[i] == nil || other == nil {
+				continue
+			}
+			// of two equal bases, the first one is kept
+			if other.AncestorOfFold(parsed[i]) || (j < i && other.EqualFold(parsed[i])) {
+				nested = true
+				break
+			}
+		}
+		if !nested {
+			dns = append(dns, base.dn)
+		}
+	}
+	return dns
+}
+
+func (s *Source) groupAttributes() []string {
+	settings := s.config.Groups
+	attributes := []string{
+		valueOr(settings.NameAttribute, defaultNameAttribute),
+		valueOr(settings.DescriptionAttribute, defaultDescriptionAttribute),
+		valueOr(settings.MemberAttribute, defaultMemberAttribute),
+	}
+	for _, attr := range []string{s.createdAt, s.updatedAt} {
+		if attr != "" {
+			attributes = append(attributes, attr)
+		}
+	}
+	return attributes
+}
+
+// toGroupRecord reads the members of a group entry. With a memberOf attribute the members are the users
+// holding the group DN, otherwise they are listed by the group itself and may include groups. Nested
+// members are only resolved here for in_chain, expand works on the stored direct memberships.
+func (s *Source) toGroupRecord(ctx context.Context, entry *ldap.Entry) (*source.GroupRecord, error) {
+	settings := s.config.Groups
+	now := time.Now()
+	group := &domain.Group{
+		DN:          entry.DN,
+		Name:        entry.GetAttributeValue(valueOr(settings.NameAttribute, defaultNameAttribute)),
+		Description: entry.GetAttributeValue(valueOr(settings.DescriptionAttribute, defaultDescriptionAttribute)),
+		CreatedAt:   ldapTimeOr(entry.GetAttributeValue(s.createdAt), now),
+		UpdatedAt:   ldapTimeOr(entry.GetAttributeValue(s.updatedAt), now),
+	}
+	if group.Name == "" {
+		if dn, err := ldap.ParseDN(entry.DN); err == nil && len(dn.RDNs) > 0 && len(dn.RDNs[0].Attributes) > 0 {
+			group.Name = dn.RDNs[0].Attributes[0].Value
+		}
+	}
+
+	record := &source.GroupRecord{Group: group}
+	if settings.MemberOfAttribute != "" {
+		filter := fmt.Sprintf("(%s=%s)", settings.MemberOfAttribute, ldap.EscapeFilter(entry.DN))
+		members, err := s.searchUserDNs(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		record.Members = members
+	} else {
+		record.Members = entry.GetAttributeValues(valueOr(settings.MemberAttribute, defaultMemberAttribute))
+	}
+
+	if settings.Nested == domain.LDAPNestedGroupsInChain {
+		memberOf := valueOr(settings.MemberOfAttribute, defaultMemberOfAttribute)
+		filter := fmt.Sprintf("(%s:%s:=%s)", memberOf, matchingRuleInChain, ldap.EscapeFilter(entry.DN))
+		nested, err := s.searchUserDNs(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		record.Nested = nested
+	}
+
+	return record, nil
+}
+
+// ancestors returns the groups that contain the given group through any level of nesting, under every
+// group base and not excluded.
+func (s *Source) ancestors(ctx context.Context, dn string) ([]string, error) {
+	settings := s.config.Groups
+	filter := fmt.Sprintf("(&%s(%s:%s:=%s))",
+		valueOr(settings.Filter, defaultGroupFilter),
+		valueOr(settings.MemberAttribute, defaultMemberAttribute),
+		matchingRuleInChain,
+		ldap.EscapeFilter(dn))
+
+	var dns []string
+	for _, baseDN := range s.groupBaseDNs() {
+		found, err := s.searchDNs(ctx, baseDN, ldap.ScopeWholeSubtree, filter)
+		if err != nil {
+			return nil, err
+		}
+		for _, dn := range found {
+			if !s.excluded(dn) {
+				dns = append(dns, dn)
+			}
+		}
+	}
+	return dns, nil
+}
+
+// searchDNs returns the DNs of every entry matching the filter, following the paging cookie.
+func (s *Source) searchDNs(ctx context.Context, baseDN string, scope int, filter string) ([]string, error) {
+	_, span := tracing.Tracer().Start(ctx, "ldap.search_members",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String(tracing.AttrConnectorID, s.connectorID)))
+
+	var dns []string
+	var err error
+	pagingControl := ldap.NewControlPaging(s.config.SyncSettings.BatchSize)
+	for {
+		var resp *ldap.SearchResult
+		resp, err = s.conn.Search(&ldap.SearchRequest{
+			BaseDN:       baseDN,
+			Scope:        scope,
+			DerefAliases: ldap.NeverDerefAliases,
+			TimeLimit:    int(s.config.ReadTimeout),
+			Filter:       filter,
+			Attributes:   []string{"1.1"}, // no attributes, only the DN
+			Controls:     []ldap.Control{pagingControl},
+		})
+		if err != nil {
+			break
+		}
+
+		for _, entry := range resp.Entries {
+			dns = append(dns, entry.DN)
+		}
+
+		ctrl, ok := ldap.FindControl(resp.Controls, ldap.ControlTypePaging).(*ldap.ControlPaging)
+		if !ok || ctrl == nil || len(ctrl.Cookie) == 0 {
+			break
+		}
+		pagingControl.SetCookie(ctrl.Cookie)
+	}
+
+	span.SetAttributes(attribute.Int("ldap.entries", len(dns)))
+	tracing.End(span, err)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return dns, err
+}
+
+// groupIterator pages through the groups under each base in turn, skipping the excluded ones. With in_chain
+// on an incremental sync, a change in a group also changes the nested members of the groups containing it,
+// those are read again once the changed groups are done.
+type groupIterator struct {
+	source        *Source
+	filter        string
+	bases         []string
+	incremental   bool
+	pagingControl *ldap.ControlPaging
+	base          int
+	page          int
+	read          map[string]struct{}
+	ancestors     []string
+	searched      bool
+}
+
+func (it *groupIterator) Next(ctx context.Context) ([]*source.GroupRecord, error) {
+	s := it.source
+	var entries []*ldap.Entry
+	var err error
+	if !it.searched {
+		entries, err = it.search(ctx)
+	} else {
+		entries, err = it.readAncestors(ctx)
+	}
+	if err != nil {
+		s.logger.Error("LDAPSource - groupIterator.Next - it.search", zap.Error(err))
+		return nil, err
+	}
+
+	records := make([]*source.GroupRecord, 0, len(entries))
+	for _, entry := range entries {
+		it.read[entry.DN] = struct{}{}
+		record, err := s.toGroupRecord(ctx, entry)
+		if err != nil {
+			s.logger.Error("LDAPSource - groupIterator.Next - s.toGroupRecord", zap.Error(err))
+			return nil, err
+		}
+		records = append(records, record)
+
+		if it.incremental && s.config.Groups.Nested == domain.LDAPNestedGroupsInChain {
+			ancestors, err := s.ancestors(ctx, entry.DN)
+			if err != nil {
+				s.logger.Error("LDAPSource - groupIterator.Next - s.ancestors", zap.Error(err))
+				return nil, err
+			}
+			it.ancestors = append(it.ancestors, ancestors...)
+		}
+	}
+
+	return records, nil
+}
+
+func (it *groupIterator) Done() bool {
+	if !it.searched {
+		return false
+	}
+
+	for len(it.ancestors) > 0 {
+		if _, ok := it.read[it.ancestors[0]]; !ok {
+			return false
+		}
+		it.ancestors = it.ancestors[1:]
+	}
+	return true
+}
+
+func (it *groupIterator) search(ctx context.Context) ([]*ldap.Entry, error) {
+	s := it.source
+	it.page++
+
+	baseDN := it.bases[it.base]
+	_, span := tracing.Tracer().Start(ctx, "ldap.search_groups",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String(tracing.AttrConnectorID, s.connectorID),
+			attribute.String("ldap.base_dn", baseDN),
+			attribute.Int("ldap.page", it.page),
+		))
+	resp, err := s.conn.Search(&ldap.SearchRequest{
+		BaseDN:       baseDN,
+		Scope:        ldap.ScopeWholeSubtree,
+		DerefAliases: ldap.NeverDerefAliases,
+		TimeLimit:    int(s.config.ReadTimeout),
+		Filter:       it.filter,
+		Attributes:   s.groupAttributes(),
+		Controls:     []ldap.Control{it.pagingControl},
+	})
+	if err == nil {
+		span.SetAttributes(attribute.Int("ldap.entries", len(resp.Entries)))
+	}
+	tracing.End(span, err)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	baseDone := true
+	ctrl, ok := ldap.FindControl(resp.Controls, ldap.ControlTypePaging).(*ldap.ControlPaging)
+	if ok && ctrl != nil && len(ctrl.Cookie) != 0 {
+		it.pagingControl.SetCookie(ctrl.Cookie)
+		baseDone = len(resp.Entries) == 0
+	}
+	if baseDone {
+		it.base++
+		it.searched = it.base == len(it.bases)
+		it.pagingControl = ldap.NewControlPaging(s.config.SyncSettings.BatchSize)
+	}
+
+	entries := make([]*ldap.Entry, 0, len(resp.Entries))
+	for _, entry := range resp.Entries {
+		if !s.excluded(entry.DN) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// readAncestors reads a batch of the ancestor groups not read yet, one base search per group.
+func (it *groupIterator) readAncestors(ctx context.Context) ([]*ldap.Entry, error) {
+	s := it.source
+	batchSize := max(int(s.config.SyncSettings.BatchSize), 1)
+
+	var entries []*ldap.Entry
+	for len(it.ancestors) > 0 && len(entries) < batchSize {
+		dn := it.ancestors[0]
+		it.ancestors = it.ancestors[1:]
+		if _, ok := it.read[dn]; ok {
+			continue
+		}
+
+		resp, err := s.conn.Search(&ldap.SearchRequest{
+			BaseDN:       dn,
+			Scope:        ldap.ScopeBaseObject,
+			DerefAliases: ldap.NeverDerefAliases,
+			TimeLimit:    int(s.config.ReadTimeout),
+			Filter:       "(objectClass=*)",
+			Attributes:   s.groupAttributes(),
+		})
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, resp.Entries...)
+	}
+
+	return entries, nil
+}
+
+func valueOr(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+func ldapTimeOr(value string, fallback time.Time) time.Time {
+	t, err := utils.LDAPStringToTime(value)
+	if err != nil || t.IsZero() {
+		return fallback
+	}
+	return t
+}
@@ -0,0 +1,92 @@
+package ldapsource
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/tuanta7/qworker/internal/domain"
+	"github.com/tuanta7/qworker/internal/mapping"
+	"github.com/tuanta7/qworker/internal/source"
+	"strings"
+)
+
+const (
+	// accountNeverExpires is the Active Directory accountExpires value of accounts that never expire, an active
+	// mapping of accountExpires only holds for them.
+	accountNeverExpires = "9223372036854775807"
+	// objectGUID is the binary identifier of Active Directory entries, read in its string form.
+	objectGUID = "objectGUID"
+)
+
+func toRecord(entry *ldap.Entry) *source.Record {
+	values := attributeValues(entry)
+	attributes := make(map[string][]string, len(entry.Attributes))
+	for _, attr := range entry.Attributes {
+		attributes[attr.Name] = values(attr.Name)
+	}
+
+	return &source.Record{
+		ID:         entry.DN,
+		Attributes: attributes,
+		Native:     entry,
+	}
+}
+
+// toUser evaluates the mapping on the entry, attribute names are matched case-insensitively like LDAP does.
+func toUser(entry *ldap.Entry, m *mapping.Mapping) (*domain.User, error) {
+	values := attributeValues(entry)
+	createdAt, _ := source.ParseTime(m.Value(domain.MappingCreatedAt, values))
+	updatedAt, _ := source.ParseTime(m.Value(domain.MappingUpdatedAt, values))
+
+	// the presets derive active from status rules, mappers written before them map accountExpires
+	active := m.Value(domain.StatusActive, values)
+
+	data, err := source.CustomData(m, values)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &domain.User{
+		ExternalID:  m.Value(domain.MappingExternalID, values),
+		FullName:    m.Value(domain.MappingFullName, values),
+		Username:    m.Value(domain.MappingUsername, values),
+		PhoneNumber: m.Value(domain.MappingPhoneNumber, values),
+		Email:       m.Value(domain.MappingEmail, values),
+		SourceDN:    entry.DN,
+		Active:      active == accountNeverExpires || strings.EqualFold(active, "true"),
+		Data:        data,
+		CreatedAt:   createdAt,
+		UpdatedAt:   updatedAt,
+	}
+	return user, source.ApplyStatus(user, m, values)
+}
+
+// attributeValues reads the values of an entry attribute, matched case-insensitively like LDAP does.
+func attributeValues(entry *ldap.Entry) func(attr string) []string {
+	return func(attr string) []string {
+		if !strings.EqualFold(attr, objectGUID) {
+			return entry.GetEqualFoldAttributeValues(attr)
+		}
+
+		raw := entry.GetEqualFoldRawAttributeValues(attr)
+		values := make([]string, len(raw))
+		for i, value := range raw {
+			values[i] = guidString(value)
+		}
+		return values
+	}
+}
+
+// guidString formats a binary GUID like Active Directory does, the first three groups being little-endian.
+// Values that are not 16 bytes long, such as a GUID already in its string form, are returned as is.
+func guidString(b []byte) string {
+	if len(b) != 16 {
+		return string(b)
+	}
+	return fmt.Sprintf("%08x-%04x-%04x-%x-%x",
+		binary.LittleEndian.Uint32(b[0:4]),
+		binary.LittleEndian.Uint16(b[4:6]),
+		binary.LittleEndian.Uint16(b[6:8]),
+		b[8:10],
+		b[10:16])
+}
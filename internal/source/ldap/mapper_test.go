@@ -0,0 +1,34 @@
+package ldapsource
+
+import (
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/tuanta7/qworker/internal/domain"
+	"github.com/tuanta7/qworker/internal/mapping"
+	"testing"
+)
+
+func TestObjectGUID(t *testing.T) {
+	guid := []byte{0xff, 0x19, 0x96, 0x6f, 0x86, 0x8b, 0x11, 0xd0, 0xb4, 0x2d, 0x00, 0xc0, 0x4f, 0xc9, 0x64, 0xff}
+	entry := &ldap.Entry{
+		DN: "CN=Alice,OU=People,DC=example,DC=com",
+		Attributes: []*ldap.EntryAttribute{
+			{Name: "objectGUID", Values: []string{string(guid)}, ByteValues: [][]byte{guid}},
+			{Name: "sAMAccountName", Values: []string{"alice"}, ByteValues: [][]byte{[]byte("alice")}},
+		},
+	}
+
+	mapper, err := domain.Mapper{Preset: domain.MapperPresetActiveDirectory}.WithPreset()
+	assert.NoError(t, err)
+	m, err := mapping.Compile(mapper)
+	assert.NoError(t, err)
+
+	user, err := toUser(entry, m)
+	assert.NoError(t, err)
+	assert.Equal(t, "6f9619ff-8b86-d011-b42d-00c04fc964ff", user.ExternalID)
+	assert.Equal(t, "alice", user.Username)
+	assert.NoError(t, user.Validate())
+
+	assert.Equal(t, []string{"6f9619ff-8b86-d011-b42d-00c04fc964ff"}, toRecord(entry).Attributes["objectGUID"])
+	assert.Equal(t, "6f9619ff-8b86-d011-b42d-00c04fc964ff", guidString([]byte("6f9619ff-8b86-d011-b42d-00c04fc964ff")))
+}
@@ -0,0 +1,107 @@
+package ldapsource
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/tuanta7/qworker/internal/domain"
+)
+
+// searchBase is a DN the users are searched under, with its go-ldap scope.
+type searchBase struct {
+	dn    string
+	scope int
+}
+
+// searchBases returns the bases of the user search, a connector without search bases searches BaseDN
+// one level deep like it always did.
+func searchBases(config *domain.LDAPConnector) ([]searchBase, error) {
+	if len(config.SearchBases) == 0 {
+		if config.BaseDN == "" {
+			return nil, errors.New("ldap connector has no base dn")
+		}
+		return []searchBase{{dn: config.BaseDN, scope: ldap.ScopeSingleLevel}}, nil
+	}
+
+	bases := make([]searchBase, 0, len(config.SearchBases))
+	for _, base := range config.SearchBases {
+		if base.DN == "" {
+			return nil, errors.New("ldap connector search base has no dn")
+		}
+
+		var scope int
+		switch base.Scope {
+		case domain.LDAPScopeBase:
+			scope = ldap.ScopeBaseObject
+		case domain.LDAPScopeOne:
+			scope = ldap.ScopeSingleLevel
+		case "", domain.LDAPScopeSubtree:
+			scope = ldap.ScopeWholeSubtree
+		default:
+			return nil, fmt.Errorf("unsupported ldap search scope: %q", base.Scope)
+		}
+		bases = append(bases, searchBase{dn: base.DN, scope: scope})
+	}
+	return bases, nil
+}
+
+// userFilter checks the user object filter, which keeps computers, groups and containers out of the users.
+func userFilter(config *domain.LDAPConnector) (string, error) {
+	if config.UserFilter == "" {
+		return "", errors.New("ldap connector has no user filter")
+	}
+	if _, err := ldap.CompileFilter(config.UserFilter); err != nil {
+		return "", fmt.Errorf("invalid ldap user filter: %w", err)
+	}
+	return config.UserFilter, nil
+}
+
+func excludedDNs(config *domain.LDAPConnector) ([]*ldap.DN, error) {
+	excluded := make([]*ldap.DN, 0, len(config.ExcludeDNs))
+	for _, raw := range config.ExcludeDNs {
+		dn, err := ldap.ParseDN(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ldap exclude dn %q: %w", raw, err)
+		}
+		excluded = append(excluded, dn)
+	}
+	return excluded, nil
+}
+
+// excluded reports whether an entry is one of the excluded DNs or lies under one of them.
+func (s *Source) excluded(raw string) bool {
+	if len(s.excludedDNs) == 0 {
+		return false
+	}
+
+	dn, err := ldap.ParseDN(raw)
+	if err != nil {
+		return false
+	}
+	for _, excluded := range s.excludedDNs {
+		if excluded.EqualFold(dn) || excluded.AncestorOfFold(dn) {
+			return true
+		}
+	}
+	return false
+}
+
+// searchUserDNs returns the DNs of the users matching the filter under every search base.
+func (s *Source) searchUserDNs(ctx context.Context, filter string) ([]string, error) {
+	filter = fmt.Sprintf("(&%s%s)", s.userFilter, filter)
+
+	var dns []string
+	for _, base := range s.bases {
+		found, err := s.searchDNs(ctx, base.dn, base.scope, filter)
+		if err != nil {
+			return nil, err
+		}
+		for _, dn := range found {
+			if !s.excluded(dn) {
+				dns = append(dns, dn)
+			}
+		}
+	}
+	return dns, nil
+}
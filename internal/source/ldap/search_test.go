@@ -0,0 +1,86 @@
+package ldapsource
+
+import (
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/tuanta7/qworker/internal/domain"
+	"testing"
+	"time"
+)
+
+func TestSearchConfig(t *testing.T) {
+	t.Run("legacy_base_dn", func(t *testing.T) {
+		bases, err := searchBases(&domain.LDAPConnector{BaseDN: "OU=People,DC=example,DC=com"})
+		assert.NoError(t, err)
+		assert.Equal(t, []searchBase{{dn: "OU=People,DC=example,DC=com", scope: ldap.ScopeSingleLevel}}, bases)
+	})
+
+	t.Run("search_bases", func(t *testing.T) {
+		bases, err := searchBases(&domain.LDAPConnector{
+			BaseDN: "DC=example,DC=com",
+			SearchBases: []domain.LDAPSearchBase{
+				{DN: "OU=Staff,DC=example,DC=com"},
+				{DN: "OU=Contractors,DC=example,DC=com", Scope: domain.LDAPScopeOne},
+				{DN: "CN=Admin,DC=example,DC=com", Scope: domain.LDAPScopeBase},
+			},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []searchBase{
+			{dn: "OU=Staff,DC=example,DC=com", scope: ldap.ScopeWholeSubtree},
+			{dn: "OU=Contractors,DC=example,DC=com", scope: ldap.ScopeSingleLevel},
+			{dn: "CN=Admin,DC=example,DC=com", scope: ldap.ScopeBaseObject},
+		}, bases)
+
+		_, err = searchBases(&domain.LDAPConnector{SearchBases: []domain.LDAPSearchBase{{DN: "DC=example,DC=com", Scope: "deep"}}})
+		assert.EqualError(t, err, `unsupported ldap search scope: "deep"`)
+	})
+
+	t.Run("user_filter", func(t *testing.T) {
+		_, err := userFilter(&domain.LDAPConnector{})
+		assert.EqualError(t, err, "ldap connector has no user filter")
+
+		_, err = userFilter(&domain.LDAPConnector{UserFilter: "objectClass=user"})
+		assert.ErrorContains(t, err, "invalid ldap user filter")
+	})
+
+	t.Run("exclude_dns", func(t *testing.T) {
+		excluded, err := excludedDNs(&domain.LDAPConnector{ExcludeDNs: []string{"OU=Service Accounts,DC=example,DC=com"}})
+		assert.NoError(t, err)
+
+		s := &Source{excludedDNs: excluded}
+		assert.True(t, s.excluded("ou=service accounts,dc=example,dc=com"))
+		assert.True(t, s.excluded("CN=svc-backup,OU=Service Accounts,DC=example,DC=com"))
+		assert.False(t, s.excluded("CN=Alice,OU=Staff,DC=example,DC=com"))
+	})
+
+	t.Run("group_bases", func(t *testing.T) {
+		bases, err := searchBases(&domain.LDAPConnector{SearchBases: []domain.LDAPSearchBase{
+			{DN: "OU=Staff,DC=example,DC=com"},
+			{DN: "OU=Interns,OU=Staff,DC=example,DC=com"},
+			{DN: "OU=Contractors,DC=example,DC=com", Scope: domain.LDAPScopeOne},
+			{DN: "ou=staff,dc=example,dc=com"},
+		}})
+		assert.NoError(t, err)
+
+		s := &Source{config: &domain.LDAPConnector{}, bases: bases}
+		assert.Equal(t, []string{"OU=Staff,DC=example,DC=com", "OU=Contractors,DC=example,DC=com"}, s.groupBaseDNs())
+
+		s.config.Groups.BaseDN = "OU=Groups,DC=example,DC=com"
+		assert.Equal(t, []string{"OU=Groups,DC=example,DC=com"}, s.groupBaseDNs())
+	})
+
+	t.Run("incremental_filter", func(t *testing.T) {
+		s := &Source{
+			config:     &domain.LDAPConnector{},
+			userFilter: "(&(objectCategory=person)(objectClass=user))",
+			updatedAt:  "whenChanged",
+		}
+		assert.Equal(t, s.userFilter, s.Iterate(time.Time{}).(*pageIterator).filter)
+
+		since := time.Date(2025, 6, 3, 9, 45, 30, 0, time.UTC)
+		filter := s.Iterate(since).(*pageIterator).filter
+		assert.Equal(t, "(&(&(objectCategory=person)(objectClass=user))(whenChanged>=20250603094530.0Z))", filter)
+		_, err := ldap.CompileFilter(filter)
+		assert.NoError(t, err)
+	})
+}
@@ -0,0 +1,232 @@
+package ldapsource
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/tuanta7/qworker/internal/domain"
+	"github.com/tuanta7/qworker/internal/mapping"
+	"github.com/tuanta7/qworker/internal/metrics"
+	"github.com/tuanta7/qworker/internal/source"
+	"github.com/tuanta7/qworker/pkg/cipherx"
+	"github.com/tuanta7/qworker/pkg/ldapclient"
+	"github.com/tuanta7/qworker/pkg/logger"
+	"github.com/tuanta7/qworker/pkg/tracing"
+	"github.com/tuanta7/qworker/pkg/utils"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"strconv"
+	"time"
+)
+
+// Register adds the LDAP source to the registry.
+func Register(r *source.Registry, client ldapclient.LDAPClient, cipher cipherx.Cipher, zl *logger.ZapLogger) {
+	source.Register(r, domain.ConnectorTypeLDAP, source.JSONDecoder[domain.LDAPConnector](),
+		func(connector *domain.Connector, config *domain.LDAPConnector) (source.SyncSource, error) {
+			m, err := mapping.Compile(connector.Mapper)
+			if err != nil {
+				return nil, err
+			}
+
+			updatedAt, err := m.Attribute(domain.MappingUpdatedAt)
+			if err != nil {
+				return nil, err
+			}
+			// groups only read the plain timestamp attributes, an expression leaves them at the sync time
+			createdAt, _ := m.Attribute(domain.MappingCreatedAt)
+
+			bases, err := searchBases(config)
+			if err != nil {
+				return nil, err
+			}
+			filter, err := userFilter(config)
+			if err != nil {
+				return nil, err
+			}
+			excluded, err := excludedDNs(config)
+			if err != nil {
+				return nil, err
+			}
+
+			return &Source{
+				connector:   connector,
+				config:      config,
+				mapping:     m,
+				createdAt:   createdAt,
+				updatedAt:   updatedAt,
+				bases:       bases,
+				userFilter:  filter,
+				excludedDNs: excluded,
+				connectorID: strconv.FormatUint(connector.ConnectorID, 10),
+				client:      client,
+				cipher:      cipher,
+				logger:      zl,
+			}, nil
+		})
+}
+
+type Source struct {
+	connector   *domain.Connector
+	config      *domain.LDAPConnector
+	mapping     *mapping.Mapping
+	createdAt   string
+	updatedAt   string
+	bases       []searchBase
+	userFilter  string
+	excludedDNs []*ldap.DN
+	connectorID string
+	client      ldapclient.LDAPClient
+	cipher      cipherx.Cipher
+	logger      *logger.ZapLogger
+	conn        ldapclient.LDAPConn
+	stopAbort   func() bool
+}
+
+func (s *Source) Connect(ctx context.Context) error {
+	conn, err := s.client.NewConnection(s.config.URL, s.config.ConnectTimeout*time.Millisecond)
+	if err != nil {
+		s.logger.Error("LDAPSource - Connect - s.client.NewConnection", zap.Error(err))
+		return err
+	}
+	s.conn = conn
+
+	// go-ldap does not take a context, closing the connection aborts a search in the middle of a page
+	s.stopAbort = context.AfterFunc(ctx, func() { _ = conn.Close() })
+
+	pwd, err := s.cipher.Decrypt(s.config.SystemAccountPassword)
+	if err != nil {
+		s.logger.Error("LDAPSource - Connect - s.cipher.Decrypt", zap.Error(err))
+		return err
+	}
+
+	_, span := tracing.Tracer().Start(ctx, "ldap.bind",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String(tracing.AttrConnectorID, s.connectorID)))
+	err = conn.Bind(s.config.SystemAccountDN, pwd)
+	tracing.End(span, err)
+	if err != nil {
+		s.logger.Error("LDAPSource - Connect - conn.Bind", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+// Iterate pages through the users of every search base in turn, incremental syncs narrow the user filter
+// to the entries changed since the watermark.
+func (s *Source) Iterate(since time.Time) source.PageIterator {
+	filter := s.userFilter
+	if !since.IsZero() {
+		filter = fmt.Sprintf("(&%s(%s>=%s))", s.userFilter, s.updatedAt, utils.TimeToLDAPString(since))
+	}
+
+	return &pageIterator{
+		source:        s,
+		filter:        filter,
+		pagingControl: ldap.NewControlPaging(s.config.SyncSettings.BatchSize),
+	}
+}
+
+func (s *Source) Map(record *source.Record) (*domain.User, error) {
+	entry, ok := record.Native.(*ldap.Entry)
+	if !ok {
+		return nil, fmt.Errorf("record %s is not an LDAP entry", record.ID)
+	}
+
+	return toUser(entry, s.mapping)
+}
+
+func (s *Source) Mapping() *mapping.Mapping {
+	return s.mapping
+}
+
+func (s *Source) Values(record *source.Record) func(attr string) []string {
+	entry, ok := record.Native.(*ldap.Entry)
+	if !ok {
+		return func(attr string) []string { return source.Values(record, attr) }
+	}
+	return attributeValues(entry)
+}
+
+func (s *Source) Close() error {
+	if s.stopAbort != nil {
+		s.stopAbort()
+	}
+	if s.conn == nil {
+		return nil
+	}
+	return s.conn.Close()
+}
+
+type pageIterator struct {
+	source        *Source
+	filter        string
+	pagingControl *ldap.ControlPaging
+	base          int
+	page          int
+	done          bool
+}
+
+func (it *pageIterator) Next(ctx context.Context) ([]*source.Record, error) {
+	s := it.source
+	it.page++
+
+	base := s.bases[it.base]
+
+	_, span := tracing.Tracer().Start(ctx, "ldap.search",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String(tracing.AttrConnectorID, s.connectorID),
+			attribute.String("ldap.base_dn", base.dn),
+			attribute.Int("ldap.page", it.page),
+		))
+	start := time.Now()
+	resp, err := s.conn.Search(&ldap.SearchRequest{
+		BaseDN:       base.dn,
+		TimeLimit:    int(s.config.ReadTimeout),
+		SizeLimit:    0,
+		Scope:        base.scope,
+		DerefAliases: ldap.NeverDerefAliases,
+		Filter:       it.filter,
+		Controls:     []ldap.Control{it.pagingControl},
+	})
+	if err == nil {
+		span.SetAttributes(attribute.Int("ldap.entries", len(resp.Entries)))
+	}
+	tracing.End(span, err)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		s.logger.Error("LDAPSource - Next - s.conn.Search", zap.Error(err))
+		return nil, err
+	}
+	metrics.LDAPSearchDuration.WithLabelValues(s.connectorID).Observe(time.Since(start).Seconds())
+	metrics.LDAPEntriesPerPage.WithLabelValues(s.connectorID).Observe(float64(len(resp.Entries)))
+
+	baseDone := true
+	updatedControl := ldap.FindControl(resp.Controls, ldap.ControlTypePaging)
+	if ctrl, ok := updatedControl.(*ldap.ControlPaging); ctrl != nil && ok && len(ctrl.Cookie) != 0 {
+		it.pagingControl.SetCookie(ctrl.Cookie)
+		baseDone = len(resp.Entries) == 0
+	}
+	if baseDone {
+		it.base++
+		it.done = it.base == len(s.bases)
+		it.pagingControl = ldap.NewControlPaging(s.config.SyncSettings.BatchSize)
+	}
+
+	records := make([]*source.Record, 0, len(resp.Entries))
+	for _, entry := range resp.Entries {
+		if !s.excluded(entry.DN) {
+			records = append(records, toRecord(entry))
+		}
+	}
+
+	return records, nil
+}
+
+func (it *pageIterator) Done() bool {
+	return it.done
+}
@@ -0,0 +1,71 @@
+package source
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/tuanta7/qworker/internal/domain"
+	"github.com/tuanta7/qworker/pkg/utils"
+	"sync"
+)
+
+// Decoder parses the connector data into the configuration of a source type.
+type Decoder[C any] func(raw []byte) (*C, error)
+
+// Factory creates a source for a single sync run from the decoded configuration.
+type Factory[C any] func(connector *domain.Connector, config *C) (SyncSource, error)
+
+type constructor func(connector *domain.Connector) (SyncSource, error)
+
+// Registry maps connector types to their sources, so that adding a type does not touch the sync pipeline.
+type Registry struct {
+	lock         sync.RWMutex
+	constructors map[domain.ConnectorType]constructor
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		constructors: make(map[domain.ConnectorType]constructor),
+	}
+}
+
+// Register adds a connector type to the registry, registering the same type twice panics.
+func Register[C any](r *Registry, t domain.ConnectorType, decode Decoder[C], factory Factory[C]) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if _, exists := r.constructors[t]; exists {
+		panic(fmt.Sprintf("source: connector type %q registered twice", t))
+	}
+
+	r.constructors[t] = func(connector *domain.Connector) (SyncSource, error) {
+		config, err := decode(connector.Data.Raw)
+		if err != nil {
+			return nil, fmt.Errorf("decode %s connector config: %w", t, err)
+		}
+		return factory(connector, config)
+	}
+}
+
+func (r *Registry) New(connector *domain.Connector) (SyncSource, error) {
+	r.lock.RLock()
+	newSource, exists := r.constructors[connector.ConnectorType]
+	r.lock.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", utils.ErrUnsupportedConnectorType, connector.ConnectorType)
+	}
+
+	return newSource(connector)
+}
+
+// JSONDecoder decodes configurations stored as JSON, which is the case for every built-in source.
+func JSONDecoder[C any]() Decoder[C] {
+	return func(raw []byte) (*C, error) {
+		config := new(C)
+		err := json.Unmarshal(raw, config)
+		if err != nil {
+			return nil, err
+		}
+		return config, nil
+	}
+}
@@ -0,0 +1,61 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/tuanta7/qworker/internal/domain"
+	"github.com/tuanta7/qworker/pkg/sqlxx"
+	"github.com/tuanta7/qworker/pkg/utils"
+	"testing"
+	"time"
+)
+
+type fakeConfig struct {
+	BaseURL string `json:"baseUrl"`
+}
+
+type fakeSource struct {
+	config *fakeConfig
+}
+
+func (f *fakeSource) Connect(context.Context) error     { return nil }
+func (f *fakeSource) Iterate(time.Time) PageIterator    { return nil }
+func (f *fakeSource) Map(*Record) (*domain.User, error) { return &domain.User{}, nil }
+func (f *fakeSource) Close() error                      { return nil }
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	Register(r, domain.ConnectorTypeSCIM, JSONDecoder[fakeConfig](),
+		func(c *domain.Connector, config *fakeConfig) (SyncSource, error) {
+			return &fakeSource{config: config}, nil
+		})
+
+	t.Run("registered_type", func(t *testing.T) {
+		src, err := r.New(&domain.Connector{
+			ConnectorType: domain.ConnectorTypeSCIM,
+			Data:          sqlxx.TextData{Raw: []byte(`{"baseUrl":"https://idp.example.com/scim/v2"}`)},
+		})
+		assert.Equal(t, nil, err)
+		assert.Equal(t, "https://idp.example.com/scim/v2", src.(*fakeSource).config.BaseURL)
+	})
+
+	t.Run("invalid_config", func(t *testing.T) {
+		_, err := r.New(&domain.Connector{
+			ConnectorType: domain.ConnectorTypeSCIM,
+			Data:          sqlxx.TextData{Raw: []byte(`{`)},
+		})
+		assert.NotEqual(t, nil, err)
+	})
+
+	t.Run("unsupported_type", func(t *testing.T) {
+		_, err := r.New(&domain.Connector{ConnectorType: domain.ConnectorTypeLDAP})
+		assert.True(t, errors.Is(err, utils.ErrUnsupportedConnectorType))
+	})
+
+	t.Run("register_twice", func(t *testing.T) {
+		assert.Panics(t, func() {
+			Register(r, domain.ConnectorTypeSCIM, JSONDecoder[fakeConfig](), nil)
+		})
+	})
+}
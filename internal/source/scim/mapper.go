@@ -0,0 +1,107 @@
+package scimsource
+
+import (
+	"fmt"
+	"github.com/tuanta7/qworker/internal/domain"
+	"github.com/tuanta7/qworker/internal/mapping"
+	"github.com/tuanta7/qworker/internal/source"
+	"strings"
+	"time"
+)
+
+// WithDefaults fills the fields left empty in a connector mapper from DefaultMapper.
+func WithDefaults(m domain.Mapper) domain.Mapper {
+	fields := []struct {
+		value    *string
+		fallback string
+	}{
+		{&m.ExternalID, DefaultMapper.ExternalID},
+		{&m.Username, DefaultMapper.Username},
+		{&m.FullName, DefaultMapper.FullName},
+		{&m.Email, DefaultMapper.Email},
+		{&m.PhoneNumber, DefaultMapper.PhoneNumber},
+		{&m.CreatedAt, DefaultMapper.CreatedAt},
+		{&m.UpdatedAt, DefaultMapper.UpdatedAt},
+	}
+	for _, f := range fields {
+		if *f.value == "" {
+			*f.value = f.fallback
+		}
+	}
+
+	custom := make(map[string]string, len(DefaultMapper.Custom)+len(m.Custom))
+	for k, v := range DefaultMapper.Custom {
+		custom[k] = v
+	}
+	for k, v := range m.Custom {
+		custom[k] = v
+	}
+	m.Custom = custom
+
+	return m
+}
+
+func toRecord(resource map[string]any) *source.Record {
+	id, _ := source.JSONString(resource["id"])
+	return &source.Record{
+		ID:         id,
+		Attributes: source.Flatten(resource),
+		Native:     resource,
+	}
+}
+
+// MapResource maps a SCIM user resource like the pull connector does, the inbound SCIM server shares it.
+func MapResource(resource map[string]any, mapper domain.Mapper) (*domain.User, error) {
+	m, err := mapping.Compile(WithDefaults(mapper))
+	if err != nil {
+		return nil, err
+	}
+	return toUser(resource, m)
+}
+
+func toUser(resource map[string]any, m *mapping.Mapping) (*domain.User, error) {
+	values := func(path string) []string { return lookup(resource, path) }
+	createdAt, err := parseTime(m.Value(domain.MappingCreatedAt, values))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", domain.MappingCreatedAt, err)
+	}
+
+	updatedAt, err := parseTime(m.Value(domain.MappingUpdatedAt, values))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", domain.MappingUpdatedAt, err)
+	}
+
+	data, err := source.CustomData(m, values)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &domain.User{
+		ExternalID:  m.Value(domain.MappingExternalID, values),
+		Username:    m.Value(domain.MappingUsername, values),
+		FullName:    m.Value(domain.MappingFullName, values),
+		PhoneNumber: m.Value(domain.MappingPhoneNumber, values),
+		Email:       m.Value(domain.MappingEmail, values),
+		Active:      strings.EqualFold(m.Value(domain.StatusActive, values), "true"),
+		Data:        data,
+		CreatedAt:   createdAt,
+		UpdatedAt:   updatedAt,
+	}
+	return user, source.ApplyStatus(user, m, values)
+}
+
+func first(resource map[string]any, path string) string {
+	values := lookup(resource, path)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// parseTime leaves a missing timestamp zero, like the LDAP mapper, so that the repository keeps the stored one.
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339Nano, value)
+}
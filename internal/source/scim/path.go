@@ -0,0 +1,137 @@
+package scimsource
+
+import (
+	"github.com/tuanta7/qworker/internal/source"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// valueFilterPattern matches the simple value filters of RFC 7644 section 3.10, such as emails[type eq "work"].
+var valueFilterPattern = regexp.MustCompile(`^([^\[]+)\[\s*(\w+)\s+eq\s+"([^"]*)"\s*\]$`)
+
+// lookup resolves an attribute path against a SCIM resource. Attribute names are case-insensitive,
+// extension attributes are addressed by their schema URN, e.g.
+// "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:department". Values of multi-valued
+// attributes are returned primary first.
+func lookup(resource map[string]any, path string) []string {
+	if path == "" {
+		return nil
+	}
+
+	var node any = resource
+	if strings.HasPrefix(strings.ToLower(path), "urn:") {
+		node, path = lookupExtension(resource, path)
+		if node == nil {
+			return nil
+		}
+	}
+
+	nodes := []any{node}
+	for _, segment := range strings.Split(path, ".") {
+		name, filterAttr, filterValue := parseSegment(segment)
+
+		var next []any
+		for _, n := range nodes {
+			obj, ok := n.(map[string]any)
+			if !ok {
+				continue
+			}
+
+			v, ok := getFold(obj, name)
+			if !ok {
+				continue
+			}
+
+			values, isList := v.([]any)
+			if !isList {
+				next = append(next, v)
+				continue
+			}
+
+			for _, item := range primaryFirst(values) {
+				if filterAttr != "" && !matches(item, filterAttr, filterValue) {
+					continue
+				}
+				next = append(next, item)
+			}
+		}
+		nodes = next
+	}
+
+	result := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		if s, ok := source.JSONString(n); ok {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// lookupExtension returns the extension object whose URN prefixes the path and the path left inside it.
+func lookupExtension(resource map[string]any, path string) (any, string) {
+	var best string
+	for key := range resource {
+		if len(key) > len(best) && len(path) > len(key) && path[len(key)] == ':' && strings.EqualFold(path[:len(key)], key) {
+			best = key
+		}
+	}
+	if best == "" {
+		return nil, ""
+	}
+
+	return resource[best], path[len(best)+1:]
+}
+
+func parseSegment(segment string) (name, filterAttr, filterValue string) {
+	m := valueFilterPattern.FindStringSubmatch(segment)
+	if m == nil {
+		return segment, "", ""
+	}
+	return m[1], m[2], m[3]
+}
+
+func getFold(obj map[string]any, name string) (any, bool) {
+	if v, ok := obj[name]; ok {
+		return v, true
+	}
+	for key, v := range obj {
+		if strings.EqualFold(key, name) {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+func matches(item any, attr, value string) bool {
+	obj, ok := item.(map[string]any)
+	if !ok {
+		return false
+	}
+
+	v, ok := getFold(obj, attr)
+	if !ok {
+		return false
+	}
+
+	s, ok := source.JSONString(v)
+	return ok && strings.EqualFold(s, value)
+}
+
+func primaryFirst(values []any) []any {
+	sorted := make([]any, len(values))
+	copy(sorted, values)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return isPrimary(sorted[i]) && !isPrimary(sorted[j])
+	})
+	return sorted
+}
+
+func isPrimary(item any) bool {
+	obj, ok := item.(map[string]any)
+	if !ok {
+		return false
+	}
+	primary, _ := getFold(obj, "primary")
+	return primary == true
+}
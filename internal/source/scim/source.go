@@ -0,0 +1,253 @@
+package scimsource
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/tuanta7/qworker/internal/domain"
+	"github.com/tuanta7/qworker/internal/mapping"
+	"github.com/tuanta7/qworker/internal/source"
+	"github.com/tuanta7/qworker/pkg/cipherx"
+	"github.com/tuanta7/qworker/pkg/logger"
+	"github.com/tuanta7/qworker/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	contentType      = "application/scim+json"
+	defaultBatchSize = 100
+	defaultTimeout   = 30 * time.Second
+	maxErrorBody     = 4 << 10
+)
+
+// DefaultMapper maps the SCIM core user schema, fields left empty in the connector mapper fall back to it.
+var DefaultMapper = domain.Mapper{
+	ExternalID:  "id",
+	Username:    "userName",
+	FullName:    "name.formatted",
+	Email:       "emails.value",
+	PhoneNumber: "phoneNumbers.value",
+	CreatedAt:   "meta.created",
+	UpdatedAt:   "meta.lastModified",
+	Custom: map[string]string{
+		"active": "active",
+	},
+}
+
+// Register adds the SCIM pull source to the registry.
+func Register(r *source.Registry, client *http.Client, cipher cipherx.Cipher, zl *logger.ZapLogger) {
+	source.Register(r, domain.ConnectorTypeSCIM, source.JSONDecoder[domain.SCIMConnector](),
+		func(connector *domain.Connector, config *domain.SCIMConnector) (source.SyncSource, error) {
+			if config.BaseURL == "" {
+				return nil, errors.New("scim connector has no base url")
+			}
+
+			m, err := mapping.Compile(WithDefaults(connector.Mapper))
+			if err != nil {
+				return nil, err
+			}
+
+			updatedAt, err := m.Attribute(domain.MappingUpdatedAt)
+			if err != nil {
+				return nil, err
+			}
+
+			return &Source{
+				connector: connector,
+				config:    config,
+				mapping:   m,
+				updatedAt: updatedAt,
+				client:    client,
+				cipher:    cipher,
+				logger:    zl,
+			}, nil
+		})
+}
+
+type Source struct {
+	connector     *domain.Connector
+	config        *domain.SCIMConnector
+	mapping       *mapping.Mapping
+	updatedAt     string
+	client        *http.Client
+	cipher        cipherx.Cipher
+	logger        *logger.ZapLogger
+	authorization string
+}
+
+type listResponse struct {
+	TotalResults int              `json:"totalResults"`
+	StartIndex   int              `json:"startIndex"`
+	ItemsPerPage int              `json:"itemsPerPage"`
+	Resources    []map[string]any `json:"Resources"`
+}
+
+type errorResponse struct {
+	Detail   string `json:"detail"`
+	ScimType string `json:"scimType"`
+}
+
+// Connect only prepares the credentials, the first request is made by the page iterator.
+func (s *Source) Connect(_ context.Context) error {
+	switch s.config.AuthType {
+	case domain.SCIMAuthBearer:
+		token, err := s.cipher.Decrypt(s.config.Token)
+		if err != nil {
+			s.logger.Error("SCIMSource - Connect - s.cipher.Decrypt", zap.Error(err))
+			return err
+		}
+		s.authorization = "Bearer " + token
+	case domain.SCIMAuthBasic:
+		password, err := s.cipher.Decrypt(s.config.Password)
+		if err != nil {
+			s.logger.Error("SCIMSource - Connect - s.cipher.Decrypt", zap.Error(err))
+			return err
+		}
+		req := &http.Request{Header: http.Header{}}
+		req.SetBasicAuth(s.config.Username, password)
+		s.authorization = req.Header.Get("Authorization")
+	case "":
+	default:
+		return fmt.Errorf("unsupported scim auth type: %s", s.config.AuthType)
+	}
+
+	return nil
+}
+
+func (s *Source) Iterate(since time.Time) source.PageIterator {
+	var filter string
+	if !since.IsZero() {
+		filter = fmt.Sprintf("%s gt %q", s.updatedAt, since.UTC().Format(time.RFC3339))
+	}
+
+	count := int(s.config.SyncSettings.BatchSize)
+	if count <= 0 {
+		count = defaultBatchSize
+	}
+
+	return &pageIterator{
+		source:     s,
+		filter:     filter,
+		count:      count,
+		startIndex: 1,
+	}
+}
+
+func (s *Source) Map(record *source.Record) (*domain.User, error) {
+	resource, ok := record.Native.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("record %s is not a SCIM resource", record.ID)
+	}
+
+	return toUser(resource, s.mapping)
+}
+
+func (s *Source) Mapping() *mapping.Mapping {
+	return s.mapping
+}
+
+func (s *Source) Values(record *source.Record) func(attr string) []string {
+	resource, _ := record.Native.(map[string]any)
+	return func(path string) []string { return lookup(resource, path) }
+}
+
+func (s *Source) Close() error {
+	return nil
+}
+
+func (s *Source) list(ctx context.Context, startIndex, count int, filter string) (*listResponse, error) {
+	query := url.Values{}
+	query.Set("startIndex", strconv.Itoa(startIndex))
+	query.Set("count", strconv.Itoa(count))
+	if filter != "" {
+		query.Set("filter", filter)
+	}
+	endpoint := strings.TrimSuffix(s.config.BaseURL, "/") + "/Users?" + query.Encode()
+
+	timeout := s.config.Timeout * time.Millisecond
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", contentType)
+	if s.authorization != "" {
+		req.Header.Set("Authorization", s.authorization)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		scimErr := &errorResponse{}
+		if json.Unmarshal(body, scimErr) == nil && scimErr.Detail != "" {
+			return nil, fmt.Errorf("scim: GET /Users: status %d: %s", resp.StatusCode, scimErr.Detail)
+		}
+		return nil, fmt.Errorf("scim: GET /Users: status %d", resp.StatusCode)
+	}
+
+	list := &listResponse{}
+	err = json.NewDecoder(resp.Body).Decode(list)
+	if err != nil {
+		return nil, fmt.Errorf("scim: decode list response: %w", err)
+	}
+
+	return list, nil
+}
+
+type pageIterator struct {
+	source     *Source
+	filter     string
+	count      int
+	startIndex int
+	done       bool
+}
+
+func (it *pageIterator) Next(ctx context.Context) ([]*source.Record, error) {
+	s := it.source
+	_, span := tracing.Tracer().Start(ctx, "scim.list",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String(tracing.AttrConnectorID, strconv.FormatUint(s.connector.ConnectorID, 10)),
+			attribute.Int("scim.start_index", it.startIndex),
+		))
+	list, err := s.list(ctx, it.startIndex, it.count, it.filter)
+	tracing.End(span, err)
+	if err != nil {
+		s.logger.Error("SCIMSource - Next - s.list", zap.Error(err))
+		return nil, err
+	}
+
+	records := make([]*source.Record, len(list.Resources))
+	for i, resource := range list.Resources {
+		records[i] = toRecord(resource)
+	}
+
+	// servers may return fewer resources than requested, the next page starts after the last one received
+	it.startIndex += len(list.Resources)
+	it.done = len(list.Resources) == 0 || it.startIndex > list.TotalResults
+
+	return records, nil
+}
+
+func (it *pageIterator) Done() bool {
+	return it.done
+}
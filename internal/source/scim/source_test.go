@@ -0,0 +1,199 @@
+package scimsource
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/tuanta7/qworker/internal/domain"
+	"github.com/tuanta7/qworker/internal/source"
+	"github.com/tuanta7/qworker/pkg/cipherx"
+	"github.com/tuanta7/qworker/pkg/logger"
+	"github.com/tuanta7/qworker/pkg/sqlxx"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const enterpriseSchema = "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User"
+
+func scimUser(i int) map[string]any {
+	return map[string]any{
+		"schemas":  []string{"urn:ietf:params:scim:schemas:core:2.0:User", enterpriseSchema},
+		"id":       fmt.Sprintf("2819c223-%04d", i),
+		"userName": fmt.Sprintf("user%d", i),
+		"name":     map[string]any{"formatted": fmt.Sprintf("User %d", i)},
+		"active":   i%2 == 0,
+		"emails": []map[string]any{
+			{"value": fmt.Sprintf("user%d@home.example.com", i), "type": "home"},
+			{"value": fmt.Sprintf("user%d@example.com", i), "type": "work", "primary": true},
+		},
+		"phoneNumbers": []map[string]any{{"value": "+84901234567", "type": "mobile"}},
+		"meta": map[string]any{
+			"created":      "2025-01-02T03:04:05Z",
+			"lastModified": "2025-03-01T10:00:00.123Z",
+		},
+		enterpriseSchema: map[string]any{"employeeNumber": strconv.Itoa(1000 + i)},
+	}
+}
+
+// newSCIMServer serves total users from /Users, requiring the given Authorization header.
+func newSCIMServer(t *testing.T, total int, authorization string, filters *[]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/scim/v2/Users" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Header.Get("Authorization") != authorization {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"schemas":["urn:ietf:params:scim:api:messages:2.0:Error"],"detail":"invalid credentials","status":"401"}`))
+			return
+		}
+		*filters = append(*filters, r.URL.Query().Get("filter"))
+
+		startIndex, _ := strconv.Atoi(r.URL.Query().Get("startIndex"))
+		count, _ := strconv.Atoi(r.URL.Query().Get("count"))
+		resources := make([]map[string]any, 0, count)
+		for i := startIndex; i < startIndex+count && i <= total; i++ {
+			resources = append(resources, scimUser(i))
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"schemas":      []string{"urn:ietf:params:scim:api:messages:2.0:ListResponse"},
+			"totalResults": total,
+			"startIndex":   startIndex,
+			"itemsPerPage": len(resources),
+			"Resources":    resources,
+		})
+	}))
+}
+
+func newSource(t *testing.T, config *domain.SCIMConnector, mapper domain.Mapper) source.SyncSource {
+	cipher, err := cipherx.New(cipherx.AEAD, []byte("1234567887654321"))
+	assert.Equal(t, nil, err)
+
+	if config.Token != "" {
+		config.Token, _ = cipher.Encrypt(config.Token)
+	}
+	if config.Password != "" {
+		config.Password, _ = cipher.Encrypt(config.Password)
+	}
+	raw, _ := json.Marshal(config)
+
+	r := source.NewRegistry()
+	Register(r, http.DefaultClient, cipher, logger.MustNewLogger("none"))
+
+	src, err := r.New(&domain.Connector{
+		ConnectorID:   7,
+		ConnectorType: domain.ConnectorTypeSCIM,
+		Data:          sqlxx.TextData{Raw: raw},
+		Mapper:        mapper,
+	})
+	assert.Equal(t, nil, err)
+	return src
+}
+
+func readAll(t *testing.T, src source.SyncSource, since time.Time) []*domain.User {
+	ctx := context.Background()
+	assert.Equal(t, nil, src.Connect(ctx))
+	defer src.Close()
+
+	var users []*domain.User
+	pages := src.Iterate(since)
+	for !pages.Done() {
+		records, err := pages.Next(ctx)
+		assert.Equal(t, nil, err)
+		for _, record := range records {
+			user, err := src.Map(record)
+			assert.Equal(t, nil, err)
+			users = append(users, user)
+		}
+	}
+	return users
+}
+
+func TestSCIMSource(t *testing.T) {
+	t.Run("full_sync_bearer", func(t *testing.T) {
+		var filters []string
+		srv := newSCIMServer(t, 5, "Bearer s3cr3t", &filters)
+		defer srv.Close()
+
+		src := newSource(t, &domain.SCIMConnector{
+			BaseURL:      srv.URL + "/scim/v2",
+			AuthType:     domain.SCIMAuthBearer,
+			Token:        "s3cr3t",
+			SyncSettings: domain.SyncSettings{BatchSize: 2},
+		}, domain.Mapper{})
+
+		users := readAll(t, src, time.Time{})
+		assert.Equal(t, 5, len(users))
+		assert.Equal(t, []string{"", "", ""}, filters)
+
+		assert.Equal(t, "user1", users[0].Username)
+		assert.Equal(t, "User 1", users[0].FullName)
+		assert.Equal(t, "user1@example.com", users[0].Email)
+		assert.Equal(t, "+84901234567", users[0].PhoneNumber)
+		assert.Equal(t, false, users[0].Active)
+		assert.Equal(t, true, users[1].Active)
+		assert.Equal(t, time.Date(2025, 3, 1, 10, 0, 0, 123000000, time.UTC), users[0].UpdatedAt)
+	})
+
+	t.Run("missing_timestamps", func(t *testing.T) {
+		var filters []string
+		srv := newSCIMServer(t, 1, "Bearer s3cr3t", &filters)
+		defer srv.Close()
+
+		src := newSource(t, &domain.SCIMConnector{
+			BaseURL:      srv.URL + "/scim/v2",
+			AuthType:     domain.SCIMAuthBearer,
+			Token:        "s3cr3t",
+			SyncSettings: domain.SyncSettings{BatchSize: 2},
+		}, domain.Mapper{CreatedAt: "meta.missing"})
+
+		users := readAll(t, src, time.Time{})
+		assert.Equal(t, 1, len(users))
+		assert.True(t, users[0].CreatedAt.IsZero())
+	})
+
+	t.Run("incremental_sync_basic_enterprise", func(t *testing.T) {
+		var filters []string
+		srv := newSCIMServer(t, 3, "Basic c3luYzpwYXNzd29yZA==", &filters)
+		defer srv.Close()
+
+		src := newSource(t, &domain.SCIMConnector{
+			BaseURL:      srv.URL + "/scim/v2/",
+			AuthType:     domain.SCIMAuthBasic,
+			Username:     "sync",
+			Password:     "password",
+			SyncSettings: domain.SyncSettings{BatchSize: 10},
+		}, domain.Mapper{
+			Username: enterpriseSchema + ":employeeNumber",
+			Email:    `emails[type eq "home"].value`,
+		})
+
+		users := readAll(t, src, time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC))
+		assert.Equal(t, 3, len(users))
+		assert.Equal(t, []string{`meta.lastModified gt "2025-02-01T00:00:00Z"`}, filters)
+		assert.Equal(t, "1001", users[0].Username)
+		assert.Equal(t, "user1@home.example.com", users[0].Email)
+	})
+
+	t.Run("unauthorized", func(t *testing.T) {
+		var filters []string
+		srv := newSCIMServer(t, 3, "Bearer s3cr3t", &filters)
+		defer srv.Close()
+
+		src := newSource(t, &domain.SCIMConnector{
+			BaseURL:  srv.URL + "/scim/v2",
+			AuthType: domain.SCIMAuthBearer,
+			Token:    "wrong",
+		}, domain.Mapper{})
+
+		assert.Equal(t, nil, src.Connect(context.Background()))
+		_, err := src.Iterate(time.Time{}).Next(context.Background())
+		assert.EqualError(t, err, "scim: GET /Users: status 401: invalid credentials")
+	})
+}
@@ -0,0 +1,74 @@
+package source
+
+import (
+	"context"
+	"github.com/Masterminds/squirrel"
+	"github.com/tuanta7/qworker/internal/domain"
+	"github.com/tuanta7/qworker/internal/mapping"
+	"time"
+)
+
+// Record is a raw entry read from a source, before it is mapped to a user.
+type Record struct {
+	ID         string              // DN for LDAP, resource ID for the other sources
+	Attributes map[string][]string // kept as is for rejected records
+	Native     any                 // the entry as returned by the source client, only read by its own source
+}
+
+// SyncSource reads users from an external identity store. A source is created per sync run and
+// is not safe for concurrent use.
+type SyncSource interface {
+	// Connect opens and authenticates the connection, ctx bounds the whole sync run so that
+	// cancelling it aborts a page being fetched.
+	Connect(ctx context.Context) error
+	// Iterate pages through the records changed since the watermark, a zero watermark means all records.
+	Iterate(since time.Time) PageIterator
+	Map(record *Record) (*domain.User, error)
+	Close() error
+}
+
+// Inspectable is implemented by sources that expose how they map records, mapper previews read it.
+type Inspectable interface {
+	// Mapping returns the mapping compiled for the run, with the source defaults applied.
+	Mapping() *mapping.Mapping
+	// Values returns the values of the mapped attributes of a record.
+	Values(record *Record) func(attr string) []string
+}
+
+type PageIterator interface {
+	// Next fetches the next page, it may return an empty page when the source runs out of records.
+	Next(ctx context.Context) ([]*Record, error)
+	// Done reports whether the last page has been fetched.
+	Done() bool
+}
+
+// Committer is implemented by sources that keep track of what they imported. CommitQueries run in the
+// sync transaction, so the tracking is only kept along with the users, and Committed runs once that
+// transaction has committed. Neither is called on dry runs.
+type Committer interface {
+	CommitQueries() []squirrel.Sqlizer
+	Committed(ctx context.Context) error
+}
+
+// GroupRecord is a group read from a source along with the DNs of its members.
+type GroupRecord struct {
+	Group   *domain.Group
+	Members []string // direct members, users or groups
+	Nested  []string // users inherited through member groups, nil when the source does not resolve nesting
+}
+
+// GroupSource is implemented by sources that also sync groups. The group pass runs after the users and is
+// written in the same transaction, so that memberships are resolved against the users of the run.
+type GroupSource interface {
+	// IterateGroups pages through the groups changed since the watermark, it returns nil when the
+	// connector does not sync groups.
+	IterateGroups(since time.Time) GroupIterator
+	// ExpandNested reports whether nested memberships are expanded from the stored direct memberships
+	// instead of being resolved by the source.
+	ExpandNested() bool
+}
+
+type GroupIterator interface {
+	Next(ctx context.Context) ([]*GroupRecord, error)
+	Done() bool
+}
@@ -0,0 +1,78 @@
+package sqlsource
+
+import (
+	"fmt"
+	"github.com/tuanta7/qworker/internal/domain"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// sincePattern matches the :since parameter but not a Postgres cast such as x::since.
+	sincePattern = regexp.MustCompile(`(^|[^:]):since\b`)
+	identPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// dialect holds the differences between the drivers that matter to the generated query.
+type dialect struct {
+	placeholder func(n int) string
+	quote       func(ident string) string
+}
+
+var dialects = map[domain.SQLDriver]dialect{
+	domain.SQLDriverPostgres: {
+		placeholder: func(n int) string { return "$" + strconv.Itoa(n) },
+		quote:       func(ident string) string { return `"` + ident + `"` },
+	},
+	domain.SQLDriverMySQL: {
+		placeholder: func(int) string { return "?" },
+		quote:       func(ident string) string { return "`" + ident + "`" },
+	},
+}
+
+// buildQuery wraps the configured query to fetch the page following the cursor, a nil cursor means the
+// first page. Every :since in the query is bound to the watermark.
+func buildQuery(d dialect, query, keyColumn string, since time.Time, cursor any, limit int) (string, []any) {
+	var args []any
+	inner := sincePattern.ReplaceAllStringFunc(strings.TrimRight(strings.TrimSpace(query), ";"), func(m string) string {
+		args = append(args, since)
+		return strings.TrimSuffix(m, ":since") + d.placeholder(len(args))
+	})
+
+	key := "src." + d.quote(keyColumn)
+	var sb strings.Builder
+	sb.WriteString("SELECT * FROM (")
+	sb.WriteString(inner)
+	sb.WriteString(") AS src")
+	if cursor != nil {
+		args = append(args, cursor)
+		fmt.Fprintf(&sb, " WHERE %s > %s", key, d.placeholder(len(args)))
+	}
+	fmt.Fprintf(&sb, " ORDER BY %s LIMIT %d", key, limit)
+
+	return sb.String(), args
+}
+
+// toAttribute formats a column value as read by database/sql, ok is false for NULL.
+func toAttribute(v any) (string, bool) {
+	switch val := v.(type) {
+	case nil:
+		return "", false
+	case []byte:
+		return string(val), true
+	case string:
+		return val, true
+	case time.Time:
+		return val.UTC().Format(time.RFC3339Nano), true
+	case bool:
+		return strconv.FormatBool(val), true
+	case int64:
+		return strconv.FormatInt(val, 10), true
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64), true
+	default:
+		return fmt.Sprint(val), true
+	}
+}
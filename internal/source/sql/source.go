@@ -0,0 +1,274 @@
+package sqlsource
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/tuanta7/qworker/internal/domain"
+	"github.com/tuanta7/qworker/internal/mapping"
+	"github.com/tuanta7/qworker/internal/source"
+	"github.com/tuanta7/qworker/pkg/cipherx"
+	"github.com/tuanta7/qworker/pkg/logger"
+	"github.com/tuanta7/qworker/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultBatchSize = 1000
+	defaultTimeout   = 60 * time.Second
+)
+
+// Register adds the SQL source to the registry.
+func Register(r *source.Registry, cipher cipherx.Cipher, zl *logger.ZapLogger) {
+	source.Register(r, domain.ConnectorTypeSQL, source.JSONDecoder[domain.SQLConnector](),
+		func(connector *domain.Connector, config *domain.SQLConnector) (source.SyncSource, error) {
+			d, ok := dialects[config.Driver]
+			if !ok {
+				return nil, fmt.Errorf("unsupported sql driver: %q", config.Driver)
+			}
+			if config.Query == "" {
+				return nil, errors.New("sql connector has no query")
+			}
+			if !identPattern.MatchString(config.KeyColumn) {
+				return nil, fmt.Errorf("invalid sql key column: %q", config.KeyColumn)
+			}
+
+			m, err := mapping.Compile(source.WithDefaultColumns(connector.Mapper))
+			if err != nil {
+				return nil, err
+			}
+
+			return &Source{
+				connector: connector,
+				config:    config,
+				dialect:   d,
+				mapping:   m,
+				cipher:    cipher,
+				logger:    zl,
+			}, nil
+		})
+}
+
+type Source struct {
+	connector *domain.Connector
+	config    *domain.SQLConnector
+	dialect   dialect
+	mapping   *mapping.Mapping
+	cipher    cipherx.Cipher
+	logger    *logger.ZapLogger
+	db        *sql.DB
+}
+
+func (s *Source) Connect(ctx context.Context) error {
+	var password string
+	if s.config.Password != "" {
+		var err error
+		password, err = s.cipher.Decrypt(s.config.Password)
+		if err != nil {
+			s.logger.Error("SQLSource - Connect - s.cipher.Decrypt", zap.Error(err))
+			return err
+		}
+	}
+
+	connector, err := newConnector(s.config.Driver, s.config.DSN, password)
+	if err != nil {
+		s.logger.Error("SQLSource - Connect - newConnector", zap.Error(err))
+		return err
+	}
+
+	// pages are read one after another, a single connection is enough
+	s.db = sql.OpenDB(connector)
+	s.db.SetMaxOpenConns(1)
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+
+	err = s.db.PingContext(ctx)
+	if err != nil {
+		s.logger.Error("SQLSource - Connect - s.db.PingContext", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+// Iterate binds the watermark to :since, a zero watermark is bound to the Unix epoch so that a full sync
+// can run the same query.
+func (s *Source) Iterate(since time.Time) source.PageIterator {
+	if since.IsZero() {
+		since = time.Unix(0, 0)
+	}
+
+	limit := int(s.config.SyncSettings.BatchSize)
+	if limit <= 0 {
+		limit = defaultBatchSize
+	}
+
+	return &pageIterator{source: s, since: since.UTC(), limit: limit}
+}
+
+func (s *Source) Map(record *source.Record) (*domain.User, error) {
+	return source.MapColumns(record, s.mapping)
+}
+
+func (s *Source) Mapping() *mapping.Mapping {
+	return s.mapping
+}
+
+func (s *Source) Values(record *source.Record) func(attr string) []string {
+	return func(attr string) []string { return source.Values(record, attr) }
+}
+
+func (s *Source) Close() error {
+	if s.db == nil {
+		return nil
+	}
+	return s.db.Close()
+}
+
+func (s *Source) timeout() time.Duration {
+	if s.config.Timeout <= 0 {
+		return defaultTimeout
+	}
+	return s.config.Timeout * time.Millisecond
+}
+
+// newConnector injects the decrypted password into the DSN, which is stored without it. An empty password
+// keeps the one of the DSN, if any, e.g. for trust or peer authentication.
+func newConnector(d domain.SQLDriver, dsn, password string) (driver.Connector, error) {
+	switch d {
+	case domain.SQLDriverPostgres:
+		config, err := pgx.ParseConfig(dsn)
+		if err != nil {
+			return nil, err
+		}
+		if password != "" {
+			config.Password = password
+		}
+		return stdlib.GetConnector(*config), nil
+	case domain.SQLDriverMySQL:
+		config, err := mysql.ParseDSN(dsn)
+		if err != nil {
+			return nil, err
+		}
+		if password != "" {
+			config.Passwd = password
+		}
+		config.ParseTime = true
+		return mysql.NewConnector(config)
+	default:
+		return nil, fmt.Errorf("unsupported sql driver: %q", d)
+	}
+}
+
+type pageIterator struct {
+	source *Source
+	since  time.Time
+	limit  int
+	cursor any
+	done   bool
+}
+
+func (it *pageIterator) Next(ctx context.Context) ([]*source.Record, error) {
+	s := it.source
+	query, args := buildQuery(s.dialect, s.config.Query, s.config.KeyColumn, it.since, it.cursor, it.limit)
+
+	ctx, span := tracing.Tracer().Start(ctx, "sql.query",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String(tracing.AttrConnectorID, strconv.FormatUint(s.connector.ConnectorID, 10)),
+			attribute.String("db.system", string(s.config.Driver)),
+		))
+	records, cursor, err := it.query(ctx, query, args)
+	tracing.End(span, err)
+	if err != nil {
+		s.logger.Error("SQLSource - Next - it.query", zap.Error(err))
+		return nil, err
+	}
+
+	if len(records) > 0 {
+		it.cursor = cursor
+	}
+	it.done = len(records) < it.limit
+
+	return records, nil
+}
+
+func (it *pageIterator) Done() bool {
+	return it.done
+}
+
+// query returns the records of a page and the key of its last row.
+func (it *pageIterator) query(ctx context.Context, query string, args []any) ([]*source.Record, any, error) {
+	s := it.source
+	ctx, cancel := context.WithTimeout(ctx, s.timeout())
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keyIndex := -1
+	for i, column := range columns {
+		if column == s.config.KeyColumn {
+			keyIndex = i
+		}
+	}
+	if keyIndex < 0 {
+		return nil, nil, fmt.Errorf("key column %q is not in the query result", s.config.KeyColumn)
+	}
+
+	values := make([]any, len(columns))
+	pointers := make([]any, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+
+	var cursor any
+	records := make([]*source.Record, 0, it.limit)
+	for rows.Next() {
+		err = rows.Scan(pointers...)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		attributes := make(map[string][]string, len(columns))
+		for i, column := range columns {
+			if value, ok := toAttribute(values[i]); ok {
+				attributes[column] = []string{value}
+			}
+		}
+
+		cursor = values[keyIndex]
+		if cursor == nil {
+			return nil, nil, fmt.Errorf("key column %q is NULL", s.config.KeyColumn)
+		}
+		if b, ok := cursor.([]byte); ok {
+			cursor = string(b)
+		}
+
+		key, _ := toAttribute(cursor)
+		records = append(records, &source.Record{
+			ID:         s.config.KeyColumn + "=" + key,
+			Attributes: attributes,
+		})
+	}
+
+	return records, cursor, rows.Err()
+}
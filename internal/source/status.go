@@ -0,0 +1,27 @@
+package source
+
+import (
+	"github.com/tuanta7/qworker/internal/domain"
+	"github.com/tuanta7/qworker/internal/mapping"
+)
+
+// ApplyStatus sets the status flags that the mapper status rules derive, values returns the values of a
+// source attribute. The flags without rules are left as mapped.
+func ApplyStatus(user *domain.User, m *mapping.Mapping, values func(attr string) []string) error {
+	active, ok, err := m.Status(domain.StatusActive, values)
+	if err != nil {
+		return err
+	}
+	if ok {
+		user.Active = active
+	}
+
+	verified, ok, err := m.Status(domain.StatusEmailVerified, values)
+	if err != nil {
+		return err
+	}
+	if ok {
+		user.EmailVerified = &verified
+	}
+	return nil
+}
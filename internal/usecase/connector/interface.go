@@ -8,4 +8,5 @@ import (
 type Repository interface {
 	ListByEnabled(ctx context.Context, enabled bool) ([]*domain.Connector, error)
 	GetByID(ctx context.Context, id uint64) (*domain.Connector, error)
+	SaveMapper(ctx context.Context, connectorID uint64, mapper *domain.Mapper) error
 }
@@ -2,8 +2,12 @@ package connectoruc
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"github.com/tuanta7/qworker/internal/domain"
+	"github.com/tuanta7/qworker/internal/mapping"
 	"github.com/tuanta7/qworker/pkg/logger"
+	"github.com/tuanta7/qworker/pkg/utils"
 	"go.uber.org/zap"
 )
 
@@ -21,6 +25,13 @@ func NewUseCase(connectorRepo Repository, zl *logger.ZapLogger) *UseCase {
 
 func (u *UseCase) ListEnabled(ctx context.Context) ([]*domain.Connector, error) {
 	connectors, err := u.connectorRepo.ListByEnabled(ctx, true)
+	if errors.Is(err, utils.ErrInvalidMapper) {
+		// one misconfigured connector must not stop the others from syncing
+		u.logger.Error(
+			"Connector - UseCase - ListEnabledConnectors - skipped connectors",
+			zap.Error(err))
+		return connectors, nil
+	}
 	if err != nil {
 		u.logger.Error(
 			"Connector - UseCase - ListEnabledConnectors - u.connectorRepo.ListByEnabled",
@@ -43,3 +54,35 @@ func (u *UseCase) GetByID(ctx context.Context, connectorID uint64) (*domain.Conn
 
 	return c, nil
 }
+
+// SaveMapper validates the mapper with its preset applied, then stores it without the preset fields so that
+// later preset changes still apply.
+func (u *UseCase) SaveMapper(ctx context.Context, connectorID uint64, mapper *domain.Mapper) error {
+	resolved, err := mapper.WithPreset()
+	if err == nil {
+		_, err = mapping.Compile(resolved)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", utils.ErrInvalidMapper, err)
+	}
+
+	_, err = u.connectorRepo.GetByID(ctx, connectorID)
+	if err != nil {
+		u.logger.Error(
+			"Connector - UseCase - SaveMapper - u.connectorRepo.GetByID",
+			zap.Uint64("connector_id", connectorID),
+			zap.Error(err))
+		return err
+	}
+
+	err = u.connectorRepo.SaveMapper(ctx, connectorID, mapper)
+	if err != nil {
+		u.logger.Error(
+			"Connector - UseCase - SaveMapper - u.connectorRepo.SaveMapper",
+			zap.Uint64("connector_id", connectorID),
+			zap.Error(err))
+		return err
+	}
+
+	return nil
+}
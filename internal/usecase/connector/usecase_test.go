@@ -0,0 +1,43 @@
+package connectoruc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/tuanta7/qworker/internal/domain"
+	"github.com/tuanta7/qworker/pkg/logger"
+	"github.com/tuanta7/qworker/pkg/utils"
+	"testing"
+)
+
+// fakeRepository answers ListByEnabled with its fields.
+type fakeRepository struct {
+	Repository
+	connectors []*domain.Connector
+	err        error
+}
+
+func (f *fakeRepository) ListByEnabled(context.Context, bool) ([]*domain.Connector, error) {
+	return f.connectors, f.err
+}
+
+func TestListEnabled(t *testing.T) {
+	connectors := []*domain.Connector{{ConnectorID: 1}}
+
+	t.Run("skipped", func(t *testing.T) {
+		err := fmt.Errorf("connector 2: %w: unknown mapper preset", utils.ErrInvalidMapper)
+		u := NewUseCase(&fakeRepository{connectors: connectors, err: errors.Join(err)}, logger.MustNewLogger("none"))
+
+		got, err := u.ListEnabled(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, connectors, got)
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		u := NewUseCase(&fakeRepository{err: context.DeadlineExceeded}, logger.MustNewLogger("none"))
+
+		_, err := u.ListEnabled(context.Background())
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
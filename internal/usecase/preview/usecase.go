@@ -0,0 +1,156 @@
+package previewuc
+
+import (
+	"context"
+	"fmt"
+	"github.com/tuanta7/qworker/internal/domain"
+	pgrepo "github.com/tuanta7/qworker/internal/repository/postgres"
+	"github.com/tuanta7/qworker/internal/source"
+	"github.com/tuanta7/qworker/pkg/logger"
+	"github.com/tuanta7/qworker/pkg/sqlxx"
+	"github.com/tuanta7/qworker/pkg/utils"
+	"go.uber.org/zap"
+	"time"
+)
+
+const (
+	DefaultLimit = 5
+	MaxLimit     = 100
+)
+
+// UseCase maps a few live entries of a source without writing anything, so that a mapper can be checked
+// before a real sync.
+type UseCase struct {
+	sources             *source.Registry
+	connectorRepository *pgrepo.ConnectorRepository
+	logger              *logger.ZapLogger
+}
+
+func NewUseCase(sources *source.Registry, connectorRepository *pgrepo.ConnectorRepository, zl *logger.ZapLogger) *UseCase {
+	return &UseCase{
+		sources:             sources,
+		connectorRepository: connectorRepository,
+		logger:              zl,
+	}
+}
+
+func (u *UseCase) Preview(ctx context.Context, req *domain.PreviewRequest) (*domain.PreviewResult, error) {
+	connector, err := u.connector(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	src, err := u.sources.New(connector)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", utils.ErrInvalidConnector, err)
+	}
+
+	inspectable, ok := src.(source.Inspectable)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s connectors cannot be previewed", utils.ErrUnsupportedConnectorType, connector.ConnectorType)
+	}
+
+	err = src.Connect(ctx)
+	if err != nil {
+		u.logger.Error("Preview - UseCase - Preview - src.Connect", zap.Error(err))
+		return nil, err
+	}
+	defer func() {
+		if err := src.Close(); err != nil {
+			u.logger.Warn("Preview - UseCase - Preview - src.Close", zap.Error(err))
+		}
+	}()
+
+	limit := req.Limit
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	limit = min(limit, MaxLimit)
+
+	result := &domain.PreviewResult{
+		ConnectorID:   connector.ConnectorID,
+		ConnectorType: connector.ConnectorType,
+		Entries:       make([]*domain.PreviewEntry, 0, limit),
+	}
+
+	pages := src.Iterate(time.Time{})
+	for !pages.Done() && len(result.Entries) < limit {
+		records, err := pages.Next(ctx)
+		if err != nil {
+			u.logger.Error("Preview - UseCase - Preview - pages.Next", zap.Error(err))
+			return nil, err
+		}
+
+		for _, record := range records[:min(len(records), limit-len(result.Entries))] {
+			result.Entries = append(result.Entries, previewEntry(src, inspectable, record))
+		}
+	}
+
+	return result, nil
+}
+
+// connector loads the stored connector, or builds one from the request config.
+func (u *UseCase) connector(ctx context.Context, req *domain.PreviewRequest) (*domain.Connector, error) {
+	connector := &domain.Connector{
+		ConnectorType: req.ConnectorType,
+		Data:          sqlxx.TextData{Raw: req.Data},
+	}
+	if req.ConnectorID != 0 {
+		var err error
+		connector, err = u.connectorRepository.GetByID(ctx, req.ConnectorID)
+		if err != nil {
+			u.logger.Error("Preview - UseCase - connector - u.connectorRepository.GetByID",
+				zap.Uint64("connector_id", req.ConnectorID),
+				zap.Error(err))
+			return nil, err
+		}
+	} else if req.ConnectorType == "" || len(req.Data) == 0 {
+		return nil, fmt.Errorf("%w: a connector id, or a connector type and data, is required", utils.ErrInvalidConnector)
+	}
+
+	if req.Mapper != nil {
+		mapper, err := req.Mapper.WithPreset()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", utils.ErrInvalidMapper, err)
+		}
+		connector.Mapper = mapper
+	}
+
+	return connector, nil
+}
+
+func previewEntry(src source.SyncSource, inspectable source.Inspectable, record *source.Record) *domain.PreviewEntry {
+	entry := &domain.PreviewEntry{
+		ID:         record.ID,
+		Attributes: record.Attributes,
+	}
+
+	m, values := inspectable.Mapping(), inspectable.Values(record)
+	for _, attr := range m.Refs() {
+		if len(values(attr)) == 0 {
+			entry.Missing = append(entry.Missing, attr)
+		}
+	}
+
+	// LDAP entries keep a zero time rather than failing on an unparseable timestamp
+	for _, name := range []string{domain.MappingCreatedAt, domain.MappingUpdatedAt} {
+		value := m.Value(name, values)
+		if value == "" {
+			continue
+		}
+		if _, err := source.ParseTime(value); err != nil {
+			entry.Warnings = append(entry.Warnings, fmt.Sprintf("%s: unparseable timestamp %q", name, value))
+		}
+	}
+
+	user, err := src.Map(record)
+	if err == nil {
+		entry.User = user
+		err = user.Validate()
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	return entry
+}
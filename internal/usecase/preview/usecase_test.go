@@ -0,0 +1,136 @@
+package previewuc
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/tuanta7/qworker/internal/domain"
+	"github.com/tuanta7/qworker/internal/mapping"
+	"github.com/tuanta7/qworker/internal/source"
+	"github.com/tuanta7/qworker/pkg/logger"
+	"github.com/tuanta7/qworker/pkg/utils"
+	"testing"
+	"time"
+)
+
+const connectorTypeFake domain.ConnectorType = "fake"
+
+type fakeConfig struct {
+	Records []map[string][]string `json:"records"`
+}
+
+type fakeSource struct {
+	config  *fakeConfig
+	mapping *mapping.Mapping
+}
+
+func (s *fakeSource) Connect(context.Context) error { return nil }
+func (s *fakeSource) Close() error                  { return nil }
+
+func (s *fakeSource) Iterate(time.Time) source.PageIterator {
+	return &fakeIterator{records: s.config.Records}
+}
+
+func (s *fakeSource) Map(record *source.Record) (*domain.User, error) {
+	return source.MapColumns(record, s.mapping)
+}
+
+func (s *fakeSource) Mapping() *mapping.Mapping {
+	return s.mapping
+}
+
+func (s *fakeSource) Values(record *source.Record) func(attr string) []string {
+	return func(attr string) []string { return source.Values(record, attr) }
+}
+
+// fakeIterator returns pages of two records.
+type fakeIterator struct {
+	records []map[string][]string
+	read    int
+}
+
+func (it *fakeIterator) Next(context.Context) ([]*source.Record, error) {
+	var page []*source.Record
+	for ; it.read < len(it.records) && len(page) < 2; it.read++ {
+		page = append(page, &source.Record{ID: it.records[it.read]["id"][0], Attributes: it.records[it.read]})
+	}
+	return page, nil
+}
+
+func (it *fakeIterator) Done() bool {
+	return it.read == len(it.records)
+}
+
+func newUseCase() *UseCase {
+	r := source.NewRegistry()
+	source.Register(r, connectorTypeFake, source.JSONDecoder[fakeConfig](),
+		func(connector *domain.Connector, config *fakeConfig) (source.SyncSource, error) {
+			m, err := mapping.Compile(source.WithDefaultColumns(connector.Mapper))
+			if err != nil {
+				return nil, err
+			}
+			return &fakeSource{config: config, mapping: m}, nil
+		})
+	return NewUseCase(r, nil, logger.MustNewLogger("none"))
+}
+
+func TestPreview(t *testing.T) {
+	data := []byte(`{"records": [
+		{"id": ["1"], "login": ["JDoe"], "mail": ["jdoe@example.com"], "changed": ["2025-03-01T10:00:00Z"]},
+		{"id": ["2"], "login": ["RRoe"], "changed": ["yesterday"]},
+		{"id": ["3"], "mail": ["nobody@example.com"]}
+	]}`)
+	mapper := &domain.Mapper{
+		Username:  "=lower(login)",
+		Email:     "mail",
+		UpdatedAt: "changed",
+	}
+
+	t.Run("draft_mapper", func(t *testing.T) {
+		result, err := newUseCase().Preview(context.Background(), &domain.PreviewRequest{
+			ConnectorType: connectorTypeFake,
+			Data:          data,
+			Mapper:        mapper,
+		})
+		assert.Equal(t, nil, err)
+		assert.Equal(t, 3, len(result.Entries))
+
+		first := result.Entries[0]
+		assert.Equal(t, "jdoe", first.User.Username)
+		assert.Equal(t, []string{"JDoe"}, first.Attributes["login"])
+		assert.Equal(t, []string{"active", "created_at", "full_name", "phone_number"}, first.Missing)
+		assert.Equal(t, "", first.Error)
+
+		second := result.Entries[1]
+		assert.Equal(t, []string{`updated_at: unparseable timestamp "yesterday"`}, second.Warnings)
+		assert.Equal(t, `updated_at: parsing time "yesterday" as "2006-01-02": cannot parse "yesterday" as "2006"`, second.Error)
+
+		third := result.Entries[2]
+		assert.Equal(t, utils.ErrUsernameRequired.Error(), third.Error)
+	})
+
+	t.Run("limit", func(t *testing.T) {
+		result, err := newUseCase().Preview(context.Background(), &domain.PreviewRequest{
+			ConnectorType: connectorTypeFake,
+			Data:          data,
+			Mapper:        mapper,
+			Limit:         1,
+		})
+		assert.Equal(t, nil, err)
+		assert.Equal(t, 1, len(result.Entries))
+	})
+
+	t.Run("invalid_mapper", func(t *testing.T) {
+		_, err := newUseCase().Preview(context.Background(), &domain.PreviewRequest{
+			ConnectorType: connectorTypeFake,
+			Data:          data,
+			Mapper:        &domain.Mapper{Username: "=lower(login"},
+		})
+		assert.ErrorIs(t, err, utils.ErrInvalidConnector)
+		assert.ErrorContains(t, err, `mapping "username": column 13: expected ")", found end of expression`)
+	})
+
+	t.Run("no_connector", func(t *testing.T) {
+		_, err := newUseCase().Preview(context.Background(), &domain.PreviewRequest{Mapper: mapper})
+		assert.ErrorIs(t, err, utils.ErrInvalidConnector)
+	})
+}
@@ -1,15 +1,23 @@
 package scheduleruc
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/google/uuid"
 	"github.com/hibiken/asynq"
 	"github.com/robfig/cron/v3"
 	"github.com/tuanta7/qworker/config"
 	"github.com/tuanta7/qworker/internal/domain"
+	"github.com/tuanta7/qworker/internal/metrics"
 	"github.com/tuanta7/qworker/pkg/logger"
+	"github.com/tuanta7/qworker/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
+	"maps"
+	"slices"
 	"strconv"
 	"sync"
 	"time"
@@ -23,6 +31,7 @@ type JobInfo struct {
 type UseCase struct {
 	lock           sync.Mutex
 	jobs           map[string]JobInfo // expect to have only one scheduler instance
+	running        bool
 	cronScheduler  *cron.Cron
 	asynqClient    *asynq.Client
 	asynqInspector *asynq.Inspector
@@ -57,6 +66,13 @@ func (u *UseCase) GetJobPeriod(connectorID string) (time.Duration, bool) {
 	return jobInfo.Period, true
 }
 
+// JobIDs returns the connector ids that have a job.
+func (u *UseCase) JobIDs() []string {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	return slices.Collect(maps.Keys(u.jobs))
+}
+
 func (u *UseCase) CleanJob(connectorID string) error {
 	u.lock.Lock()
 	jobInfo, exists := u.jobs[connectorID]
@@ -64,12 +80,15 @@ func (u *UseCase) CleanJob(connectorID string) error {
 
 	if exists {
 		u.cronScheduler.Remove(jobInfo.EntryID)
+		u.lock.Lock()
 		delete(u.jobs, connectorID)
+		metrics.SchedulerJobs.Set(float64(len(u.jobs)))
+		u.lock.Unlock()
 		u.logger.Info("Job removed", zap.Any("id", jobInfo.EntryID))
 	}
 
 	var errs []error
-	for q := range config.QueuePriority {
+	for q := range config.QueueTask {
 		err := u.asynqInspector.DeleteTask(q, connectorID)
 		if err != nil {
 			if errors.Is(err, asynq.ErrQueueNotFound) || errors.Is(err, asynq.ErrTaskNotFound) {
@@ -100,6 +119,7 @@ func (u *UseCase) CreateJob(period time.Duration, queue string, message *domain.
 		EntryID: jobID,
 		Period:  period,
 	}
+	metrics.SchedulerJobs.Set(float64(len(u.jobs)))
 	defer u.lock.Unlock()
 
 	u.logger.Info("Create cron job", zap.Any("message", message))
@@ -108,15 +128,44 @@ func (u *UseCase) CreateJob(period time.Duration, queue string, message *domain.
 
 func (u *UseCase) enqueueTaskCMD(message *domain.QueueMessage, queue string) func() {
 	return func() {
+		metrics.SchedulerTicks.WithLabelValues(queue).Inc()
+
 		taskID := strconv.FormatUint(message.ConnectorID, 10)
-		payload, err := json.Marshal(message)
+		ctx, span := tracing.Tracer().Start(context.Background(), "scheduler.enqueue",
+			trace.WithSpanKind(trace.SpanKindProducer),
+			trace.WithAttributes(
+				attribute.String(tracing.AttrConnectorID, taskID),
+				attribute.String(tracing.AttrTaskID, taskID),
+				attribute.String(tracing.AttrTaskType, message.TaskType),
+				attribute.String(tracing.AttrQueue, queue),
+			))
+		var err error
+		defer func() { tracing.End(span, err) }()
+
+		// the message is shared by every tick of the job, each tick is its own run
+		tickMessage := *message
+		tickMessage.CorrelationID = uuid.NewString()
+		tickMessage.ScheduledAt = time.Now()
+		tickMessage.TraceContext = tracing.Inject(ctx)
+		span.SetAttributes(attribute.String(tracing.AttrCorrelationID, tickMessage.CorrelationID))
+		payload, err := json.Marshal(&tickMessage)
 		if err != nil {
+			metrics.SchedulerRefusals.WithLabelValues(queue, metrics.RefusalMarshalError).Inc()
 			u.logger.Error("SchedulerUsecase -  enqueueTaskCMD - json.Marshal", zap.Error(err))
 			return
 		}
 
 		ok, err := u.IsTaskAllowed(taskID)
+		if err == nil && !ok {
+			span.SetAttributes(attribute.Bool("qworker.task.refused", true))
+		}
 		if err != nil || !ok {
+			reason := metrics.RefusalFullSyncPending
+			if err != nil {
+				reason = metrics.RefusalInspectorError
+			}
+			metrics.SchedulerRefusals.WithLabelValues(queue, reason).Inc()
+
 			u.logger.Error("SchedulerUsecase -  enqueueTaskCMD - u.IsTaskAllowed",
 				zap.String("message", "this task is not allowed to be enqueued right now"),
 				zap.String("type", message.TaskType),
@@ -138,11 +187,16 @@ func (u *UseCase) enqueueTaskCMD(message *domain.QueueMessage, queue string) fun
 			asynq.Retention(0),
 		)
 		if err != nil {
+			metrics.SchedulerRefusals.WithLabelValues(queue, metrics.RefusalEnqueueError).Inc()
 			u.logger.Error("SchedulerUsecase -  enqueueTaskCMD - u.asynqClient.Enqueue", zap.Error(err))
 			return
 		}
+		metrics.SchedulerEnqueues.WithLabelValues(queue).Inc()
 
-		u.logger.Info("enqueue new task", zap.Any("task", task))
+		u.logger.Info("enqueue new task",
+			zap.Any("task", task),
+			zap.String("correlation_id", tickMessage.CorrelationID),
+			zap.String("trace_id", span.SpanContext().TraceID().String()))
 	}
 }
 
@@ -164,8 +218,20 @@ func (u *UseCase) IsTaskAllowed(id string) (bool, error) {
 	return true, nil
 }
 
-func (u *UseCase) ClearAllJobs() {
-	u.cronScheduler.Stop()
+// ClearAllJobs stops the cron scheduler and waits for in-flight enqueues until ctx is done.
+func (u *UseCase) ClearAllJobs(ctx context.Context) error {
+	stopped := u.cronScheduler.Stop()
+
+	u.lock.Lock()
+	u.running = false
+	u.lock.Unlock()
+
+	var err error
+	select {
+	case <-stopped.Done():
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
 
 	for _, e := range u.cronScheduler.Entries() {
 		u.cronScheduler.Remove(e.ID)
@@ -173,9 +239,24 @@ func (u *UseCase) ClearAllJobs() {
 
 	u.lock.Lock()
 	clear(u.jobs)
+	metrics.SchedulerJobs.Set(0)
 	u.lock.Unlock()
+
+	return err
 }
 
 func (u *UseCase) StartScheduler() {
 	u.cronScheduler.Start()
+
+	u.lock.Lock()
+	u.running = true
+	u.lock.Unlock()
+}
+
+// IsLeader reports whether this instance is scheduling jobs. Only one scheduler instance is
+// expected, so it leads as soon as its cron scheduler has been started.
+func (u *UseCase) IsLeader() bool {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	return u.running
 }
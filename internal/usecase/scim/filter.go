@@ -0,0 +1,149 @@
+package scimuc
+
+import (
+	"fmt"
+	"github.com/tuanta7/qworker/internal/domain"
+	"github.com/tuanta7/qworker/pkg/utils"
+	"strconv"
+	"strings"
+)
+
+// parseFilter supports the equality filters identity providers send before provisioning a user, such as
+// `userName eq "bjensen"`, joined with "and". Attributes are resolved through the connector mapper, so a
+// filter matches the column the attribute is stored in.
+func parseFilter(filter string, mapper domain.Mapper) (map[string]any, error) {
+	tokens, err := tokenize(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := filterColumns(mapper)
+	result := make(map[string]any)
+	for len(tokens) > 0 {
+		if len(tokens) < 3 || !strings.EqualFold(tokens[1].text, "eq") || tokens[0].quoted {
+			return nil, fmt.Errorf("%w: expected <attribute> eq <value>", utils.ErrInvalidFilter)
+		}
+
+		column, ok := columns[strings.ToLower(tokens[0].text)]
+		if !ok {
+			return nil, fmt.Errorf("%w: unsupported attribute %q", utils.ErrInvalidFilter, tokens[0].text)
+		}
+
+		value, err := tokens[2].value()
+		if err != nil {
+			return nil, err
+		}
+		if column == domain.ColActive {
+			if _, ok := value.(bool); !ok {
+				return nil, fmt.Errorf("%w: %s expects a boolean", utils.ErrInvalidFilter, tokens[0].text)
+			}
+		}
+		result[column] = value
+
+		tokens = tokens[3:]
+		if len(tokens) == 0 {
+			break
+		}
+		if !strings.EqualFold(tokens[0].text, "and") || tokens[0].quoted {
+			return nil, fmt.Errorf("%w: only \"and\" is supported between expressions", utils.ErrInvalidFilter)
+		}
+		tokens = tokens[1:]
+		if len(tokens) == 0 {
+			return nil, fmt.Errorf("%w: dangling \"and\"", utils.ErrInvalidFilter)
+		}
+	}
+
+	return result, nil
+}
+
+// filterColumns maps the lower-cased attribute paths of the mapper to their columns. A multi-valued
+// attribute may be filtered on without its "value" sub-attribute, e.g. `emails eq "..."`.
+func filterColumns(mapper domain.Mapper) map[string]string {
+	columns := map[string]string{
+		"id": domain.ColUserID,
+	}
+
+	paths := []struct {
+		path   string
+		column string
+	}{
+		{mapper.Username, domain.ColUsername},
+		{mapper.FullName, domain.ColFullName},
+		{mapper.Email, domain.ColEmail},
+		{mapper.PhoneNumber, domain.ColPhoneNumber},
+		{mapper.Custom["active"], domain.ColActive},
+	}
+	for _, p := range paths {
+		if p.path == "" {
+			continue
+		}
+		path := strings.ToLower(p.path)
+		columns[path] = p.column
+		if trimmed, ok := strings.CutSuffix(path, ".value"); ok {
+			columns[trimmed] = p.column
+		}
+	}
+
+	return columns
+}
+
+type token struct {
+	text   string
+	quoted bool
+}
+
+func (t token) value() (any, error) {
+	if t.quoted {
+		return t.text, nil
+	}
+
+	switch strings.ToLower(t.text) {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+
+	if _, err := strconv.ParseFloat(t.text, 64); err == nil {
+		return t.text, nil
+	}
+
+	return nil, fmt.Errorf("%w: invalid value %q", utils.ErrInvalidFilter, t.text)
+}
+
+func tokenize(filter string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(filter); {
+		switch c := filter[i]; {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '"':
+			var sb strings.Builder
+			i++
+			for ; i < len(filter) && filter[i] != '"'; i++ {
+				if filter[i] == '\\' && i+1 < len(filter) {
+					i++
+				}
+				sb.WriteByte(filter[i])
+			}
+			if i >= len(filter) {
+				return nil, fmt.Errorf("%w: unterminated string", utils.ErrInvalidFilter)
+			}
+			i++
+			tokens = append(tokens, token{text: sb.String(), quoted: true})
+		case c == '(' || c == ')' || c == '[' || c == ']':
+			return nil, fmt.Errorf("%w: grouping is not supported", utils.ErrInvalidFilter)
+		default:
+			start := i
+			for i < len(filter) && !strings.ContainsRune(" \t\"()[]", rune(filter[i])) {
+				i++
+			}
+			tokens = append(tokens, token{text: filter[start:i]})
+		}
+	}
+
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("%w: empty filter", utils.ErrInvalidFilter)
+	}
+	return tokens, nil
+}
@@ -0,0 +1,53 @@
+package scimuc
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/tuanta7/qworker/internal/domain"
+	"github.com/tuanta7/qworker/internal/source/scim"
+	"github.com/tuanta7/qworker/pkg/utils"
+	"testing"
+)
+
+func TestParseFilter(t *testing.T) {
+	mapper := scimsource.WithDefaults(domain.Mapper{})
+
+	tests := []struct {
+		filter   string
+		expected map[string]any
+	}{
+		{`userName eq "bjensen"`, map[string]any{domain.ColUsername: "bjensen"}},
+		{`USERNAME Eq "b \"j\" jensen"`, map[string]any{domain.ColUsername: `b "j" jensen`}},
+		{`emails eq "bjensen@example.com" and active eq true`, map[string]any{
+			domain.ColEmail:  "bjensen@example.com",
+			domain.ColActive: true,
+		}},
+		{`id eq "2819c223-7f76-453a-919d-413861904646"`, map[string]any{
+			domain.ColUserID: "2819c223-7f76-453a-919d-413861904646",
+		}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.filter, func(t *testing.T) {
+			columns, err := parseFilter(tc.filter, mapper)
+			assert.Equal(t, nil, err)
+			assert.Equal(t, tc.expected, columns)
+		})
+	}
+
+	invalid := []string{
+		``,
+		`userName sw "b"`,
+		`externalId eq "1"`,
+		`userName eq "a" or userName eq "b"`,
+		`emails[type eq "work"] eq "x"`,
+		`active eq "true"`,
+		`userName eq "unterminated`,
+		`userName eq "a" and`,
+	}
+	for _, filter := range invalid {
+		t.Run("invalid_"+filter, func(t *testing.T) {
+			_, err := parseFilter(filter, mapper)
+			assert.ErrorIs(t, err, utils.ErrInvalidFilter)
+		})
+	}
+}
@@ -0,0 +1,20 @@
+package scimuc
+
+import (
+	"context"
+	"github.com/Masterminds/squirrel"
+	"github.com/tuanta7/qworker/internal/domain"
+	"time"
+)
+
+type UserRepository interface {
+	GetByID(ctx context.Context, sourceID uint64, id string) (*domain.User, error)
+	GetByUsername(ctx context.Context, sourceID uint64, username string) (*domain.User, error)
+	GetLinkedUsernames(ctx context.Context, sourceID uint64, externalIDs []string) (map[string]string, error)
+	HeldByHigherPriority(ctx context.Context, sourceID uint64, username string) (bool, error)
+	List(ctx context.Context, sourceID uint64, filter map[string]any, offset, limit uint64) ([]*domain.User, uint64, error)
+	BuildBulkSyncQueries(sourceID uint64, users []*domain.User, result *domain.SyncResult) []squirrel.Sqlizer
+	BuildReplaceQueries(user *domain.User) []squirrel.Sqlizer
+	BuildDeprovisionQuery(sourceID uint64, id string, at time.Time) squirrel.Sqlizer
+	ExecuteTransaction(ctx context.Context, queries []squirrel.Sqlizer) error
+}
@@ -0,0 +1,233 @@
+package scimuc
+
+import (
+	"fmt"
+	"github.com/tuanta7/qworker/pkg/utils"
+	"regexp"
+	"strings"
+)
+
+// PatchOperation is an operation of a PatchOp request, see RFC 7644 section 3.5.2.
+type PatchOperation struct {
+	Op    string `json:"op"`
+	Path  string `json:"path,omitempty"`
+	Value any    `json:"value,omitempty"`
+}
+
+// pathPattern matches attr, attr.sub, attr[filter] and attr[filter].sub.
+var pathPattern = regexp.MustCompile(`^(\w+)(?:\[\s*(\w+)\s+eq\s+"([^"]*)"\s*\])?(?:\.(\w+))?$`)
+
+type patchPath struct {
+	extension   string
+	attr        string
+	filterAttr  string
+	filterValue string
+	sub         string
+}
+
+func applyPatch(resource map[string]any, operations []PatchOperation) error {
+	for _, operation := range operations {
+		op := strings.ToLower(operation.Op)
+		switch op {
+		case "add", "replace", "remove":
+		default:
+			return fmt.Errorf("%w: unsupported op %q", utils.ErrInvalidPatch, operation.Op)
+		}
+
+		if operation.Path == "" {
+			if op == "remove" {
+				return fmt.Errorf("%w: remove requires a path", utils.ErrInvalidPatch)
+			}
+
+			// Without a path the value holds the attributes to add or replace, keyed by their path.
+			values, ok := operation.Value.(map[string]any)
+			if !ok {
+				return fmt.Errorf("%w: value must be an object when path is omitted", utils.ErrInvalidPatch)
+			}
+			for path, value := range values {
+				err := applyOperation(resource, op, path, value)
+				if err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		err := applyOperation(resource, op, operation.Path, operation.Value)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func applyOperation(resource map[string]any, op, rawPath string, value any) error {
+	path, err := parsePath(rawPath)
+	if err != nil {
+		return err
+	}
+
+	container := resource
+	if path.extension != "" {
+		extension, ok := getFold(resource, path.extension)
+		if path.attr == "" {
+			return setAttribute(resource, op, path.extension, extension, value)
+		}
+
+		container, ok = extension.(map[string]any)
+		if !ok {
+			if op == "remove" {
+				return nil
+			}
+			container = make(map[string]any)
+			resource[path.extension] = container
+		}
+	}
+
+	key, current := findFold(container, path.attr)
+	if key == "" {
+		key = path.attr
+	}
+
+	if path.filterAttr == "" && path.sub == "" {
+		return setAttribute(container, op, key, current, value)
+	}
+
+	if path.filterAttr == "" {
+		// attr.sub of a complex attribute such as name.givenName
+		obj, ok := current.(map[string]any)
+		if !ok {
+			if op == "remove" {
+				return nil
+			}
+			obj = make(map[string]any)
+			container[key] = obj
+		}
+		subKey, subCurrent := findFold(obj, path.sub)
+		if subKey == "" {
+			subKey = path.sub
+		}
+		return setAttribute(obj, op, subKey, subCurrent, value)
+	}
+
+	items, _ := current.([]any)
+	kept := make([]any, 0, len(items))
+	matched := false
+	for _, item := range items {
+		obj, ok := item.(map[string]any)
+		if !ok || !matchesFold(obj, path.filterAttr, path.filterValue) {
+			kept = append(kept, item)
+			continue
+		}
+
+		matched = true
+		switch {
+		case op == "remove" && path.sub == "":
+			continue
+		case path.sub == "":
+			if replacement, ok := value.(map[string]any); ok {
+				item = replacement
+			}
+		default:
+			subKey, subCurrent := findFold(obj, path.sub)
+			if subKey == "" {
+				subKey = path.sub
+			}
+			err = setAttribute(obj, op, subKey, subCurrent, value)
+			if err != nil {
+				return err
+			}
+		}
+		kept = append(kept, item)
+	}
+
+	// Identity providers replace emails[type eq "work"].value of users that have no work email yet.
+	if !matched && op != "remove" {
+		item := map[string]any{path.filterAttr: path.filterValue}
+		if path.sub != "" {
+			item[path.sub] = value
+		} else if obj, ok := value.(map[string]any); ok {
+			for k, v := range obj {
+				item[k] = v
+			}
+		}
+		kept = append(kept, item)
+	}
+
+	container[key] = kept
+	return nil
+}
+
+func setAttribute(container map[string]any, op, key string, current, value any) error {
+	switch op {
+	case "remove":
+		delete(container, key)
+	case "add":
+		values, isList := value.([]any)
+		if existing, ok := current.([]any); ok && isList {
+			container[key] = append(existing, values...)
+			return nil
+		}
+		if existing, ok := current.(map[string]any); ok {
+			if obj, ok := value.(map[string]any); ok {
+				for k, v := range obj {
+					existing[k] = v
+				}
+				return nil
+			}
+		}
+		container[key] = value
+	case "replace":
+		container[key] = value
+	}
+	return nil
+}
+
+// parsePath splits a path such as "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:manager.value"
+// into the extension URN and the attribute path inside it.
+func parsePath(path string) (*patchPath, error) {
+	p := &patchPath{}
+	if strings.HasPrefix(strings.ToLower(path), "urn:") {
+		i := strings.LastIndex(path, ":")
+		if strings.EqualFold(path[i+1:], "User") {
+			p.extension = path
+			return p, nil
+		}
+		p.extension, path = path[:i], path[i+1:]
+	}
+
+	m := pathPattern.FindStringSubmatch(path)
+	if m == nil {
+		return nil, fmt.Errorf("%w: unsupported path %q", utils.ErrInvalidPatch, path)
+	}
+	p.attr, p.filterAttr, p.filterValue, p.sub = m[1], m[2], m[3], m[4]
+
+	return p, nil
+}
+
+func findFold(obj map[string]any, name string) (string, any) {
+	if v, ok := obj[name]; ok {
+		return name, v
+	}
+	for key, v := range obj {
+		if strings.EqualFold(key, name) {
+			return key, v
+		}
+	}
+	return "", nil
+}
+
+func getFold(obj map[string]any, name string) (any, bool) {
+	key, v := findFold(obj, name)
+	return v, key != ""
+}
+
+func matchesFold(obj map[string]any, attr, value string) bool {
+	v, ok := getFold(obj, attr)
+	if !ok {
+		return false
+	}
+	s, ok := v.(string)
+	return ok && strings.EqualFold(s, value)
+}
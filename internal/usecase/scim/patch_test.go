@@ -0,0 +1,76 @@
+package scimuc
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/tuanta7/qworker/pkg/utils"
+	"testing"
+)
+
+const enterpriseSchema = "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User"
+
+func resource(t *testing.T) map[string]any {
+	var r map[string]any
+	err := json.Unmarshal([]byte(`{
+		"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"],
+		"userName": "bjensen",
+		"active": true,
+		"name": {"formatted": "Barbara Jensen", "givenName": "Barbara"},
+		"emails": [{"value": "bjensen@example.com", "type": "work", "primary": true}]
+	}`), &r)
+	assert.Equal(t, nil, err)
+	return r
+}
+
+func TestApplyPatch(t *testing.T) {
+	t.Run("replace_without_path", func(t *testing.T) {
+		r := resource(t)
+		err := applyPatch(r, []PatchOperation{{Op: "replace", Value: map[string]any{"active": false}}})
+		assert.Equal(t, nil, err)
+		assert.Equal(t, false, r["active"])
+	})
+
+	t.Run("replace_sub_attribute", func(t *testing.T) {
+		r := resource(t)
+		err := applyPatch(r, []PatchOperation{{Op: "Replace", Path: "name.givenName", Value: "Babs"}})
+		assert.Equal(t, nil, err)
+		assert.Equal(t, "Babs", r["name"].(map[string]any)["givenName"])
+		assert.Equal(t, "Barbara Jensen", r["name"].(map[string]any)["formatted"])
+	})
+
+	t.Run("value_filter", func(t *testing.T) {
+		r := resource(t)
+		err := applyPatch(r, []PatchOperation{
+			{Op: "replace", Path: `emails[type eq "work"].value`, Value: "barbara@example.com"},
+			{Op: "add", Path: `emails[type eq "home"].value`, Value: "babs@home.example.com"},
+		})
+		assert.Equal(t, nil, err)
+
+		emails := r["emails"].([]any)
+		assert.Equal(t, 2, len(emails))
+		assert.Equal(t, "barbara@example.com", emails[0].(map[string]any)["value"])
+		assert.Equal(t, map[string]any{"type": "home", "value": "babs@home.example.com"}, emails[1])
+
+		err = applyPatch(r, []PatchOperation{{Op: "remove", Path: `emails[type eq "home"]`}})
+		assert.Equal(t, nil, err)
+		assert.Equal(t, 1, len(r["emails"].([]any)))
+	})
+
+	t.Run("extension_attribute", func(t *testing.T) {
+		r := resource(t)
+		err := applyPatch(r, []PatchOperation{{Op: "add", Path: enterpriseSchema + ":department", Value: "Tour Operations"}})
+		assert.Equal(t, nil, err)
+		assert.Equal(t, map[string]any{"department": "Tour Operations"}, r[enterpriseSchema])
+
+		err = applyPatch(r, []PatchOperation{{Op: "remove", Path: enterpriseSchema + ":department"}})
+		assert.Equal(t, nil, err)
+		assert.Equal(t, map[string]any{}, r[enterpriseSchema])
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		r := resource(t)
+		assert.ErrorIs(t, applyPatch(r, []PatchOperation{{Op: "move", Path: "userName"}}), utils.ErrInvalidPatch)
+		assert.ErrorIs(t, applyPatch(r, []PatchOperation{{Op: "remove"}}), utils.ErrInvalidPatch)
+		assert.ErrorIs(t, applyPatch(r, []PatchOperation{{Op: "add", Path: "emails[value co \"x\"]"}}), utils.ErrInvalidPatch)
+	})
+}
@@ -0,0 +1,75 @@
+package scimuc
+
+import (
+	"encoding/json"
+	"github.com/tuanta7/qworker/internal/domain"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	SchemaUser         = "urn:ietf:params:scim:schemas:core:2.0:User"
+	SchemaListResponse = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	SchemaPatchOp      = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	SchemaError        = "urn:ietf:params:scim:api:messages:2.0:Error"
+)
+
+// serverAttributes are owned by the server and never stored from a request. The password is dropped
+// because users authenticate against the identity provider, not against private.user.
+var serverAttributes = []string{"id", "meta", "password"}
+
+// ToResource renders a user as a SCIM resource. The stored resource keeps the attributes the columns
+// cannot hold, such as the enterprise extension, while id, active and meta always come from the row.
+func ToResource(user *domain.User) map[string]any {
+	resource := make(map[string]any)
+	if len(user.Data.Raw) > 0 {
+		_ = json.Unmarshal(user.Data.Raw, &resource)
+	}
+
+	if len(resource) == 0 {
+		resource = map[string]any{
+			"schemas":  []any{SchemaUser},
+			"userName": user.Username,
+		}
+		if user.FullName != "" {
+			resource["name"] = map[string]any{"formatted": user.FullName}
+			resource["displayName"] = user.FullName
+		}
+		if user.Email != "" {
+			resource["emails"] = []any{map[string]any{"value": user.Email, "primary": true}}
+		}
+		if user.PhoneNumber != "" {
+			resource["phoneNumbers"] = []any{map[string]any{"value": user.PhoneNumber, "primary": true}}
+		}
+	}
+
+	resource["id"] = user.UserID
+	resource["active"] = user.Active
+	resource["meta"] = map[string]any{
+		"resourceType": "User",
+		"created":      user.CreatedAt.UTC().Format(time.RFC3339),
+		"lastModified": user.UpdatedAt.UTC().Format(time.RFC3339),
+		"version":      `W/"` + strconv.FormatInt(user.UpdatedAt.UnixNano(), 10) + `"`,
+	}
+
+	return resource
+}
+
+// sanitize removes the server owned attributes and normalizes active, which some identity providers
+// send as the string "True" or "False".
+func sanitize(resource map[string]any) {
+	for key := range resource {
+		for _, attr := range serverAttributes {
+			if strings.EqualFold(key, attr) {
+				delete(resource, key)
+			}
+		}
+	}
+
+	if key, active := findFold(resource, "active"); key != "" {
+		if s, ok := active.(string); ok {
+			resource[key] = strings.EqualFold(s, "true")
+		}
+	}
+}
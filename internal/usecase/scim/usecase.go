@@ -0,0 +1,294 @@
+package scimuc
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/tuanta7/qworker/internal/domain"
+	pgrepo "github.com/tuanta7/qworker/internal/repository/postgres"
+	"github.com/tuanta7/qworker/internal/source/scim"
+	"github.com/tuanta7/qworker/pkg/cipherx"
+	"github.com/tuanta7/qworker/pkg/logger"
+	"github.com/tuanta7/qworker/pkg/sqlxx"
+	"github.com/tuanta7/qworker/pkg/utils"
+	"go.uber.org/zap"
+	"time"
+)
+
+// UseCase provisions the users pushed by identity providers into private.user. Writes go through the
+// same upsert and transaction as the worker, so a pushed user and a pulled user end up identical.
+type UseCase struct {
+	connectorRepository *pgrepo.ConnectorRepository
+	userRepository      UserRepository
+	cipher              cipherx.Cipher
+	logger              *logger.ZapLogger
+}
+
+func NewUseCase(
+	connectorRepository *pgrepo.ConnectorRepository,
+	userRepository UserRepository,
+	cipher cipherx.Cipher,
+	zl *logger.ZapLogger,
+) *UseCase {
+	return &UseCase{
+		connectorRepository: connectorRepository,
+		userRepository:      userRepository,
+		cipher:              cipher,
+		logger:              zl,
+	}
+}
+
+// Authenticate returns the connector the bearer token belongs to. Every failure is reported as
+// utils.ErrInvalidToken so that callers cannot probe which connectors exist.
+func (u *UseCase) Authenticate(ctx context.Context, connectorID uint64, token string) (*domain.Connector, error) {
+	c, err := u.connectorRepository.GetByID(ctx, connectorID)
+	if err != nil {
+		if errors.Is(err, utils.ErrConnectorNotFound) {
+			return nil, utils.ErrInvalidToken
+		}
+		u.logger.Error("SCIM - UseCase - Authenticate - u.connectorRepository.GetByID", zap.Error(err))
+		return nil, err
+	}
+
+	if c.ConnectorType != domain.ConnectorTypeSCIM || !c.Enabled {
+		return nil, utils.ErrInvalidToken
+	}
+
+	config := &domain.SCIMConnector{}
+	err = json.Unmarshal(c.Data.Raw, config)
+	if err != nil || config.InboundToken == "" {
+		return nil, utils.ErrInvalidToken
+	}
+
+	expected, err := u.cipher.Decrypt(config.InboundToken)
+	if err != nil {
+		u.logger.Error("SCIM - UseCase - Authenticate - u.cipher.Decrypt",
+			zap.Uint64("connector_id", connectorID),
+			zap.Error(err))
+		return nil, utils.ErrInvalidToken
+	}
+
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(token)) != 1 {
+		return nil, utils.ErrInvalidToken
+	}
+
+	return c, nil
+}
+
+func (u *UseCase) Get(ctx context.Context, c *domain.Connector, id string) (*domain.User, error) {
+	if uuid.Validate(id) != nil {
+		return nil, utils.ErrUserNotFound
+	}
+
+	return u.userRepository.GetByID(ctx, c.ConnectorID, id)
+}
+
+// List returns the users matching the filter, startIndex is 1-based as in RFC 7644.
+func (u *UseCase) List(
+	ctx context.Context,
+	c *domain.Connector,
+	filter string,
+	startIndex, count uint64,
+) ([]*domain.User, uint64, error) {
+	var columns map[string]any
+	if filter != "" {
+		var err error
+		columns, err = parseFilter(filter, scimsource.WithDefaults(c.Mapper))
+		if err != nil {
+			return nil, 0, err
+		}
+
+		if id, ok := columns[domain.ColUserID].(string); ok && uuid.Validate(id) != nil {
+			return []*domain.User{}, 0, nil
+		}
+	}
+
+	users, total, err := u.userRepository.List(ctx, c.ConnectorID, columns, startIndex-1, count)
+	if err != nil {
+		u.logger.Error("SCIM - UseCase - List - u.userRepository.List", zap.Error(err))
+		return nil, 0, err
+	}
+
+	return users, total, nil
+}
+
+// Create upserts the user by username like a sync does. A user the connector already holds under the
+// username or linked to the externalId is a conflict, as RFC 7644 requires, and so is a user held by a
+// connector of higher priority, which the connector could not address afterwards. A user of a lower
+// priority connector is taken over. The user is read back from the row the upsert wrote, which may be a
+// linked user of another username.
+func (u *UseCase) Create(ctx context.Context, c *domain.Connector, resource map[string]any) (*domain.User, error) {
+	if key, _ := findFold(resource, "active"); key == "" {
+		resource["active"] = true
+	}
+
+	user, err := u.toUser(c, resource)
+	if err != nil {
+		return nil, err
+	}
+
+	err = u.checkExisting(ctx, c, user)
+	if err != nil {
+		return nil, err
+	}
+
+	user.CreatedAt = user.UpdatedAt
+	queries := u.userRepository.BuildBulkSyncQueries(c.ConnectorID, []*domain.User{user}, &domain.SyncResult{})
+	err = u.execute(ctx, "Create", queries...)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, query := range queries {
+		if counted, ok := query.(*pgrepo.CountedQuery); ok && len(counted.IDs) > 0 {
+			created, err := u.userRepository.GetByID(ctx, c.ConnectorID, counted.IDs[0])
+			if errors.Is(err, utils.ErrUserNotFound) {
+				// taken by a connector of higher priority since checkExisting
+				return nil, utils.ErrUserConflict
+			}
+			return created, err
+		}
+	}
+	// the row was not written, its content for the connector did not change
+	return nil, utils.ErrUserConflict
+}
+
+// checkExisting returns utils.ErrUserConflict when the connector already holds the user, or cannot take it
+// over from the connector holding it.
+func (u *UseCase) checkExisting(ctx context.Context, c *domain.Connector, user *domain.User) error {
+	_, err := u.userRepository.GetByUsername(ctx, c.ConnectorID, user.Username)
+	if err == nil {
+		return utils.ErrUserConflict
+	}
+	if !errors.Is(err, utils.ErrUserNotFound) {
+		u.logger.Error("SCIM - UseCase - Create - u.userRepository.GetByUsername", zap.Error(err))
+		return err
+	}
+
+	held, err := u.userRepository.HeldByHigherPriority(ctx, c.ConnectorID, user.Username)
+	if err != nil {
+		u.logger.Error("SCIM - UseCase - Create - u.userRepository.HeldByHigherPriority", zap.Error(err))
+		return err
+	}
+	if held {
+		return utils.ErrUserConflict
+	}
+
+	if user.ExternalID == "" {
+		return nil
+	}
+	linked, err := u.userRepository.GetLinkedUsernames(ctx, c.ConnectorID, []string{user.ExternalID})
+	if err != nil {
+		u.logger.Error("SCIM - UseCase - Create - u.userRepository.GetLinkedUsernames", zap.Error(err))
+		return err
+	}
+	if len(linked) > 0 {
+		return utils.ErrUserConflict
+	}
+	return nil
+}
+
+func (u *UseCase) Replace(ctx context.Context, c *domain.Connector, id string, resource map[string]any) (*domain.User, error) {
+	existing, err := u.Get(ctx, c, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return u.replace(ctx, c, existing, resource)
+}
+
+func (u *UseCase) Patch(ctx context.Context, c *domain.Connector, id string, operations []PatchOperation) (*domain.User, error) {
+	existing, err := u.Get(ctx, c, id)
+	if err != nil {
+		return nil, err
+	}
+
+	resource := ToResource(existing)
+	err = applyPatch(resource, operations)
+	if err != nil {
+		return nil, err
+	}
+
+	return u.replace(ctx, c, existing, resource)
+}
+
+// Deprovision deactivates the user instead of deleting it, as a sync does for accounts disabled at the source.
+func (u *UseCase) Deprovision(ctx context.Context, c *domain.Connector, id string) error {
+	_, err := u.Get(ctx, c, id)
+	if err != nil {
+		return err
+	}
+
+	return u.execute(ctx, "Deprovision", u.userRepository.BuildDeprovisionQuery(c.ConnectorID, id, time.Now()))
+}
+
+func (u *UseCase) replace(
+	ctx context.Context,
+	c *domain.Connector,
+	existing *domain.User,
+	resource map[string]any,
+) (*domain.User, error) {
+	user, err := u.toUser(c, resource)
+	if err != nil {
+		return nil, err
+	}
+
+	user.UserID = existing.UserID
+	user.CreatedAt = existing.CreatedAt
+	if user.ExternalID != "" {
+		linked, err := u.userRepository.GetLinkedUsernames(ctx, c.ConnectorID, []string{user.ExternalID})
+		if err != nil {
+			u.logger.Error("SCIM - UseCase - Replace - u.userRepository.GetLinkedUsernames", zap.Error(err))
+			return nil, err
+		}
+		// the externalId belongs to another user of the connector
+		if username, ok := linked[user.ExternalID]; ok && username != existing.Username {
+			return nil, utils.ErrUserConflict
+		}
+	}
+
+	err = u.execute(ctx, "Replace", u.userRepository.BuildReplaceQueries(user)...)
+	if err != nil {
+		return nil, err
+	}
+
+	return u.userRepository.GetByID(ctx, c.ConnectorID, user.UserID)
+}
+
+// toUser maps the resource with the connector mapper and validates it like a synced record.
+func (u *UseCase) toUser(c *domain.Connector, resource map[string]any) (*domain.User, error) {
+	sanitize(resource)
+
+	user, err := scimsource.MapResource(resource, c.Mapper)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", utils.ErrInvalidResource, err)
+	}
+	// the id of a pushed user is ours, the client identifies it by its externalId
+	_, externalID := findFold(resource, "externalId")
+	user.ExternalID, _ = externalID.(string)
+
+	err = user.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", utils.ErrInvalidResource, err)
+	}
+
+	sourceID := c.ConnectorID
+	user.SourceID = &sourceID
+	user.Data = sqlxx.TextData{Parsed: resource}
+	user.UpdatedAt = time.Now()
+	user.Hash = user.ContentHash()
+
+	return user, nil
+}
+
+func (u *UseCase) execute(ctx context.Context, operation string, queries ...squirrel.Sqlizer) error {
+	err := u.userRepository.ExecuteTransaction(ctx, queries)
+	if err != nil && !errors.Is(err, utils.ErrUserConflict) {
+		u.logger.Error("SCIM - UseCase - "+operation+" - u.userRepository.ExecuteTransaction", zap.Error(err))
+	}
+	return err
+}
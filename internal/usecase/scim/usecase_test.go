@@ -0,0 +1,67 @@
+package scimuc
+
+import (
+	"context"
+	"github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/tuanta7/qworker/internal/domain"
+	pgrepo "github.com/tuanta7/qworker/internal/repository/postgres"
+	"github.com/tuanta7/qworker/pkg/logger"
+	"github.com/tuanta7/qworker/pkg/utils"
+	"testing"
+)
+
+type queryBuilderOnly struct{}
+
+func (queryBuilderOnly) Pool() *pgxpool.Pool { return nil }
+func (queryBuilderOnly) QueryBuilder() squirrel.StatementBuilderType {
+	return squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+}
+func (queryBuilderOnly) Close() {}
+
+// fakeUserRepository builds the real queries and answers the reads from its fields.
+type fakeUserRepository struct {
+	*pgrepo.UserRepository
+	held     bool
+	executed bool
+}
+
+func (f *fakeUserRepository) GetByUsername(context.Context, uint64, string) (*domain.User, error) {
+	return nil, utils.ErrUserNotFound
+}
+
+func (f *fakeUserRepository) GetLinkedUsernames(context.Context, uint64, []string) (map[string]string, error) {
+	return map[string]string{}, nil
+}
+
+func (f *fakeUserRepository) HeldByHigherPriority(context.Context, uint64, string) (bool, error) {
+	return f.held, nil
+}
+
+func (f *fakeUserRepository) ExecuteTransaction(context.Context, []squirrel.Sqlizer) error {
+	f.executed = true
+	return nil
+}
+
+func TestCreate(t *testing.T) {
+	c := &domain.Connector{ConnectorID: 4, ConnectorType: domain.ConnectorTypeSCIM}
+
+	t.Run("held_by_higher_priority", func(t *testing.T) {
+		repo := &fakeUserRepository{UserRepository: pgrepo.NewUserRepository(queryBuilderOnly{}), held: true}
+		u := NewUseCase(nil, repo, nil, logger.MustNewLogger("none"))
+
+		_, err := u.Create(context.Background(), c, resource(t))
+		assert.ErrorIs(t, err, utils.ErrUserConflict)
+		assert.False(t, repo.executed)
+	})
+
+	t.Run("not_written", func(t *testing.T) {
+		repo := &fakeUserRepository{UserRepository: pgrepo.NewUserRepository(queryBuilderOnly{})}
+		u := NewUseCase(nil, repo, nil, logger.MustNewLogger("none"))
+
+		_, err := u.Create(context.Background(), c, resource(t))
+		assert.ErrorIs(t, err, utils.ErrUserConflict)
+		assert.True(t, repo.executed)
+	})
+}
@@ -0,0 +1,266 @@
+package webhookuc
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/hibiken/asynq"
+	"github.com/tuanta7/qworker/config"
+	"github.com/tuanta7/qworker/internal/domain"
+	pgrepo "github.com/tuanta7/qworker/internal/repository/postgres"
+	"github.com/tuanta7/qworker/pkg/cipherx"
+	"github.com/tuanta7/qworker/pkg/logger"
+	"go.uber.org/zap"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Headers of a delivery. The signature is the hex encoded HMAC-SHA256 of the timestamp, a dot and the body,
+// keyed by the subscription secret.
+const (
+	HeaderEvent     = "X-QWorker-Event"
+	HeaderDelivery  = "X-QWorker-Delivery"
+	HeaderTimestamp = "X-QWorker-Timestamp"
+	HeaderSignature = "X-QWorker-Signature"
+)
+
+// UseCase delivers the user events recorded in private.user_event to the webhook subscriptions. Events are
+// dispatched to the webhook queue after the sync transaction commits, one task per subscription, so that
+// a slow or failing endpoint never holds up a sync.
+type UseCase struct {
+	webhookRepository *pgrepo.WebhookRepository
+	asynqClient       *asynq.Client
+	httpClient        *http.Client
+	cipher            cipherx.Cipher
+	config            *config.WebhookConfig
+	logger            *logger.ZapLogger
+}
+
+func NewUseCase(
+	webhookRepository *pgrepo.WebhookRepository,
+	asynqClient *asynq.Client,
+	httpClient *http.Client,
+	cipher cipherx.Cipher,
+	cfg *config.WebhookConfig,
+	zl *logger.ZapLogger,
+) *UseCase {
+	return &UseCase{
+		webhookRepository: webhookRepository,
+		asynqClient:       asynqClient,
+		httpClient:        httpClient,
+		cipher:            cipher,
+		config:            cfg,
+		logger:            zl,
+	}
+}
+
+// Run dispatches the pending events every DispatchInterval until the context is done.
+func (u *UseCase) Run(ctx context.Context) {
+	ticker := time.NewTicker(u.config.DispatchInterval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			n, err := u.Dispatch(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					u.logger.Error("Webhook - UseCase - Run - u.Dispatch", zap.Error(err))
+				}
+				break
+			}
+			if uint64(n) < u.config.BatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Dispatch enqueues a delivery task per pending event and subscription accepting it, and returns the
+// number of events dispatched. Events without subscribers are dispatched without any task.
+func (u *UseCase) Dispatch(ctx context.Context) (int, error) {
+	subscriptions, err := u.webhookRepository.ListEnabledSubscriptions(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return u.webhookRepository.DispatchEvents(ctx, u.config.BatchSize, func(events []*domain.UserEvent) error {
+		for _, event := range events {
+			for _, subscription := range subscriptions {
+				if !subscription.Accepts(event.Type) {
+					continue
+				}
+
+				err := u.enqueue(ctx, event, subscription)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func (u *UseCase) enqueue(ctx context.Context, event *domain.UserEvent, subscription *domain.WebhookSubscription) error {
+	payload, err := json.Marshal(&domain.WebhookTask{EventID: event.EventID, SubscriptionID: subscription.SubscriptionID})
+	if err != nil {
+		return err
+	}
+
+	_, err = u.asynqClient.EnqueueContext(ctx,
+		asynq.NewTask(config.TaskTypeWebhookDelivery, payload),
+		asynq.TaskID(deliveryID(event.EventID, subscription.SubscriptionID)),
+		asynq.Queue(config.QueueWebhook),
+		asynq.MaxRetry(u.config.MaxRetry),
+	)
+	// already queued by a dispatch that failed to commit
+	if errors.Is(err, asynq.ErrTaskIDConflict) {
+		return nil
+	}
+	return err
+}
+
+// Deliver posts an event to a subscription and logs the attempt. A subscription disabled since the event
+// was dispatched receives nothing.
+func (u *UseCase) Deliver(ctx context.Context, task *domain.WebhookTask, attempt int) error {
+	subscription, err := u.webhookRepository.GetSubscription(ctx, task.SubscriptionID)
+	if err != nil {
+		return err
+	}
+	if !subscription.Enabled {
+		return nil
+	}
+
+	event, err := u.webhookRepository.GetEvent(ctx, task.EventID)
+	if err != nil {
+		return err
+	}
+
+	secret, err := u.cipher.Decrypt(subscription.Secret)
+	if err != nil {
+		u.logger.Error("Webhook - UseCase - Deliver - u.cipher.Decrypt", zap.Error(err))
+		return err
+	}
+
+	start := time.Now()
+	statusCode, err := u.post(ctx, subscription.URL, secret, event)
+	delivery := &domain.WebhookDelivery{
+		SubscriptionID: subscription.SubscriptionID,
+		EventID:        event.EventID,
+		Attempt:        attempt,
+		StatusCode:     statusCode,
+		Duration:       time.Since(start),
+		CreatedAt:      start,
+	}
+	if err != nil {
+		delivery.Error = err.Error()
+	}
+
+	if logErr := u.webhookRepository.InsertDelivery(ctx, delivery); logErr != nil {
+		u.logger.Error("Webhook - UseCase - Deliver - u.webhookRepository.InsertDelivery", zap.Error(logErr))
+	}
+	return err
+}
+
+// post sends the event and returns the response status, any status but 2xx is an error.
+func (u *UseCase) post(ctx context.Context, url, secret string, event *domain.UserEvent) (int, error) {
+	body, err := eventBody(event)
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, u.config.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "qworker-webhook")
+	req.Header.Set(HeaderEvent, string(event.Type))
+	req.Header.Set(HeaderDelivery, strconv.FormatUint(event.EventID, 10))
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, "sha256="+Sign(secret, timestamp, body))
+
+	resp, err := u.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("webhook responded %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// Sign returns the signature of a delivery body sent at timestamp, in Unix seconds.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// RetryDelay doubles the base delay on each retry up to maxDelay, with a jitter of up to half of it so that
+// the retries of a failing endpoint spread out.
+func RetryDelay(retried int, base, maxDelay time.Duration) time.Duration {
+	delay := min(base, maxDelay)
+	for i := 0; i < retried && delay < maxDelay; i++ {
+		delay = min(delay*2, maxDelay)
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// eventBody is the JSON sent to subscribers. The user data column is stored as text, it is sent as the
+// JSON it holds.
+func eventBody(event *domain.UserEvent) ([]byte, error) {
+	var data struct {
+		User    map[string]any `json:"user"`
+		Changes map[string]any `json:"changes"`
+	}
+	err := json.Unmarshal(event.Payload, &data)
+	if err != nil {
+		return nil, err
+	}
+
+	if text, ok := data.User[domain.ColData].(string); ok && json.Valid([]byte(text)) {
+		data.User[domain.ColData] = json.RawMessage(text)
+	}
+	if change, ok := data.Changes[domain.ColData].(map[string]any); ok {
+		for _, key := range []string{"old", "new"} {
+			if text, ok := change[key].(string); ok && json.Valid([]byte(text)) {
+				change[key] = json.RawMessage(text)
+			}
+		}
+	}
+
+	return json.Marshal(map[string]any{
+		"id":        event.EventID,
+		"type":      event.Type,
+		"createdAt": event.CreatedAt,
+		"data":      data,
+	})
+}
+
+func deliveryID(eventID, subscriptionID uint64) string {
+	return fmt.Sprintf("webhook:%d:%d", eventID, subscriptionID)
+}
@@ -0,0 +1,91 @@
+package webhookuc
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tuanta7/qworker/config"
+	"github.com/tuanta7/qworker/internal/domain"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPost(t *testing.T) {
+	var received *http.Request
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	u := &UseCase{httpClient: server.Client(), config: &config.WebhookConfig{Timeout: time.Second}}
+	event := &domain.UserEvent{
+		EventID:   42,
+		Type:      domain.UserUpdated,
+		Payload:   json.RawMessage(`{"user":{"username":"jdoe","data":"{\"title\":\"CTO\"}"},"changes":{"data":{"old":null,"new":"{\"title\":\"CTO\"}"}}}`),
+		CreatedAt: time.Date(2025, 5, 27, 10, 0, 0, 0, time.UTC),
+	}
+
+	status, err := u.post(context.Background(), server.URL, "s3cret", event)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, status)
+
+	assert.Equal(t, "user.updated", received.Header.Get(HeaderEvent))
+	assert.Equal(t, "42", received.Header.Get(HeaderDelivery))
+	signature := Sign("s3cret", received.Header.Get(HeaderTimestamp), body)
+	assert.Equal(t, "sha256="+signature, received.Header.Get(HeaderSignature))
+	assert.JSONEq(t, `{
+		"id": 42,
+		"type": "user.updated",
+		"createdAt": "2025-05-27T10:00:00Z",
+		"data": {
+			"user": {"username": "jdoe", "data": {"title": "CTO"}},
+			"changes": {"data": {"old": null, "new": {"title": "CTO"}}}
+		}
+	}`, string(body))
+}
+
+func TestPostRejected(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	u := &UseCase{httpClient: server.Client(), config: &config.WebhookConfig{Timeout: time.Second}}
+	event := &domain.UserEvent{EventID: 1, Type: domain.UserCreated, Payload: json.RawMessage(`{"user":{}}`)}
+
+	status, err := u.post(context.Background(), server.URL, "s3cret", event)
+	assert.Equal(t, http.StatusBadGateway, status)
+	assert.ErrorContains(t, err, "502")
+}
+
+func TestSign(t *testing.T) {
+	signature := Sign("s3cret", "1748340000", []byte(`{"id":1}`))
+	assert.Len(t, signature, 64)
+	assert.Equal(t, signature, Sign("s3cret", "1748340000", []byte(`{"id":1}`)))
+	assert.NotEqual(t, signature, Sign("s3cret", "1748340001", []byte(`{"id":1}`)))
+	assert.NotEqual(t, signature, Sign("other", "1748340000", []byte(`{"id":1}`)))
+	assert.Equal(t, strings.ToLower(signature), signature)
+}
+
+func TestRetryDelay(t *testing.T) {
+	base, limit := 10*time.Second, time.Hour
+	for retried, want := range []time.Duration{10 * time.Second, 20 * time.Second, 40 * time.Second} {
+		delay := RetryDelay(retried, base, limit)
+		assert.GreaterOrEqual(t, delay, want/2)
+		assert.LessOrEqual(t, delay, want)
+	}
+
+	for _, retried := range []int{12, 40, 100} {
+		delay := RetryDelay(retried, base, limit)
+		assert.GreaterOrEqual(t, delay, limit/2)
+		assert.LessOrEqual(t, delay, limit)
+	}
+}
@@ -0,0 +1,150 @@
+package workeruc
+
+import (
+	"context"
+	"github.com/Masterminds/squirrel"
+	"github.com/tuanta7/qworker/internal/domain"
+	"github.com/tuanta7/qworker/internal/source"
+	"github.com/tuanta7/qworker/pkg/sqlxx"
+	"github.com/tuanta7/qworker/pkg/utils"
+	"go.uber.org/zap"
+	"maps"
+	"slices"
+	"time"
+)
+
+// syncGroups runs the group pass of sources that sync groups, page is the number of pages fetched so far in
+// the run. The memberships of the groups read are replaced, a full sync also deletes the groups it did not
+// read. Nested memberships are then expanded if the source leaves it to the repository, and every
+// membership of the source is linked to the users by DN once the users of the run are written.
+func (u *UseCase) syncGroups(
+	ctx context.Context,
+	src source.SyncSource,
+	connector *domain.Connector,
+	limit domain.RateLimit,
+	since time.Time,
+	page *int,
+	result *domain.SyncResult,
+) ([]squirrel.Sqlizer, error) {
+	groupSource, ok := src.(source.GroupSource)
+	if !ok {
+		return nil, nil
+	}
+
+	pages := groupSource.IterateGroups(since)
+	if pages == nil {
+		return nil, nil
+	}
+
+	sourceID := connector.ConnectorID
+	seen := make(map[string]struct{})
+	var queries []squirrel.Sqlizer
+	for !pages.Done() {
+		*page++
+		release, err := u.throttle(ctx, sourceID, limit, *page)
+		if err != nil {
+			return nil, err
+		}
+
+		records, err := pages.Next(ctx)
+		release()
+		if err != nil {
+			if ctx.Err() != nil {
+				u.logger.Warn("syncGroups - sync aborted", zap.Int("page", *page), zap.Error(ctx.Err()))
+			}
+			return nil, err
+		}
+
+		groups := make([]*domain.Group, 0, len(records))
+		var dns []string
+		var members []*domain.GroupMember
+		var rejected []*domain.RejectedRecord
+		for _, record := range records {
+			group := record.Group
+			group.SourceID = sourceID
+			err := validateGroup(group, seen)
+			if err != nil {
+				u.logger.Warn("syncGroups - validateGroup", zap.String("group", group.DN), zap.Error(err))
+				rejected = append(rejected, toRejectedGroup(group, err))
+				continue
+			}
+
+			groups = append(groups, group)
+			dns = append(dns, group.DN)
+			members = append(members, toMembers(record)...)
+		}
+
+		result.Groups += len(groups)
+		result.RejectedGroups += len(rejected)
+		result.Memberships += len(members)
+		if len(groups) > 0 {
+			queries = append(queries,
+				u.groupRepository.BuildBulkUpsertQuery(groups),
+				u.groupRepository.BuildDeleteMembersQuery(sourceID, dns),
+			)
+		}
+		if len(members) > 0 {
+			queries = append(queries, u.groupRepository.BuildBulkInsertMembersQuery(sourceID, members))
+		}
+		if len(rejected) > 0 {
+			queries = append(queries, u.rejectedRecordRepository.BuildBulkInsertQuery(rejected))
+		}
+	}
+
+	// a rejected group keeps its previous version rather than being deleted
+	if since.IsZero() {
+		queries = append(queries, u.groupRepository.BuildDeleteMissingQuery(sourceID, slices.Collect(maps.Keys(seen))))
+	}
+	if groupSource.ExpandNested() {
+		queries = append(queries, u.groupRepository.BuildExpandNestedQueries(sourceID)...)
+	}
+	queries = append(queries, u.groupRepository.BuildResolveMembersQuery(sourceID))
+
+	return queries, nil
+}
+
+// validateGroup also rejects groups already read in the current run, a DN is unique within a source.
+func validateGroup(group *domain.Group, seen map[string]struct{}) error {
+	if _, exists := seen[group.DN]; exists {
+		return utils.ErrGroupDuplicated
+	}
+	seen[group.DN] = struct{}{}
+
+	return group.Validate()
+}
+
+// toMembers lists the memberships of a group, a member both direct and inherited is kept as direct.
+func toMembers(record *source.GroupRecord) []*domain.GroupMember {
+	members := make([]*domain.GroupMember, 0, len(record.Members)+len(record.Nested))
+	direct := make(map[string]struct{}, len(record.Members))
+	for _, dn := range record.Members {
+		if _, exists := direct[dn]; exists {
+			continue
+		}
+		direct[dn] = struct{}{}
+		members = append(members, &domain.GroupMember{GroupDN: record.Group.DN, MemberDN: dn})
+	}
+
+	for _, dn := range record.Nested {
+		if _, exists := direct[dn]; exists {
+			continue
+		}
+		direct[dn] = struct{}{}
+		members = append(members, &domain.GroupMember{GroupDN: record.Group.DN, MemberDN: dn, Nested: true})
+	}
+
+	return members
+}
+
+func toRejectedGroup(group *domain.Group, reason error) *domain.RejectedRecord {
+	return &domain.RejectedRecord{
+		SourceID: group.SourceID,
+		DN:       group.DN,
+		Reason:   reason.Error(),
+		RawAttributes: sqlxx.TextData{Parsed: map[string][]string{
+			"name":        {group.Name},
+			"description": {group.Description},
+		}},
+		CreatedAt: time.Now(),
+	}
+}
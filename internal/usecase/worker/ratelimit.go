@@ -0,0 +1,96 @@
+package workeruc
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/tuanta7/qworker/internal/domain"
+	"go.uber.org/zap"
+	"time"
+)
+
+const (
+	searchSlotPollInterval = 200 * time.Millisecond
+	searchLease            = 5 * time.Minute // outlives any page fetch, reclaims slots of crashed workers
+)
+
+// throttle waits for the page interval between two pages of a run, then for the rate limit of the connector.
+// The returned function releases the search slot and must be called once the page is fetched.
+func (u *UseCase) throttle(ctx context.Context, connectorID uint64, limit domain.RateLimit, page int) (func(), error) {
+	if page > 1 {
+		err := sleep(ctx, limit.PageInterval*time.Millisecond)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return u.waitForPage(ctx, connectorID, limit)
+}
+
+// waitForPage blocks until the connector is allowed to fetch another page and a search slot is free.
+// The returned function releases the search slot and must be called once the search is done.
+func (u *UseCase) waitForPage(ctx context.Context, connectorID uint64, limit domain.RateLimit) (func(), error) {
+	if limit.MaxPagesPerSecond > 0 {
+		for {
+			wait, err := u.rateLimitRepository.TakePage(ctx, connectorID, limit.MaxPagesPerSecond)
+			if err != nil {
+				u.logger.Error("waitForPage - u.rateLimitRepository.TakePage", zap.Error(err))
+				return nil, err
+			}
+
+			if wait <= 0 {
+				break
+			}
+
+			err = sleep(ctx, wait)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if limit.MaxConcurrentSearches == 0 {
+		return func() {}, nil
+	}
+
+	holder := uuid.NewString()
+	for {
+		ok, err := u.rateLimitRepository.AcquireSearch(ctx, connectorID, holder, limit.MaxConcurrentSearches, searchLease)
+		if err != nil {
+			u.logger.Error("waitForPage - u.rateLimitRepository.AcquireSearch", zap.Error(err))
+			return nil, err
+		}
+
+		if ok {
+			break
+		}
+
+		err = sleep(ctx, searchSlotPollInterval)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return func() {
+		// the slot must be released even if the sync context is already cancelled
+		err := u.rateLimitRepository.ReleaseSearch(context.WithoutCancel(ctx), connectorID, holder)
+		if err != nil {
+			u.logger.Warn("waitForPage - u.rateLimitRepository.ReleaseSearch", zap.Error(err))
+		}
+	}, nil
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,207 @@
+package workeruc
+
+import (
+	"context"
+	"github.com/Masterminds/squirrel"
+	"github.com/tuanta7/qworker/internal/domain"
+	"github.com/tuanta7/qworker/internal/source"
+	"github.com/tuanta7/qworker/pkg/sqlxx"
+	"github.com/tuanta7/qworker/pkg/utils"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"time"
+)
+
+// sync is the pipeline shared by every connector type and by both incremental and full syncs: it pages
+// through the source, maps and validates the records, then upserts the users, and the groups of sources
+// that have some, in a single transaction.
+// A dry run validates every record but writes nothing.
+func (u *UseCase) sync(
+	ctx context.Context,
+	connector *domain.Connector,
+	since time.Time,
+	dryRun bool,
+) (*domain.SyncResult, error) {
+	settings, err := connector.GetSyncSettings()
+	if err != nil {
+		u.logger.Error("sync - connector.GetSyncSettings", zap.Error(err))
+		return nil, err
+	}
+
+	src, err := u.sources.New(connector)
+	if err != nil {
+		u.logger.Error("sync - u.sources.New", zap.Error(err))
+		return nil, err
+	}
+	defer src.Close()
+
+	err = src.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &domain.SyncResult{ConnectorID: connector.ConnectorID, DryRun: dryRun}
+	seen := make(map[string]struct{})
+	seenExternalIDs := make(map[string]struct{})
+	pages := src.Iterate(since)
+
+	var queries []squirrel.Sqlizer
+	page := 0
+	for !pages.Done() {
+		page++
+		release, err := u.throttle(ctx, connector.ConnectorID, settings.RateLimit, page)
+		if err != nil {
+			return nil, err
+		}
+
+		records, err := pages.Next(ctx)
+		release()
+		if err != nil {
+			if ctx.Err() != nil {
+				u.logger.Warn("sync - sync aborted", zap.Int("page", page), zap.Error(ctx.Err()))
+			}
+			return nil, err
+		}
+
+		result.Total += len(records)
+		mapped := make([]*domain.User, len(records))
+		var rejected []*domain.RejectedRecord
+		for i, record := range records {
+			mapped[i], err = mapUser(src, record, connector.ConnectorID)
+			if err != nil {
+				u.logger.Warn("sync - mapUser", zap.String("record", record.ID), zap.Error(err))
+				rejected = append(rejected, toRejectedRecord(record, connector.ConnectorID, err))
+			}
+		}
+
+		linked, err := u.linkedUsernames(ctx, connector.ConnectorID, mapped)
+		if err != nil {
+			u.logger.Error("sync - u.linkedUsernames", zap.Error(err))
+			return nil, err
+		}
+
+		users := make([]*domain.User, 0, len(records))
+		for i, user := range mapped {
+			if user == nil {
+				continue
+			}
+			err = claimUser(user, linked[user.ExternalID], seen, seenExternalIDs)
+			if err != nil {
+				u.logger.Warn("sync - claimUser", zap.String("record", records[i].ID), zap.Error(err))
+				rejected = append(rejected, toRejectedRecord(records[i], connector.ConnectorID, err))
+				continue
+			}
+			users = append(users, user)
+		}
+
+		result.Synced += len(users)
+		result.Rejected += len(rejected)
+		queries = append(queries, u.userRepository.BuildBulkSyncQueries(connector.ConnectorID, users, result)...)
+		if len(rejected) > 0 {
+			queries = append(queries, u.rejectedRecordRepository.BuildBulkInsertQuery(rejected))
+		}
+	}
+
+	groupQueries, err := u.syncGroups(ctx, src, connector, settings.RateLimit, since, &page, result)
+	if err != nil {
+		return nil, err
+	}
+	queries = append(queries, groupQueries...)
+
+	if dryRun {
+		u.logger.Info("dry run finished", zap.Any("result", result))
+		return result, nil
+	}
+
+	committer, isCommitter := src.(source.Committer)
+	if isCommitter {
+		queries = append(queries, committer.CommitQueries()...)
+	}
+
+	err = u.userRepository.ExecuteTransaction(ctx, queries)
+	if err != nil {
+		u.logger.Error("sync - u.userRepository.ExecuteTransaction", zap.Error(err))
+		return nil, err
+	}
+	result.Unchanged = result.Synced - result.Created - result.Updated
+
+	if isCommitter {
+		err = committer.Committed(ctx)
+		if err != nil {
+			u.logger.Error("sync - committer.Committed", zap.Error(err))
+			return nil, err
+		}
+	}
+
+	u.logger.Info("sync successfully",
+		zap.Any("result", result),
+		zap.String("trace_id", trace.SpanContextFromContext(ctx).TraceID().String()))
+	return result, nil
+}
+
+// mapUser rejects users that would violate the private.user constraints.
+func mapUser(src source.SyncSource, record *source.Record, sourceID uint64) (*domain.User, error) {
+	user, err := src.Map(record)
+	if err != nil {
+		return nil, err
+	}
+	user.SourceID = &sourceID
+
+	err = user.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	user.Hash = user.ContentHash()
+	return user, nil
+}
+
+// linkedUsernames looks up the usernames the users with an external id are linked to, in one query per page.
+func (u *UseCase) linkedUsernames(ctx context.Context, sourceID uint64, users []*domain.User) (map[string]string, error) {
+	var externalIDs []string
+	for _, user := range users {
+		if user != nil && user.ExternalID != "" {
+			externalIDs = append(externalIDs, user.ExternalID)
+		}
+	}
+	if len(externalIDs) == 0 {
+		return nil, nil
+	}
+	return u.userRepository.GetLinkedUsernames(ctx, sourceID, externalIDs)
+}
+
+// claimUser rejects the usernames and external ids already seen in the current run, which would make the
+// upsert affect a row twice and fail. A linked user is upserted under its linked username unless the rename
+// to its own goes through, so it claims both.
+func claimUser(user *domain.User, linkedUsername string, seen, seenExternalIDs map[string]struct{}) error {
+	usernames := []string{user.Username}
+	if linkedUsername != "" && linkedUsername != user.Username {
+		usernames = append(usernames, linkedUsername)
+	}
+	for _, username := range usernames {
+		if _, exists := seen[username]; exists {
+			return utils.ErrUsernameDuplicated
+		}
+	}
+
+	if user.ExternalID != "" {
+		if _, exists := seenExternalIDs[user.ExternalID]; exists {
+			return utils.ErrExternalIDDuplicated
+		}
+		seenExternalIDs[user.ExternalID] = struct{}{}
+	}
+	for _, username := range usernames {
+		seen[username] = struct{}{}
+	}
+	return nil
+}
+
+func toRejectedRecord(record *source.Record, sourceID uint64, reason error) *domain.RejectedRecord {
+	return &domain.RejectedRecord{
+		SourceID:      sourceID,
+		DN:            record.ID,
+		Reason:        reason.Error(),
+		RawAttributes: sqlxx.TextData{Parsed: record.Attributes},
+		CreatedAt:     time.Now(),
+	}
+}
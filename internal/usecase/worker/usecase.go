@@ -3,43 +3,47 @@ package workeruc
 import (
 	"context"
 	"errors"
-	"fmt"
 	"github.com/hibiken/asynq"
 	"github.com/tuanta7/qworker/internal/domain"
 	pgrepo "github.com/tuanta7/qworker/internal/repository/postgres"
-	"github.com/tuanta7/qworker/pkg/cipherx"
-	"github.com/tuanta7/qworker/pkg/ldapclient"
+	redisrepo "github.com/tuanta7/qworker/internal/repository/redis"
+	"github.com/tuanta7/qworker/internal/source"
 	"github.com/tuanta7/qworker/pkg/logger"
-	"github.com/tuanta7/qworker/pkg/utils"
 	"go.uber.org/zap"
 	"strconv"
 	"time"
 )
 
 type UseCase struct {
-	asynqInspector      *asynq.Inspector
-	ldapClient          ldapclient.LDAPClient
-	cipher              cipherx.Cipher
-	connectorRepository *pgrepo.ConnectorRepository
-	userRepository      *pgrepo.UserRepository
-	logger              *logger.ZapLogger
+	asynqInspector           *asynq.Inspector
+	sources                  *source.Registry
+	connectorRepository      *pgrepo.ConnectorRepository
+	userRepository           *pgrepo.UserRepository
+	groupRepository          *pgrepo.GroupRepository
+	rejectedRecordRepository *pgrepo.RejectedRecordRepository
+	rateLimitRepository      *redisrepo.RateLimitRepository
+	logger                   *logger.ZapLogger
 }
 
 func NewUseCase(
 	asynqInspector *asynq.Inspector,
-	ldapClient ldapclient.LDAPClient,
-	cipher cipherx.Cipher,
+	sources *source.Registry,
 	connectorRepository *pgrepo.ConnectorRepository,
 	userRepository *pgrepo.UserRepository,
+	groupRepository *pgrepo.GroupRepository,
+	rejectedRecordRepository *pgrepo.RejectedRecordRepository,
+	rateLimitRepository *redisrepo.RateLimitRepository,
 	zl *logger.ZapLogger,
 ) *UseCase {
 	return &UseCase{
-		asynqInspector:      asynqInspector,
-		ldapClient:          ldapClient,
-		cipher:              cipher,
-		connectorRepository: connectorRepository,
-		userRepository:      userRepository,
-		logger:              zl,
+		asynqInspector:           asynqInspector,
+		sources:                  sources,
+		connectorRepository:      connectorRepository,
+		userRepository:           userRepository,
+		groupRepository:          groupRepository,
+		rejectedRecordRepository: rejectedRecordRepository,
+		rateLimitRepository:      rateLimitRepository,
+		logger:                   zl,
 	}
 }
 
@@ -54,74 +58,70 @@ func (u *UseCase) GetTask(id uint64, queue string) (*asynq.TaskInfo, error) {
 	return taskInfo, nil
 }
 
-func (u *UseCase) RunIncrementalSyncTask(ctx context.Context, message *domain.QueueMessage) error {
+func (u *UseCase) RunIncrementalSyncTask(ctx context.Context, message *domain.QueueMessage) (*domain.SyncResult, error) {
 	c, err := u.connectorRepository.GetByID(ctx, message.ConnectorID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if !c.Enabled {
-		return errors.New("connector is disabled")
+		return nil, errors.New("connector is disabled")
 	}
 
 	syncSettings, err := c.GetSyncSettings()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if !syncSettings.IncSync {
-		return errors.New("incremental sync is disabled")
-	}
-
-	switch c.ConnectorType {
-	case domain.ConnectorTypeLDAP:
-		filter := fmt.Sprintf("(%s>=%s)", c.Mapper.UpdatedAt, utils.TimeToLDAPString(c.LastSync))
-		err = u.ldapSync(ctx, c, filter)
-	default:
-		return errors.New("unsupported connector type")
-	}
-	if err != nil {
-		return err
+		return nil, errors.New("incremental sync is disabled")
 	}
 
-	c.LastSync = time.Now()
-	c.UpdatedAt = c.LastSync
-	err = u.connectorRepository.UpdateSyncInfo(ctx, c)
-	if err != nil {
-		u.logger.Error("RunIncrementalSyncTask - u.connectorRepository.UpdateSyncInfo", zap.Error(err))
-		return err
+	since := c.LastSync
+	if message.Since != nil {
+		since = *message.Since
 	}
 
-	return nil
+	return u.runSync(ctx, c, message, since)
 }
 
-func (u *UseCase) RunFullSyncTask(ctx context.Context, message *domain.QueueMessage) error {
+func (u *UseCase) RunFullSyncTask(ctx context.Context, message *domain.QueueMessage) (*domain.SyncResult, error) {
 	c, err := u.connectorRepository.GetByID(ctx, message.ConnectorID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if !c.Enabled {
-		return errors.New("connector is disabled")
+		return nil, errors.New("connector is disabled")
 	}
 
-	switch c.ConnectorType {
-	case domain.ConnectorTypeLDAP:
-		err = u.ldapSync(ctx, c)
-	default:
-		return errors.New("unsupported connector type")
-	}
+	return u.runSync(ctx, c, message, time.Time{})
+}
+
+func (u *UseCase) runSync(
+	ctx context.Context,
+	c *domain.Connector,
+	message *domain.QueueMessage,
+	since time.Time,
+) (*domain.SyncResult, error) {
+	startedAt := time.Now()
+	result, err := u.sync(ctx, c, since, message.DryRun)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	if message.DryRun {
+		return result, nil
 	}
 
-	c.LastSync = time.Now()
-	c.UpdatedAt = c.LastSync
+	// changes made while the sync was running are picked up by the next incremental sync
+	c.LastSync = startedAt
+	c.UpdatedAt = time.Now()
 	err = u.connectorRepository.UpdateSyncInfo(ctx, c)
 	if err != nil {
-		u.logger.Error("RunIncrementalSyncTask - u.connectorRepository.UpdateSyncInfo", zap.Error(err))
-		return err
+		u.logger.Error("runSync - u.connectorRepository.UpdateSyncInfo", zap.Error(err))
+		return nil, err
 	}
 
-	return nil
+	return result, nil
 }
@@ -1 +1,181 @@
 package workeruc
+
+import (
+	"context"
+	"github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/tuanta7/qworker/internal/domain"
+	pgrepo "github.com/tuanta7/qworker/internal/repository/postgres"
+	"github.com/tuanta7/qworker/internal/source"
+	"github.com/tuanta7/qworker/pkg/logger"
+	"github.com/tuanta7/qworker/pkg/sqlxx"
+	"github.com/tuanta7/qworker/pkg/utils"
+	"strings"
+	"testing"
+	"time"
+)
+
+type queryBuilderOnly struct{}
+
+func (queryBuilderOnly) Pool() *pgxpool.Pool { return nil }
+func (queryBuilderOnly) QueryBuilder() squirrel.StatementBuilderType {
+	return squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar)
+}
+func (queryBuilderOnly) Close() {}
+
+type staticSource struct {
+	pages [][]*source.Record
+}
+
+func (s *staticSource) Connect(context.Context) error { return nil }
+func (s *staticSource) Close() error                  { return nil }
+
+func (s *staticSource) Iterate(time.Time) source.PageIterator {
+	return &staticPages{pages: s.pages}
+}
+
+func (s *staticSource) Map(record *source.Record) (*domain.User, error) {
+	return &domain.User{
+		Username:    record.Attributes["uid"][0],
+		PhoneNumber: record.Attributes["mobile"][0],
+	}, nil
+}
+
+type staticPages struct {
+	pages [][]*source.Record
+}
+
+func (p *staticPages) Next(context.Context) ([]*source.Record, error) {
+	page := p.pages[0]
+	p.pages = p.pages[1:]
+	return page, nil
+}
+
+func (p *staticPages) Done() bool {
+	return len(p.pages) == 0
+}
+
+func record(uid, mobile string) *source.Record {
+	return &source.Record{
+		ID:         "uid=" + uid + ",ou=people,dc=example,dc=com",
+		Attributes: map[string][]string{"uid": {uid}, "mobile": {mobile}},
+	}
+}
+
+func TestSyncDryRun(t *testing.T) {
+	src := &staticSource{pages: [][]*source.Record{
+		{record("alice", "0901234567"), record("bob", strings.Repeat("9", 25))},
+		{record("carol", "0907654321"), record("alice", "0901234567")},
+	}}
+
+	sources := source.NewRegistry()
+	source.Register(sources, domain.ConnectorTypeLDAP, source.JSONDecoder[domain.LDAPConnector](),
+		func(*domain.Connector, *domain.LDAPConnector) (source.SyncSource, error) { return src, nil })
+
+	u := NewUseCase(nil, sources, nil,
+		pgrepo.NewUserRepository(queryBuilderOnly{}),
+		pgrepo.NewGroupRepository(queryBuilderOnly{}),
+		pgrepo.NewRejectedRecordRepository(queryBuilderOnly{}),
+		nil,
+		logger.MustNewLogger("none"),
+	)
+
+	result, err := u.sync(context.Background(), &domain.Connector{
+		ConnectorID:   1,
+		ConnectorType: domain.ConnectorTypeLDAP,
+		Data:          sqlxx.TextData{Raw: []byte(`{"syncSettings":{"batchSize":2}}`)},
+	}, time.Time{}, true)
+
+	assert.Equal(t, nil, err)
+	assert.Equal(t, &domain.SyncResult{ConnectorID: 1, Total: 4, Synced: 2, Rejected: 2, DryRun: true}, result)
+}
+
+type groupSource struct {
+	staticSource
+	groups [][]*source.GroupRecord
+}
+
+func (s *groupSource) IterateGroups(time.Time) source.GroupIterator {
+	return &staticGroupPages{pages: s.groups}
+}
+
+func (s *groupSource) ExpandNested() bool { return true }
+
+type staticGroupPages struct {
+	pages [][]*source.GroupRecord
+}
+
+func (p *staticGroupPages) Next(context.Context) ([]*source.GroupRecord, error) {
+	page := p.pages[0]
+	p.pages = p.pages[1:]
+	return page, nil
+}
+
+func (p *staticGroupPages) Done() bool {
+	return len(p.pages) == 0
+}
+
+func groupRecord(cn string, members, nested []string) *source.GroupRecord {
+	return &source.GroupRecord{
+		Group:   &domain.Group{DN: "cn=" + cn + ",ou=groups,dc=example,dc=com", Name: cn},
+		Members: members,
+		Nested:  nested,
+	}
+}
+
+func TestSyncGroupsDryRun(t *testing.T) {
+	alice := "uid=alice,ou=people,dc=example,dc=com"
+	bob := "uid=bob,ou=people,dc=example,dc=com"
+	src := &groupSource{
+		staticSource: staticSource{pages: [][]*source.Record{{record("alice", "0901234567")}}},
+		groups: [][]*source.GroupRecord{
+			{groupRecord("admins", []string{alice, alice}, []string{alice, bob})},
+			{groupRecord("admins", []string{bob}, nil), groupRecord(strings.Repeat("x", 256), nil, nil)},
+		},
+	}
+
+	sources := source.NewRegistry()
+	source.Register(sources, domain.ConnectorTypeLDAP, source.JSONDecoder[domain.LDAPConnector](),
+		func(*domain.Connector, *domain.LDAPConnector) (source.SyncSource, error) { return src, nil })
+
+	u := NewUseCase(nil, sources, nil,
+		pgrepo.NewUserRepository(queryBuilderOnly{}),
+		pgrepo.NewGroupRepository(queryBuilderOnly{}),
+		pgrepo.NewRejectedRecordRepository(queryBuilderOnly{}),
+		nil,
+		logger.MustNewLogger("none"),
+	)
+
+	result, err := u.sync(context.Background(), &domain.Connector{
+		ConnectorID:   1,
+		ConnectorType: domain.ConnectorTypeLDAP,
+		Data:          sqlxx.TextData{Raw: []byte(`{}`)},
+	}, time.Time{}, true)
+
+	assert.Equal(t, nil, err)
+	assert.Equal(t, &domain.SyncResult{
+		ConnectorID:    1,
+		Total:          1,
+		Synced:         1,
+		DryRun:         true,
+		Groups:         1,
+		RejectedGroups: 2,
+		Memberships:    2,
+	}, result)
+}
+
+func TestClaimUser(t *testing.T) {
+	seen, seenExternalIDs := make(map[string]struct{}), make(map[string]struct{})
+
+	// renamed to alice.smith at the source, it stays linked as alice when the rename is blocked
+	renamed := &domain.User{Username: "alice.smith", ExternalID: "guid-a"}
+	assert.NoError(t, claimUser(renamed, "alice", seen, seenExternalIDs))
+
+	assert.ErrorIs(t, claimUser(&domain.User{Username: "alice"}, "", seen, seenExternalIDs), utils.ErrUsernameDuplicated)
+	assert.ErrorIs(t, claimUser(&domain.User{Username: "bob", ExternalID: "guid-b"}, "alice.smith", seen, seenExternalIDs),
+		utils.ErrUsernameDuplicated)
+	assert.ErrorIs(t, claimUser(&domain.User{Username: "carol", ExternalID: "guid-a"}, "", seen, seenExternalIDs),
+		utils.ErrExternalIDDuplicated)
+	assert.NoError(t, claimUser(&domain.User{Username: "carol", ExternalID: "guid-c"}, "", seen, seenExternalIDs))
+}
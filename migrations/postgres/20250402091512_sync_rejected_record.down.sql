@@ -0,0 +1 @@
+DROP TABLE IF EXISTS private.sync_rejected_record;
\ No newline at end of file
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS private.sync_rejected_record
+(
+    id             BIGSERIAL PRIMARY KEY,
+    source_id      INTEGER       NOT NULL,
+    dn             VARCHAR(2000) NOT NULL,
+    reason         VARCHAR(1000) NOT NULL,
+    raw_attributes TEXT,
+    created_at     TIMESTAMP     NOT NULL DEFAULT NOW(),
+    FOREIGN KEY (source_id) REFERENCES private.connector (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS sync_rejected_record_source_id_idx ON private.sync_rejected_record (source_id, created_at);
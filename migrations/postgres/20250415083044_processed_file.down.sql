@@ -0,0 +1 @@
+DROP TABLE IF EXISTS private.processed_file;
\ No newline at end of file
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS private.processed_file
+(
+    source_id    INTEGER       NOT NULL,
+    checksum     CHAR(64)      NOT NULL,
+    file_name    VARCHAR(1000) NOT NULL,
+    record_count INTEGER       NOT NULL DEFAULT 0,
+    processed_at TIMESTAMP     NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (source_id, checksum),
+    FOREIGN KEY (source_id) REFERENCES private.connector (id) ON DELETE CASCADE
+);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS private.group_member;
+DROP TABLE IF EXISTS private.group;
+DROP INDEX IF EXISTS private.user_source_dn_idx;
+ALTER TABLE private.user DROP COLUMN IF EXISTS source_dn;
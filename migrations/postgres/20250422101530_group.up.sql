@@ -0,0 +1,33 @@
+ALTER TABLE private.user ADD COLUMN IF NOT EXISTS source_dn VARCHAR(2000);
+
+CREATE INDEX IF NOT EXISTS user_source_dn_idx ON private.user (source_id, lower(source_dn));
+
+CREATE TABLE IF NOT EXISTS private.group
+(
+    id          BIGSERIAL PRIMARY KEY,
+    source_id   INTEGER       NOT NULL,
+    dn          VARCHAR(2000) NOT NULL,
+    name        VARCHAR(255)  NOT NULL,
+    description VARCHAR(1000),
+    created_at  TIMESTAMP     NOT NULL DEFAULT NOW(),
+    updated_at  TIMESTAMP     NOT NULL DEFAULT NOW(),
+    UNIQUE (source_id, dn),
+    FOREIGN KEY (source_id) REFERENCES private.connector (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS group_dn_idx ON private.group (source_id, lower(dn));
+
+-- member_dn is either a user or a group of the same source, user_id is resolved from private.user.source_dn.
+-- Rows with nested set are inherited through member groups.
+CREATE TABLE IF NOT EXISTS private.group_member
+(
+    group_id  BIGINT        NOT NULL,
+    member_dn VARCHAR(2000) NOT NULL,
+    user_id   UUID,
+    nested    BOOLEAN       NOT NULL DEFAULT false,
+    PRIMARY KEY (group_id, member_dn),
+    FOREIGN KEY (group_id) REFERENCES private.group (id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES private.user (id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS group_member_user_id_idx ON private.group_member (user_id);
@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS private.mapper;
+
+CREATE TABLE private.mapper
+(
+    external_id VARCHAR(255),
+    full_name   VARCHAR(255),
+    updated_at  VARCHAR(255)
+);
@@ -0,0 +1,26 @@
+-- the first mapper table was never used, mappers are now stored per connector as a JSON document. Its rows
+-- cannot be matched to a connector, so the migration refuses to drop it unless it is empty.
+DO
+$$
+BEGIN
+    IF EXISTS (SELECT 1
+               FROM information_schema.columns
+               WHERE table_schema = 'private'
+                 AND table_name = 'mapper'
+                 AND column_name = 'external_id') THEN
+        IF EXISTS (SELECT 1 FROM private.mapper) THEN
+            RAISE EXCEPTION 'private.mapper holds rows of the old format, move them to a connector mapper first';
+        END IF;
+        DROP TABLE private.mapper;
+    END IF;
+END
+$$;
+
+CREATE TABLE IF NOT EXISTS private.mapper
+(
+    connector_id INTEGER PRIMARY KEY,
+    data         TEXT      NOT NULL,
+    created_at   TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at   TIMESTAMP NOT NULL DEFAULT NOW(),
+    FOREIGN KEY (connector_id) REFERENCES private.connector (id) ON DELETE CASCADE
+);
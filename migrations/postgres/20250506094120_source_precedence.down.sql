@@ -0,0 +1,7 @@
+DROP FUNCTION IF EXISTS private.merge_sources(JSONB, INTEGER);
+DROP FUNCTION IF EXISTS private.source_wins(TEXT, INTEGER, JSONB);
+DROP FUNCTION IF EXISTS private.outranks(INTEGER, INTEGER);
+DROP TABLE IF EXISTS private.identity_conflict;
+DROP TABLE IF EXISTS private.attribute_owner;
+ALTER TABLE private.user DROP COLUMN IF EXISTS attribute_sources;
+ALTER TABLE private.connector DROP COLUMN IF EXISTS priority;
@@ -0,0 +1,61 @@
+-- a higher priority takes precedence when several connectors write the same user, the oldest connector wins a tie
+ALTER TABLE private.connector ADD COLUMN IF NOT EXISTS priority INTEGER NOT NULL DEFAULT 0;
+
+-- attribute_sources holds the connector that wrote each attribute of the user
+ALTER TABLE private.user ADD COLUMN IF NOT EXISTS attribute_sources JSONB NOT NULL DEFAULT '{}';
+
+UPDATE private.user
+SET attribute_sources = jsonb_build_object(
+        'full_name', source_id, 'phone_number', source_id, 'email', source_id, 'active', source_id, 'data', source_id);
+
+-- an owned attribute is written by its owner whatever the priorities, other connectors only fill it until then
+CREATE TABLE IF NOT EXISTS private.attribute_owner
+(
+    attribute    VARCHAR(50) PRIMARY KEY CHECK (attribute IN ('full_name', 'phone_number', 'email', 'active', 'data')),
+    connector_id INTEGER NOT NULL,
+    FOREIGN KEY (connector_id) REFERENCES private.connector (id) ON DELETE CASCADE
+);
+
+-- a conflict is a connector syncing a value that differs from the one another connector wrote, it is kept once
+-- per user, attribute and connector with the time it was last detected
+CREATE TABLE IF NOT EXISTS private.identity_conflict
+(
+    id                BIGSERIAL PRIMARY KEY,
+    user_id           UUID        NOT NULL,
+    attribute         VARCHAR(50) NOT NULL,
+    source_id         INTEGER     NOT NULL,
+    current_source_id INTEGER,
+    winner_id         INTEGER     NOT NULL,
+    created_at        TIMESTAMP   NOT NULL DEFAULT NOW(),
+    updated_at        TIMESTAMP   NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, attribute, source_id),
+    FOREIGN KEY (user_id) REFERENCES private.user (id) ON DELETE CASCADE,
+    FOREIGN KEY (source_id) REFERENCES private.connector (id) ON DELETE CASCADE
+);
+
+CREATE OR REPLACE FUNCTION private.outranks(source INTEGER, other INTEGER) RETURNS BOOLEAN AS
+$$
+SELECT other IS NULL OR source = other OR COALESCE((SELECT (s.priority, o.id) > (o.priority, s.id)
+                                                     FROM private.connector s,
+                                                          private.connector o
+                                                     WHERE s.id = source
+                                                       AND o.id = other), true)
+$$ LANGUAGE sql STABLE;
+
+-- source_wins tells whether a connector writes an attribute, sources being the attribute_sources of the user
+CREATE OR REPLACE FUNCTION private.source_wins(attr TEXT, source INTEGER, sources JSONB) RETURNS BOOLEAN AS
+$$
+SELECT CASE
+           WHEN o.connector_id = source THEN true
+           WHEN o.connector_id = (sources ->> attr)::INTEGER THEN false
+           ELSE private.outranks(source, (sources ->> attr)::INTEGER)
+           END
+FROM (SELECT (SELECT connector_id FROM private.attribute_owner WHERE attribute = attr) AS connector_id) o
+$$ LANGUAGE sql STABLE;
+
+CREATE OR REPLACE FUNCTION private.merge_sources(sources JSONB, source INTEGER) RETURNS JSONB AS
+$$
+SELECT sources || COALESCE(jsonb_object_agg(a, source), '{}')
+FROM unnest(ARRAY ['full_name', 'phone_number', 'email', 'active', 'data']) AS a
+WHERE private.source_wins(a, source, sources)
+$$ LANGUAGE sql STABLE;
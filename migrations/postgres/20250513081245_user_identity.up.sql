@@ -0,0 +1,14 @@
+-- external_id is the identity of a user at a source, it links the user across renames. A user synced by several
+-- connectors has one identity per connector.
+CREATE TABLE IF NOT EXISTS private.user_identity
+(
+    source_id   INTEGER      NOT NULL,
+    external_id VARCHAR(255) NOT NULL,
+    user_id     UUID         NOT NULL,
+    created_at  TIMESTAMP    NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (source_id, external_id),
+    FOREIGN KEY (source_id) REFERENCES private.connector (id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES private.user (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS user_identity_user_id_idx ON private.user_identity (user_id);
@@ -0,0 +1 @@
+ALTER TABLE private.user DROP COLUMN IF EXISTS source_hashes;
@@ -0,0 +1,3 @@
+-- source_hashes holds the content hash of the user per connector, keyed by connector id, a sync skips the users
+-- whose hash did not change
+ALTER TABLE private.user ADD COLUMN IF NOT EXISTS source_hashes JSONB NOT NULL DEFAULT '{}';
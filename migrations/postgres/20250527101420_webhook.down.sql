@@ -0,0 +1,5 @@
+DROP TRIGGER IF EXISTS record_user_event ON private.user;
+DROP FUNCTION IF EXISTS private.record_user_event();
+DROP TABLE IF EXISTS private.webhook_delivery;
+DROP TABLE IF EXISTS private.user_event;
+DROP TABLE IF EXISTS private.webhook_subscription;
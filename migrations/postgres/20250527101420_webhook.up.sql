@@ -0,0 +1,87 @@
+-- events lists the event types a subscription receives, all of them when empty. The secret is encrypted with
+-- AES_SECRET like connector secrets.
+CREATE TABLE IF NOT EXISTS private.webhook_subscription
+(
+    id         SERIAL PRIMARY KEY,
+    url        VARCHAR(2000) NOT NULL,
+    secret     TEXT          NOT NULL,
+    events     TEXT[]        NOT NULL DEFAULT '{}',
+    enabled    BOOLEAN       NOT NULL DEFAULT true,
+    created_at TIMESTAMP     NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP     NOT NULL DEFAULT NOW()
+);
+
+-- user_event is the outbox of the webhooks, written in the transaction that changes the user. dispatched_at is set
+-- once the deliveries of the event are queued.
+CREATE TABLE IF NOT EXISTS private.user_event
+(
+    id            BIGSERIAL PRIMARY KEY,
+    user_id       UUID        NOT NULL,
+    event_type    VARCHAR(50) NOT NULL,
+    payload       JSONB       NOT NULL,
+    created_at    TIMESTAMP   NOT NULL DEFAULT NOW(),
+    dispatched_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS user_event_pending_idx ON private.user_event (id) WHERE dispatched_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS private.webhook_delivery
+(
+    id              BIGSERIAL PRIMARY KEY,
+    subscription_id INTEGER   NOT NULL,
+    event_id        BIGINT    NOT NULL,
+    attempt         INTEGER   NOT NULL,
+    status_code     INTEGER,
+    error           TEXT,
+    duration_ms     INTEGER   NOT NULL,
+    created_at      TIMESTAMP NOT NULL DEFAULT NOW(),
+    FOREIGN KEY (subscription_id) REFERENCES private.webhook_subscription (id) ON DELETE CASCADE,
+    FOREIGN KEY (event_id) REFERENCES private.user_event (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS webhook_delivery_event_id_idx ON private.webhook_delivery (event_id);
+
+-- record_user_event diffs the previous and new rows of a user. Writes that only touch the bookkeeping columns,
+-- such as the content hashes or updated_at, record nothing.
+CREATE OR REPLACE FUNCTION private.record_user_event() RETURNS TRIGGER AS
+$$
+DECLARE
+    fields     TEXT[] := ARRAY ['username', 'full_name', 'phone_number', 'email', 'email_verified', 'active', 'data',
+        'source_id', 'source_dn'];
+    old_row    JSONB;
+    new_row    JSONB  := to_jsonb(NEW) - 'attribute_sources' - 'source_hashes';
+    changes    JSONB  := '{}';
+    field      TEXT;
+    event_type TEXT   := 'user.created';
+BEGIN
+    IF TG_OP = 'UPDATE' THEN
+        old_row := to_jsonb(OLD);
+        FOREACH field IN ARRAY fields
+            LOOP
+                IF old_row -> field IS DISTINCT FROM new_row -> field THEN
+                    changes := changes || jsonb_build_object(field,
+                                                             jsonb_build_object('old', old_row -> field, 'new', new_row -> field));
+                END IF;
+            END LOOP;
+
+        IF changes = '{}' THEN
+            RETURN NEW;
+        END IF;
+
+        event_type := CASE
+                          WHEN COALESCE(OLD.active, false) AND NOT COALESCE(NEW.active, false) THEN 'user.deprovisioned'
+                          ELSE 'user.updated' END;
+    END IF;
+
+    INSERT INTO private.user_event (user_id, event_type, payload)
+    VALUES (NEW.id, event_type, jsonb_build_object('user', new_row, 'changes', changes));
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS record_user_event ON private.user;
+CREATE TRIGGER record_user_event
+    AFTER INSERT OR UPDATE
+    ON private.user
+    FOR EACH ROW
+EXECUTE FUNCTION private.record_user_event();
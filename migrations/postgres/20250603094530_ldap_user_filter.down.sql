@@ -0,0 +1,3 @@
+UPDATE private.connector
+SET data = (data::jsonb - 'userFilter')::text
+WHERE connector_type = 'ldap';
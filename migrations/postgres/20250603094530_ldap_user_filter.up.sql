@@ -0,0 +1,16 @@
+-- the user filter of LDAP connectors is now required, existing connectors get the user classes of their mapper preset
+-- in place of the (objectClass=*) they searched with, connectors without a mapper using Active Directory
+UPDATE private.connector c
+SET data = jsonb_set(c.data::jsonb, '{userFilter}', to_jsonb(
+        CASE p.preset
+            WHEN 'active_directory' THEN '(&(objectCategory=person)(objectClass=user))'
+            WHEN 'openldap' THEN '(objectClass=inetOrgPerson)'
+            WHEN 'freeipa' THEN '(objectClass=inetOrgPerson)'
+            ELSE '(objectClass=person)'
+            END))::text
+FROM (SELECT c.id, CASE WHEN m.connector_id IS NULL THEN 'active_directory' ELSE m.data::jsonb ->> 'preset' END AS preset
+      FROM private.connector c
+               LEFT JOIN private.mapper m ON m.connector_id = c.id) p
+WHERE p.id = c.id
+  AND c.connector_type = 'ldap'
+  AND COALESCE(c.data::jsonb ->> 'userFilter', '') = '';
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS private.user_source_dns_idx;
+ALTER TABLE private.user DROP COLUMN IF EXISTS source_dns;
@@ -0,0 +1,9 @@
+-- source_dns holds the lower-cased DN of the user per connector, keyed by connector id, so that the members of a
+-- group are linked to the user even when another connector holds it
+ALTER TABLE private.user ADD COLUMN IF NOT EXISTS source_dns JSONB NOT NULL DEFAULT '{}';
+
+UPDATE private.user
+SET source_dns = jsonb_build_object(source_id::text, lower(source_dn))
+WHERE source_dn IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS user_source_dns_idx ON private.user USING GIN (source_dns jsonb_path_ops);
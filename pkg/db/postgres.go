@@ -3,6 +3,7 @@ package db
 import (
 	"context"
 	"github.com/Masterminds/squirrel"
+	"github.com/exaring/otelpgx"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/tuanta7/qworker/config"
 	"log"
@@ -73,3 +74,10 @@ func WithMinConns(minConns int32) PostgresOption {
 		c.MinConns = minConns
 	}
 }
+
+// WithTracing creates a span for every query, spans join the trace carried by the query context.
+func WithTracing() PostgresOption {
+	return func(c *pgxpool.Config) {
+		c.ConnConfig.Tracer = otelpgx.NewTracer()
+	}
+}
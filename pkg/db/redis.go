@@ -15,8 +15,8 @@ func NewRedisSentinelClient(cfg *config.Config) (*redis.Client, error) {
 		MasterName:    cfg.Redis.MasterName,
 		Password:      cfg.Redis.Password,
 		DB:            cfg.Redis.Database,
-		PoolSize:      10,
-		MinIdleConns:  3,
+		PoolSize:      cfg.Redis.PoolSize,
+		MinIdleConns:  cfg.Redis.MinIdle,
 	})
 
 	ctx := context.Background()
@@ -0,0 +1,133 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const checkTimeout = 3 * time.Second
+
+// Check reports whether a dependency is ready, a nil error means ready.
+type Check func(ctx context.Context) error
+
+type Status struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// Server serves /healthz for liveness and /readyz for readiness of the registered checks.
+type Server struct {
+	lock   sync.RWMutex
+	checks map[string]Check
+	mux    *http.ServeMux
+	srv    *http.Server
+}
+
+func NewServer(addr string) *Server {
+	s := &Server{
+		checks: make(map[string]Check),
+		mux:    http.NewServeMux(),
+	}
+
+	s.mux.HandleFunc("GET /healthz", s.handleLiveness)
+	s.mux.HandleFunc("GET /readyz", s.handleReadiness)
+	s.srv = &http.Server{
+		Addr:              addr,
+		Handler:           s.mux,
+		ReadHeaderTimeout: 5 * time.Second,
+		WriteTimeout:      10 * time.Second,
+	}
+
+	return s
+}
+
+func (s *Server) AddCheck(name string, check Check) {
+	s.lock.Lock()
+	s.checks[name] = check
+	s.lock.Unlock()
+}
+
+// Handle registers an extra handler on the same listener.
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+// Start listens synchronously so that a busy port fails the startup, then serves in the background.
+func (s *Server) Start() error {
+	ln, err := net.Listen("tcp", s.srv.Addr)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		_ = s.srv.Serve(ln)
+	}()
+
+	return nil
+}
+
+func (s *Server) Shutdown(ctx context.Context) error {
+	err := s.srv.Shutdown(ctx)
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (s *Server) handleLiveness(w http.ResponseWriter, _ *http.Request) {
+	writeStatus(w, http.StatusOK, &Status{Status: "ok"})
+}
+
+func (s *Server) handleReadiness(w http.ResponseWriter, r *http.Request) {
+	s.lock.RLock()
+	names := make([]string, 0, len(s.checks))
+	for name := range s.checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	checks := make([]Check, len(names))
+	for i, name := range names {
+		checks[i] = s.checks[name]
+	}
+	s.lock.RUnlock()
+
+	results := make([]error, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(r.Context(), checkTimeout)
+			defer cancel()
+			results[i] = check(ctx)
+		}()
+	}
+	wg.Wait()
+
+	code := http.StatusOK
+	status := &Status{Status: "ok", Checks: make(map[string]string, len(names))}
+	for i, name := range names {
+		if results[i] != nil {
+			code = http.StatusServiceUnavailable
+			status.Status = "unavailable"
+			status.Checks[name] = results[i].Error()
+			continue
+		}
+		status.Checks[name] = "ok"
+	}
+
+	writeStatus(w, code, status)
+}
+
+func writeStatus(w http.ResponseWriter, code int, status *Status) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(status)
+}
@@ -0,0 +1,41 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestServer(t *testing.T) {
+	s := NewServer("localhost:0")
+	s.AddCheck("postgres", func(ctx context.Context) error { return nil })
+
+	t.Run("liveness", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		s.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("ready", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		s.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("not_ready", func(t *testing.T) {
+		s.AddCheck("redis", func(ctx context.Context) error { return errors.New("connection refused") })
+
+		rec := httptest.NewRecorder()
+		s.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+		status := &Status{}
+		assert.Equal(t, nil, json.Unmarshal(rec.Body.Bytes(), status))
+		assert.Equal(t, "ok", status.Checks["postgres"])
+		assert.Equal(t, "connection refused", status.Checks["redis"])
+	})
+}
@@ -1,6 +1,7 @@
 package sqlxx
 
 import (
+	"bytes"
 	"database/sql/driver"
 	"encoding/json"
 	"errors"
@@ -35,6 +36,23 @@ func (t TextData) Value() (driver.Value, error) {
 	return json.Marshal(t.Parsed)
 }
 
+// ParseJSON fills Parsed from Raw. Numbers are kept as json.Number, so that integers read back unchanged.
+func (t *TextData) ParseJSON() error {
+	decoder := json.NewDecoder(bytes.NewReader(t.Raw))
+	decoder.UseNumber()
+
+	var parsed any
+	err := decoder.Decode(&parsed)
+	if err != nil {
+		return err
+	}
+	t.Parsed = parsed
+	return nil
+}
+
 func (t *TextData) MarshalJSON() ([]byte, error) {
+	if t.Parsed == nil && len(t.Raw) > 0 && json.Valid(t.Raw) {
+		return t.Raw, nil
+	}
 	return json.Marshal(t.Parsed)
 }
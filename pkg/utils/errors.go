@@ -8,4 +8,41 @@ var (
 	ErrUserNotFound      = errors.New("user not found")
 	ErrNoUserProvided    = errors.New("no users provided")
 	ErrTaskConflict      = errors.New("task conflict")
+	ErrConnectorDisabled = errors.New("connector is disabled")
+	ErrInvalidToken      = errors.New("invalid bearer token")
+
+	ErrUnsupportedMessageVersion = errors.New("unsupported queue message version")
+	ErrUnsupportedConnectorType  = errors.New("unsupported connector type")
+	ErrInvalidMapper             = errors.New("invalid connector mapper")
+	ErrInvalidConnector          = errors.New("invalid connector config")
+
+	ErrSubscriptionNotFound = errors.New("webhook subscription not found")
+	ErrEventNotFound        = errors.New("user event not found")
+)
+
+var (
+	ErrUsernameRequired     = errors.New("username is required")
+	ErrUsernameTooLong      = errors.New("username exceeds 255 characters")
+	ErrUsernameDuplicated   = errors.New("username is duplicated in this sync run")
+	ErrExternalIDTooLong    = errors.New("external id exceeds 255 characters")
+	ErrExternalIDDuplicated = errors.New("external id is duplicated in this sync run")
+	ErrFullNameTooLong      = errors.New("full name exceeds 1000 characters")
+	ErrPhoneNumberTooLong   = errors.New("phone number exceeds 20 characters")
+	ErrEmailTooLong         = errors.New("email exceeds 1000 characters")
+	ErrInvalidTextEncoding  = errors.New("attribute value is not valid UTF-8 text")
+	ErrUserConflict         = errors.New("user conflicts with an existing user")
+	ErrDNTooLong            = errors.New("dn exceeds 2000 characters")
+)
+
+var (
+	ErrGroupNameRequired       = errors.New("group name is required")
+	ErrGroupNameTooLong        = errors.New("group name exceeds 255 characters")
+	ErrGroupDescriptionTooLong = errors.New("group description exceeds 1000 characters")
+	ErrGroupDuplicated         = errors.New("group is duplicated in this sync run")
+)
+
+var (
+	ErrInvalidResource = errors.New("invalid scim resource")
+	ErrInvalidFilter   = errors.New("invalid scim filter")
+	ErrInvalidPatch    = errors.New("invalid scim patch operation")
 )
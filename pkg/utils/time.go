@@ -4,6 +4,8 @@ import "time"
 
 const (
 	ldapTimeFormat = "20060102150405.0Z"
+	// generalizedTimeFormat parses the LDAP generalized time, with or without fractional seconds.
+	generalizedTimeFormat = "20060102150405Z0700"
 )
 
 func TimeToLDAPString(t time.Time) string {
@@ -13,3 +15,8 @@ func TimeToLDAPString(t time.Time) string {
 func LDAPStringToTime(ldapTime string) (time.Time, error) {
 	return time.Parse(ldapTimeFormat, ldapTime)
 }
+
+// ParseGeneralizedTime reads an LDAP generalized time in any zone, not only the form of TimeToLDAPString.
+func ParseGeneralizedTime(value string) (time.Time, error) {
+	return time.Parse(generalizedTimeFormat, value)
+}